@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 
 	"github.com/cybozu-go/moco/pkg/constants"
 	"github.com/robfig/cron/v3"
@@ -54,6 +55,26 @@ type MySQLClusterSpec struct {
 	// +optional
 	MySQLConfigMapName *string `json:"mysqlConfigMapName,omitempty"`
 
+	// MySQLPort is the port number on which mysqld accepts client connections.
+	// The Services of the cluster expose the same port.
+	// This field is not editable.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=3306
+	// +optional
+	MySQLPort int32 `json:"mysqlPort,omitempty"`
+
+	// ReplicationUser is the MySQL user with which the replicas connect to the primary.
+	// MOCO generates its password and creates the user on the primary.
+	// The name must be a valid MySQL user name of up to 32 characters consisting of
+	// alphanumerics, `_`, and `-`.  Other users of MOCO cannot be used.
+	// This field is not editable.
+	// +kubebuilder:validation:MaxLength=32
+	// +kubebuilder:validation:Pattern="^[0-9A-Za-z_][0-9A-Za-z_-]*$"
+	// +kubebuilder:default=moco-repl
+	// +optional
+	ReplicationUser string `json:"replicationUser,omitempty"`
+
 	// ReplicationSourceSecretName is a `Secret` name which contains replication source info.
 	// If this field is given, the `MySQLCluster` works as an intermediate primary.
 	// +nullable
@@ -94,6 +115,140 @@ type MySQLClusterSpec struct {
 	// +optional
 	StartupWaitSeconds int32 `json:"startupWaitSeconds,omitempty"`
 
+	// MinPrimaryUptimeBeforeWritesSeconds is the duration for which an instance must
+	// stay as the primary before MOCO makes it writable.
+	// This avoids accepting writes on a primary that may be failed over soon.
+	// The default is 0, which makes the primary writable immediately.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinPrimaryUptimeBeforeWritesSeconds int32 `json:"minPrimaryUptimeBeforeWritesSeconds,omitempty"`
+
+	// CheckIntervalSeconds overrides the interval of the cluster maintenance
+	// given by `--check-interval` flag of moco-controller.
+	// The value is clamped between 5 and 3600 seconds.
+	// The default is 0, which means the global interval is used.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CheckIntervalSeconds int32 `json:"checkIntervalSeconds,omitempty"`
+
+	// MaxWaitSeconds is the duration for which MOCO waits for the cluster to converge
+	// before it escalates, i.e. sets the `WaitTimeout` condition and records a warning event.
+	// The default is 0, which means MOCO waits forever.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxWaitSeconds int32 `json:"maxWaitSeconds,omitempty"`
+
+	// MaxReplicationLagSeconds is the threshold of `Seconds_Behind_Master` of replicas.
+	// Replicas delayed over this threshold do not acknowledge transactions of the primary
+	// as semi-synchronous replicas, and are reported by the `OutOfSync` condition.
+	// Unlike `maxDelaySeconds`, this does not affect the readiness of the Pods.
+	// The default is 0, which disables the check.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicationLagSeconds int32 `json:"maxReplicationLagSeconds,omitempty"`
+
+	// ReplicaHealthCheckSQL is an SQL statement to check the health of replica instances
+	// in addition to the replication status.
+	// The statement is executed in a read-only transaction and must return a single
+	// boolean value.  If it returns false or fails, the replica is treated as not ready.
+	// This is an arbitrary statement, so MOCO executes it as `moco-readonly` user
+	// and cancels it after 5 seconds.
+	// +optional
+	ReplicaHealthCheckSQL string `json:"replicaHealthCheckSQL,omitempty"`
+
+	// EnsureTables is the list of tables that MOCO creates on the primary instance
+	// when it makes the instance writable.  Existing tables are left as they are.
+	// This is ignored for an intermediate primary.
+	// +optional
+	EnsureTables []TableSpec `json:"ensureTables,omitempty"`
+
+	// RequireGTIDAutoPosition makes MOCO re-configure replicas that replicate
+	// data without `MASTER_AUTO_POSITION=1`, i.e. based on binlog file and position.
+	// The default is true.
+	// +kubebuilder:default=true
+	// +optional
+	RequireGTIDAutoPosition *bool `json:"requireGTIDAutoPosition,omitempty"`
+
+	// AutoReCloneOnGap makes MOCO re-clone the data of a replica from the primary when the replica
+	// lacks transactions that have been purged from the binary logs of the primary.
+	// Such a replica can never catch up by replication.
+	// MOCO re-clones at most one replica at a time and waits at least 10 minutes between re-clones.
+	// A replica whose data volume is smaller than the data of the primary is not re-cloned.
+	// +optional
+	AutoReCloneOnGap bool `json:"autoReCloneOnGap,omitempty"`
+
+	// ReadinessPolicy specifies the conditions required for the `Ready` condition to be true.
+	// "AvailableOnly" requires the cluster to be available.
+	// "FullyHealthy" requires the cluster to be healthy, i.e. all replicas are synced.
+	// +kubebuilder:default=AvailableOnly
+	// +optional
+	ReadinessPolicy ReadinessPolicy `json:"readinessPolicy,omitempty"`
+
+	// MaxExecutionTimeMilliseconds limits the execution time of the statements that MOCO runs
+	// to operate mysqld instances.  mysqld aborts the queries to gather the status by
+	// `MAX_EXECUTION_TIME` optimizer hint, and MOCO cancels the other statements.
+	// Waiting for replicas to catch up, e.g. during a failover, is not limited by this.
+	// Zero means no limit.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxExecutionTimeMilliseconds int32 `json:"maxExecutionTimeMilliseconds,omitempty"`
+
+	// FenceOldPrimaryOnFailover makes MOCO fence the old primary before promoting a new one in a failover.
+	// If the old primary is still reachable, MOCO sets `super_read_only=ON` and kills its client connections
+	// so that it cannot accept writes after the promotion.
+	// +optional
+	FenceOldPrimaryOnFailover bool `json:"fenceOldPrimaryOnFailover,omitempty"`
+
+	// FailoverWebhookURL is the URL to which MOCO POSTs a JSON notification
+	// after it changes the primary instance by a switchover or a failover.
+	// The notification is sent in the background and retried a few times on failures.
+	// The host of the URL must be allowed by `--failover-webhook-hosts` flag of moco-controller.
+	// +kubebuilder:validation:Pattern="^https?://"
+	// +optional
+	FailoverWebhookURL string `json:"failoverWebhookURL,omitempty"`
+
+	// FailoverTieBreakers is the ordered list of rules to choose the new primary in a failover
+	// when multiple replicas have the most advanced GTID set.
+	// "Priority" prefers replicas whose Pods have the highest `moco.cybozu.com/failover-priority` annotation.
+	// "SameZone" prefers replicas in the same zone as the old primary by `topology.kubernetes.io/zone` label of Pods.
+	// The replica with the lowest index is chosen if the rules do not narrow down the candidates to one.
+	// The default is ["Priority", "SameZone"].
+	// +optional
+	FailoverTieBreakers []FailoverTieBreaker `json:"failoverTieBreakers,omitempty"`
+
+	// AllReplicasDownPolicy specifies what the primary does while all replicas are unavailable.
+	// "Halt" keeps the primary waiting for semi-synchronous acknowledgements, so writes are blocked.
+	// "Continue" disables semi-synchronous replication on a writable primary so that it keeps accepting writes.
+	// Transactions committed in this mode may be lost if the primary fails before replicas catch up.
+	// +kubebuilder:default=Halt
+	// +optional
+	AllReplicasDownPolicy AllReplicasDownPolicy `json:"allReplicasDownPolicy,omitempty"`
+
+	// SemiSyncTimeoutSeconds sets `rpl_semi_sync_master_timeout` of the primary instance.
+	// If the primary does not receive an acknowledgement from replicas within this period,
+	// it falls back to asynchronous replication so that writes are not blocked.
+	// Transactions committed asynchronously may be lost if the primary fails before replicas catch up.
+	// If not set, the timeout is 24 hours so that the replication practically never falls back.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4294967
+	// +optional
+	SemiSyncTimeoutSeconds *int32 `json:"semiSyncTimeoutSeconds,omitempty"`
+
+	// SemiSyncWaitForCount sets `rpl_semi_sync_master_wait_for_slave_count` of the primary instance,
+	// i.e. the number of replicas that must acknowledge a transaction before it is committed.
+	// The value must not exceed `replicas - 1`.
+	// If not set, the count is `replicas / 2`.
+	// Note that a larger value makes writes block when fewer replicas are available.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	SemiSyncWaitForCount *int32 `json:"semiSyncWaitForCount,omitempty"`
+
+	// SwitchoverBlackoutWindows is the list of periods during which MOCO defers
+	// switchovers requested by `kubectl moco switchover`.
+	// Switchovers for terminating Pods and failovers are not deferred.
+	// +optional
+	SwitchoverBlackoutWindows []BlackoutWindow `json:"switchoverBlackoutWindows,omitempty"`
+
 	// LogRotationSchedule specifies the schedule to rotate MySQL logs.
 	// If not set, the default is to rotate logs every 5 minutes.
 	// See https://pkg.go.dev/github.com/robfig/cron/v3#hdr-CRON_Expression_Format for the field format.
@@ -140,6 +295,23 @@ func (s MySQLClusterSpec) validateCreate() (admission.Warnings, field.ErrorList)
 		}
 	}
 
+	pp = p.Child("mysqlPort")
+	if s.MySQLPort < 0 || s.MySQLPort > 65535 {
+		allErrs = append(allErrs, field.Invalid(pp, s.MySQLPort, "mysqlPort must be between 1 and 65535"))
+	}
+	switch s.mysqlPort() {
+	case constants.MySQLXPort, constants.MySQLAdminPort, constants.MySQLHealthPort,
+		constants.AgentPort, constants.AgentMetricsPort, constants.ExporterPort:
+		allErrs = append(allErrs, field.Invalid(pp, s.MySQLPort, "reserved port"))
+	}
+
+	pp = p.Child("replicationUser")
+	if s.ReplicationUser != "" {
+		if err := validateReplicationUser(s.ReplicationUser); err != nil {
+			allErrs = append(allErrs, field.Invalid(pp, s.ReplicationUser, err.Error()))
+		}
+	}
+
 	pp = p.Child("serverIDBase")
 	if s.ServerIDBase <= 0 {
 		allErrs = append(allErrs, field.Invalid(pp, s.ServerIDBase, "serverIDBase must be a positive integer"))
@@ -153,9 +325,23 @@ func (s MySQLClusterSpec) validateCreate() (admission.Warnings, field.ErrorList)
 		}
 	}
 
+	pp = p.Child("switchoverBlackoutWindows")
+	for i, w := range s.SwitchoverBlackoutWindows {
+		if _, err := cron.ParseStandard(w.Schedule); err != nil {
+			allErrs = append(allErrs, field.Invalid(pp.Index(i).Child("schedule"), w.Schedule, err.Error()))
+		}
+	}
+
+	pp = p.Child("semiSyncWaitForCount")
+	if s.SemiSyncWaitForCount != nil && *s.SemiSyncWaitForCount > s.Replicas-1 {
+		allErrs = append(allErrs, field.Invalid(pp, *s.SemiSyncWaitForCount, "semiSyncWaitForCount must not exceed replicas - 1"))
+	}
+
 	pp = p.Child("replicas")
 	if s.Replicas%2 == 0 {
-		allErrs = append(allErrs, field.Invalid(pp, s.Replicas, "replicas must be a positive odd number"))
+		allErrs = append(allErrs, field.Invalid(pp, s.Replicas,
+			"replicas must be a positive odd number because MOCO waits for replicas / 2 replicas to acknowledge each transaction,"+
+				" which makes the instances having the transaction a majority only when the number is odd"))
 	}
 	if s.Replicas <= 0 {
 		allErrs = append(allErrs, field.Invalid(pp, s.Replicas, "replicas must be a positive integer"))
@@ -191,7 +377,7 @@ func (s MySQLClusterSpec) validateCreate() (admission.Warnings, field.ErrorList)
 		for i, port := range s.PodTemplate.Spec.Containers[mysqldIndex].Ports {
 			if port.ContainerPort != nil {
 				switch *port.ContainerPort {
-				case constants.MySQLPort, constants.MySQLXPort, constants.MySQLAdminPort, constants.MySQLHealthPort:
+				case s.mysqlPort(), constants.MySQLXPort, constants.MySQLAdminPort, constants.MySQLHealthPort:
 					allErrs = append(allErrs, field.Invalid(pp.Index(i), port.ContainerPort, "reserved port"))
 				}
 			}
@@ -252,6 +438,14 @@ func (s MySQLClusterSpec) validateUpdate(ctx context.Context, apiReader client.R
 			allErrs = append(allErrs, field.Forbidden(p, "replication source secret name cannot be modified"))
 		}
 	}
+	if s.mysqlPort() != old.mysqlPort() {
+		p := p.Child("mysqlPort")
+		allErrs = append(allErrs, field.Forbidden(p, "not editable"))
+	}
+	if s.replicationUser() != old.replicationUser() {
+		p := p.Child("replicationUser")
+		allErrs = append(allErrs, field.Forbidden(p, "not editable"))
+	}
 	if !equality.Semantic.DeepEqual(s.Restore, old.Restore) {
 		p := p.Child("restore")
 		allErrs = append(allErrs, field.Forbidden(p, "not editable"))
@@ -290,6 +484,50 @@ func (s MySQLClusterSpec) validateUpdate(ctx context.Context, apiReader client.R
 	return warns, append(allErrs, errs...)
 }
 
+// mysqlPort returns the port number of mysqld.
+// Zero means the default port for clusters created before `mysqlPort` was introduced.
+func (s MySQLClusterSpec) mysqlPort() int32 {
+	if s.MySQLPort == 0 {
+		return constants.MySQLPort
+	}
+	return s.MySQLPort
+}
+
+// replicationUser returns the name of the replication user.
+// Empty means the default user for clusters created before `replicationUser` was introduced.
+func (s MySQLClusterSpec) replicationUser() string {
+	if s.ReplicationUser == "" {
+		return constants.ReplicationUser
+	}
+	return s.ReplicationUser
+}
+
+// replicationUserPattern is the pattern of the replication user names.
+// The name is also a part of a Secret key, so only the characters valid in both
+// MySQL unquoted identifiers and Secret keys are allowed, except that `-` is allowed
+// for the default `moco-repl`.
+var replicationUserPattern = regexp.MustCompile(`^[0-9A-Za-z_][0-9A-Za-z_-]*$`)
+
+// validateReplicationUser validates `user` as the name of the replication user.
+func validateReplicationUser(user string) error {
+	// MySQL user names can be up to 32 characters long.
+	if len(user) > 32 {
+		return errors.New("user name must be no more than 32 characters")
+	}
+	if !replicationUserPattern.MatchString(user) {
+		return errors.New("user name must consist of alphanumerics, '_', and '-', and must not start with '-'")
+	}
+	if user == constants.ReplicationUser {
+		return nil
+	}
+	for _, u := range constants.MocoUsers {
+		if u == user {
+			return fmt.Errorf("%s is reserved for MOCO", user)
+		}
+	}
+	return nil
+}
+
 func (s MySQLClusterSpec) validateVolumeExpansionSupported(ctx context.Context, apiReader client.Reader, targetIndices []int) field.ErrorList {
 	var allErrs field.ErrorList
 	p := field.NewPath("spec").Child("volumeClaimTemplates")
@@ -410,6 +648,45 @@ type PodTemplateSpec struct {
 	OverwriteContainers []OverwriteContainer `json:"overwriteContainers,omitempty"`
 }
 
+// BlackoutWindow is a period that starts on a schedule and lasts for a fixed duration.
+type BlackoutWindow struct {
+	// Schedule is the start time of the window in Cron format.
+	// See https://pkg.go.dev/github.com/robfig/cron/v3#hdr-CRON_Expression_Format for the field format.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// DurationSeconds is the length of the window.
+	// +kubebuilder:validation:Minimum=1
+	DurationSeconds int32 `json:"durationSeconds"`
+}
+
+// ReadinessPolicy is the policy to determine the readiness of a cluster.
+// +kubebuilder:validation:Enum=AvailableOnly;FullyHealthy
+type ReadinessPolicy string
+
+const (
+	ReadinessPolicyAvailableOnly ReadinessPolicy = "AvailableOnly"
+	ReadinessPolicyFullyHealthy  ReadinessPolicy = "FullyHealthy"
+)
+
+// AllReplicasDownPolicy is the policy for the primary while all replicas are unavailable.
+// +kubebuilder:validation:Enum=Halt;Continue
+type AllReplicasDownPolicy string
+
+const (
+	AllReplicasDownPolicyHalt     AllReplicasDownPolicy = "Halt"
+	AllReplicasDownPolicyContinue AllReplicasDownPolicy = "Continue"
+)
+
+// FailoverTieBreaker is a rule to choose the new primary among replicas with the same GTID set.
+// +kubebuilder:validation:Enum=Priority;SameZone
+type FailoverTieBreaker string
+
+const (
+	FailoverTieBreakerPriority FailoverTieBreaker = "Priority"
+	FailoverTieBreakerSameZone FailoverTieBreaker = "SameZone"
+)
+
 // OverwriteableContainerName is the name of the container.
 // +kubebuilder:validation:Enum=agent;moco-init;slow-log;mysqld-exporter
 type OverwriteableContainerName string
@@ -541,6 +818,24 @@ type ServiceTemplate struct {
 	Spec *ServiceSpecApplyConfiguration `json:"spec,omitempty"`
 }
 
+// TableSpec defines a table that MOCO creates if it does not exist.
+type TableSpec struct {
+	// Database is the name of the database.  The database is created if it does not exist.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=64
+	Database string `json:"database"`
+
+	// Name is the name of the table.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=64
+	Name string `json:"name"`
+
+	// Definition is the list of column and index definitions of the table.
+	// e.g. "id INT PRIMARY KEY, ts TIMESTAMP(6) NOT NULL"
+	// +kubebuilder:validation:MinLength=1
+	Definition string `json:"definition"`
+}
+
 // RestoreSpec represents a set of parameters for Point-in-Time Recovery.
 type RestoreSpec struct {
 	// SourceName is the name of the source `MySQLCluster`.
@@ -572,6 +867,14 @@ type MySQLClusterStatus struct {
 	// Initially, this is zero.
 	CurrentPrimaryIndex int `json:"currentPrimaryIndex"`
 
+	// PrimarySince is the time when the current primary became the primary.
+	// +optional
+	PrimarySince *metav1.Time `json:"primarySince,omitempty"`
+
+	// Instances is the list of the observed status of each instance.
+	// +optional
+	Instances []InstanceStatus `json:"instances,omitempty"`
+
 	// SyncedReplicas is the number of synced instances including the primary.
 	// +optional
 	SyncedReplicas int `json:"syncedReplicas,omitempty"`
@@ -584,6 +887,33 @@ type MySQLClusterStatus struct {
 	// +optional
 	ErrantReplicaList []int `json:"errantReplicaList,omitempty"`
 
+	// OutOfSyncReplicas is the list of instances that are not synced with the primary
+	// and the reason why each instance is considered out of sync.
+	// +optional
+	OutOfSyncReplicas []OutOfSyncReplica `json:"outOfSyncReplicas,omitempty"`
+
+	// PrimaryGTIDPurged is `@@gtid_purged` of the primary instance, i.e. the set of
+	// transactions that are no longer available in the binary logs of the primary.
+	// A replica that lacks any of them cannot catch up without cloning.
+	// This keeps the last observed value while the primary is unreachable.
+	// +optional
+	PrimaryGTIDPurged string `json:"primaryGTIDPurged,omitempty"`
+
+	// SemiSyncClients is the number of semi-synchronous replicas connected to the primary.
+	// +optional
+	SemiSyncClients int `json:"semiSyncClients,omitempty"`
+
+	// SemiSyncWaitForCount is the number of acknowledgements from semi-synchronous
+	// replicas that the primary waits for before committing a transaction.
+	// The primary cannot accept writes while SemiSyncClients is less than this.
+	// +optional
+	SemiSyncWaitForCount int `json:"semiSyncWaitForCount,omitempty"`
+
+	// SemiSyncAcknowledgers is the list of indices of replicas that are connected to the primary
+	// and actively acknowledging transactions as semi-synchronous replicas.
+	// +optional
+	SemiSyncAcknowledgers []int `json:"semiSyncAcknowledgers,omitempty"`
+
 	// Backup is the status of the last successful backup.
 	// +optional
 	Backup BackupStatus `json:"backup"`
@@ -596,6 +926,43 @@ type MySQLClusterStatus struct {
 	// +optional
 	Cloned bool `json:"cloned,omitempty"`
 
+	// Clones is the list of the last completed clone operations of instances.
+	// +optional
+	Clones []InstanceCloneStatus `json:"clones,omitempty"`
+
+	// LastReCloneTime is the time when MOCO last started re-cloning a replica by `spec.autoReCloneOnGap`.
+	// +optional
+	LastReCloneTime *metav1.Time `json:"lastReCloneTime,omitempty"`
+
+	// ReplicaRecoveries is the list of replicas that MOCO has tried to recover
+	// from transient errors of the replication SQL thread.
+	// An entry is removed when the SQL thread of the replica runs without errors.
+	// +optional
+	ReplicaRecoveries []ReplicaRecoveryStatus `json:"replicaRecoveries,omitempty"`
+
+	// RequeueReason is the reason why MOCO is waiting for the cluster to become healthy.
+	// This is empty if MOCO has nothing to wait for.
+	// +optional
+	RequeueReason string `json:"requeueReason,omitempty"`
+
+	// WaitingSince is the time when MOCO started waiting for the cluster to converge.
+	// This is cleared when MOCO has nothing to wait for.
+	// +optional
+	WaitingSince *metav1.Time `json:"waitingSince,omitempty"`
+
+	// DeferredOperations is the list of operations that MOCO decided to run but deferred
+	// in the last check because of rate limits or waiting periods.
+	// Each entry is the name of an operation, i.e. "Switchover", "Failover", or "SetWritable",
+	// or "ReClone/<index>" for a re-clone of a replica.  This is empty if nothing is deferred.
+	// +optional
+	DeferredOperations []string `json:"deferredOperations,omitempty"`
+
+	// LastReconcileTime is the time when MOCO last finished maintaining the cluster without errors.
+	// Failed attempts do not update this, so a stale value means MOCO is stuck on the cluster.
+	// To save writes to kube-apiserver, this is updated only when it is older than 5 minutes.
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
 	// ReconcileInfo represents version information for reconciler.
 	// +optional
 	ReconcileInfo ReconcileInfo `json:"reconcileInfo"`
@@ -607,8 +974,123 @@ const (
 	ConditionHealthy          string = "Healthy"
 	ConditionStatefulSetReady string = "StatefulSetReady"
 	ConditionReconcileSuccess string = "ReconcileSuccess"
+
+	// ConditionReady is true if the cluster satisfies `spec.readinessPolicy`.
+	ConditionReady string = "Ready"
+
+	// ConditionMissingCredentials is true if the password secret of the cluster is missing or invalid.
+	ConditionMissingCredentials string = "MissingCredentials"
+
+	// ConditionConstraintsViolated is true if MOCO cannot make the primary writable
+	// because another instance is not read-only.
+	ConditionConstraintsViolated string = "ConstraintsViolated"
+
+	// ConditionPrimaryBinlogDisabled is true if the binary logging is disabled on the primary.
+	ConditionPrimaryBinlogDisabled string = "PrimaryBinlogDisabled"
+
+	// ConditionMultiSourceReplicationDetected is true if an instance has more than one replication channel.
+	ConditionMultiSourceReplicationDetected string = "MultiSourceReplicationDetected"
+
+	// ConditionGTIDAutoPositionDisabled is true if a replica does not use GTID auto-positioning.
+	ConditionGTIDAutoPositionDisabled string = "GTIDAutoPositionDisabled"
+
+	// ConditionCharsetMismatch is true if a replica uses a different character set or collation from the primary.
+	ConditionCharsetMismatch string = "CharsetMismatch"
+
+	// ConditionCloneFailed is true if the last clone operation of any instance failed.
+	ConditionCloneFailed string = "CloneFailed"
+
+	// ConditionAllReplicasDown is true if the primary is available but all replicas are unavailable.
+	ConditionAllReplicasDown string = "AllReplicasDown"
+
+	// ConditionOutOfSync is true if any replica is delayed over `spec.maxReplicationLagSeconds`.
+	ConditionOutOfSync string = "OutOfSync"
+
+	// ConditionErrantReplicasDetected is true if any replica has errant transactions.
+	// Such replicas are never promoted to the primary.
+	ConditionErrantReplicasDetected string = "ErrantReplicasDetected"
+
+	// ConditionInitializing is true while a new cluster is being brought up,
+	// i.e. until the cluster becomes available for the first time.
+	ConditionInitializing string = "Initializing"
+
+	// ConditionWaitTimeout is true if MOCO has been waiting for the cluster to converge
+	// for longer than `spec.maxWaitSeconds`.
+	ConditionWaitTimeout string = "WaitTimeout"
 )
 
+// InstanceStatus represents the observed status of an instance.
+type InstanceStatus struct {
+	// Index is the index of the instance.
+	Index int `json:"index"`
+
+	// Available is true if MOCO could connect to `mysqld` of the instance and gather its status.
+	Available bool `json:"available"`
+
+	// Primary is true if the instance is the current primary.
+	// +optional
+	Primary bool `json:"primary,omitempty"`
+
+	// ReplicationConfigHash is the hash of the replication source, i.e. the host, port, and user,
+	// that MOCO configured last for the instance as a replica.
+	// +optional
+	ReplicationConfigHash string `json:"replicationConfigHash,omitempty"`
+
+	// Recovering is true if InnoDB of the instance is rolling back transactions recovered
+	// by the crash recovery.  The instance accepts connections, but MOCO does not operate it
+	// until the rollback completes.
+	// +optional
+	Recovering bool `json:"recovering,omitempty"`
+
+	// SQLDelaySeconds is `SQL_Delay` of the replica, i.e. the delay configured by `MASTER_DELAY`.
+	// A replica with a positive value lags behind the primary intentionally.
+	// +optional
+	SQLDelaySeconds int32 `json:"sqlDelaySeconds,omitempty"`
+
+	// SQLRemainingDelaySeconds is `SQL_Remaining_Delay` of the replica, i.e. the number of
+	// seconds left until the SQL thread applies the event delayed by `MASTER_DELAY`.
+	// This is nil if the SQL thread is not waiting for the delay.
+	// +optional
+	SQLRemainingDelaySeconds *int32 `json:"sqlRemainingDelaySeconds,omitempty"`
+}
+
+// InstanceCloneStatus represents the last completed clone operation of an instance.
+type InstanceCloneStatus struct {
+	// Index is the index of the instance.
+	Index int `json:"index"`
+
+	// Source is the address of the clone source.
+	Source string `json:"source"`
+
+	// EndTime is the time when the clone operation completed.
+	// +nullable
+	EndTime metav1.Time `json:"endTime"`
+}
+
+// ReplicaRecoveryStatus represents the attempts to recover a replica from
+// a transient error of the replication SQL thread.
+type ReplicaRecoveryStatus struct {
+	// Index is the index of the instance.
+	Index int `json:"index"`
+
+	// Attempts is the number of times MOCO has reset the replication of the instance.
+	Attempts int `json:"attempts"`
+
+	// LastSQLErrno is `Last_SQL_Errno` of the instance when MOCO last tried to recover it.
+	LastSQLErrno int `json:"lastSQLErrno"`
+}
+
+// OutOfSyncReplica represents an instance that is not synced with the primary.
+type OutOfSyncReplica struct {
+	// Index is the index of the instance.
+	Index int `json:"index"`
+
+	// Reason is the criterion that classified the instance as out of sync.
+	// One of "unavailable", "errant", "io_error", "sql_error", "high_lag",
+	// "applying_backlog", "gtid_behind", or "not_ready".
+	Reason string `json:"reason"`
+}
+
 // BackupStatus represents the status of the last successful backup.
 type BackupStatus struct {
 	// The time of the backup.  This is used to generate object keys of backup files in a bucket.
@@ -670,6 +1152,7 @@ type ReconcileInfo struct {
 // +kubebuilder:printcolumn:name="Synced replicas",type="integer",JSONPath=".status.syncedReplicas"
 // +kubebuilder:printcolumn:name="Errant replicas",type="integer",JSONPath=".status.errantReplicas"
 // +kubebuilder:printcolumn:name="Last backup",type="string",JSONPath=".status.backup.time"
+// +kubebuilder:printcolumn:name="Requeue reason",type="string",JSONPath=".status.requeueReason",priority=1
 
 // MySQLCluster is the Schema for the mysqlclusters API
 type MySQLCluster struct {
@@ -708,6 +1191,16 @@ func (r *MySQLCluster) ControllerSecretName() string {
 	return fmt.Sprintf("mysql-%s.%s", r.Namespace, r.Name)
 }
 
+// MySQLPort returns the port number on which mysqld accepts client connections.
+func (r *MySQLCluster) MySQLPort() int32 {
+	return r.Spec.mysqlPort()
+}
+
+// ReplicationUser returns the MySQL user with which the replicas connect to the primary.
+func (r *MySQLCluster) ReplicationUser() string {
+	return r.Spec.replicationUser()
+}
+
 // HeadlessServiceName returns the name of Service for StatefulSet.
 func (r *MySQLCluster) HeadlessServiceName() string {
 	return r.PrefixedName()
@@ -2,6 +2,7 @@ package v1beta2_test
 
 import (
 	"context"
+	"strings"
 
 	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
 	"github.com/cybozu-go/moco/pkg/constants"
@@ -160,6 +161,101 @@ var _ = Describe("MySQLCluster Webhook", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should allow valid switchoverBlackoutWindows", func() {
+		r := makeMySQLCluster()
+		r.Spec.SwitchoverBlackoutWindows = []mocov1beta2.BlackoutWindow{{Schedule: "0 9 * * 1-5", DurationSeconds: 8 * 3600}}
+		err := k8sClient.Create(ctx, r)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should deny an invalid schedule of switchoverBlackoutWindows", func() {
+		r := makeMySQLCluster()
+		r.Spec.SwitchoverBlackoutWindows = []mocov1beta2.BlackoutWindow{{Schedule: "hoge fuga", DurationSeconds: 60}}
+		err := k8sClient.Create(ctx, r)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should allow a valid semiSyncWaitForCount", func() {
+		r := makeMySQLCluster()
+		r.Spec.Replicas = 3
+		r.Spec.SemiSyncWaitForCount = pointer.Int32(2)
+		err := k8sClient.Create(ctx, r)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should deny semiSyncWaitForCount greater than replicas - 1", func() {
+		r := makeMySQLCluster()
+		r.Spec.Replicas = 3
+		r.Spec.SemiSyncWaitForCount = pointer.Int32(3)
+		err := k8sClient.Create(ctx, r)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should set the default mysqlPort", func() {
+		r := makeMySQLCluster()
+		err := k8sClient.Create(ctx, r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Spec.MySQLPort).To(BeNumerically("==", constants.MySQLPort))
+	})
+
+	It("should deny invalid mysqlPort", func() {
+		for _, port := range []int32{-1, 65536, constants.MySQLXPort, constants.MySQLAdminPort, constants.AgentPort} {
+			r := makeMySQLCluster()
+			r.Spec.MySQLPort = port
+			err := k8sClient.Create(ctx, r)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("should deny changing mysqlPort", func() {
+		r := makeMySQLCluster()
+		r.Spec.MySQLPort = 13306
+		err := k8sClient.Create(ctx, r)
+		Expect(err).NotTo(HaveOccurred())
+
+		r.Spec.MySQLPort = 3306
+		err = k8sClient.Update(ctx, r)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should deny mysqld container using the custom mysqlPort", func() {
+		r := makeMySQLCluster()
+		r.Spec.MySQLPort = 13306
+		r.Spec.PodTemplate.Spec.Containers[0].WithPorts(corev1ac.ContainerPort().WithContainerPort(13306))
+		err := k8sClient.Create(ctx, r)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should set the default replicationUser", func() {
+		r := makeMySQLCluster()
+		err := k8sClient.Create(ctx, r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Spec.ReplicationUser).To(Equal(constants.ReplicationUser))
+	})
+
+	It("should deny invalid replicationUser", func() {
+		for _, user := range []string{
+			"-repl", "repl$", "repl user", "r'epl", strings.Repeat("r", 33),
+			constants.AdminUser, constants.AgentUser, constants.WritableUser,
+		} {
+			r := makeMySQLCluster()
+			r.Spec.ReplicationUser = user
+			err := k8sClient.Create(ctx, r)
+			Expect(err).To(HaveOccurred(), user)
+		}
+	})
+
+	It("should deny changing replicationUser", func() {
+		r := makeMySQLCluster()
+		r.Spec.ReplicationUser = "tenant_repl"
+		err := k8sClient.Create(ctx, r)
+		Expect(err).NotTo(HaveOccurred())
+
+		r.Spec.ReplicationUser = constants.ReplicationUser
+		err = k8sClient.Update(ctx, r)
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("should deny without mysqld container", func() {
 		r := makeMySQLCluster()
 		r.Spec.PodTemplate.Spec.Containers = nil
@@ -305,6 +401,13 @@ var _ = Describe("MySQLCluster Webhook", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should explain why even replicas are denied", func() {
+		r := makeMySQLCluster()
+		r.Spec.Replicas = 2
+		err := k8sClient.Create(ctx, r)
+		Expect(err).To(MatchError(ContainSubstring("acknowledge each transaction")))
+	})
+
 	It("should deny adding replication source secret", func() {
 		r := makeMySQLCluster()
 		err := k8sClient.Create(ctx, r)
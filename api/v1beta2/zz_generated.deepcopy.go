@@ -144,6 +144,21 @@ func (in *BackupStatus) DeepCopy() *BackupStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlackoutWindow) DeepCopyInto(out *BlackoutWindow) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlackoutWindow.
+func (in *BlackoutWindow) DeepCopy() *BlackoutWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(BlackoutWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BucketConfig) DeepCopyInto(out *BucketConfig) {
 	*out = *in
@@ -171,6 +186,42 @@ func (in *EnvVarApplyConfiguration) DeepCopyInto(out *EnvVarApplyConfiguration)
 	*out = *clone
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceCloneStatus) DeepCopyInto(out *InstanceCloneStatus) {
+	*out = *in
+	in.EndTime.DeepCopyInto(&out.EndTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceCloneStatus.
+func (in *InstanceCloneStatus) DeepCopy() *InstanceCloneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceCloneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceStatus) DeepCopyInto(out *InstanceStatus) {
+	*out = *in
+	if in.SQLRemainingDelaySeconds != nil {
+		in, out := &in.SQLRemainingDelaySeconds, &out.SQLRemainingDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceStatus.
+func (in *InstanceStatus) DeepCopy() *InstanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *JobConfig) DeepCopyInto(out *JobConfig) {
 	*out = *in
@@ -340,6 +391,36 @@ func (in *MySQLClusterSpec) DeepCopyInto(out *MySQLClusterSpec) {
 		*out = new(int)
 		**out = **in
 	}
+	if in.EnsureTables != nil {
+		in, out := &in.EnsureTables, &out.EnsureTables
+		*out = make([]TableSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequireGTIDAutoPosition != nil {
+		in, out := &in.RequireGTIDAutoPosition, &out.RequireGTIDAutoPosition
+		*out = new(bool)
+		**out = **in
+	}
+	if in.FailoverTieBreakers != nil {
+		in, out := &in.FailoverTieBreakers, &out.FailoverTieBreakers
+		*out = make([]FailoverTieBreaker, len(*in))
+		copy(*out, *in)
+	}
+	if in.SemiSyncTimeoutSeconds != nil {
+		in, out := &in.SemiSyncTimeoutSeconds, &out.SemiSyncTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SemiSyncWaitForCount != nil {
+		in, out := &in.SemiSyncWaitForCount, &out.SemiSyncWaitForCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SwitchoverBlackoutWindows != nil {
+		in, out := &in.SwitchoverBlackoutWindows, &out.SwitchoverBlackoutWindows
+		*out = make([]BlackoutWindow, len(*in))
+		copy(*out, *in)
+	}
 	if in.BackupPolicyName != nil {
 		in, out := &in.BackupPolicyName, &out.BackupPolicyName
 		*out = new(string)
@@ -372,16 +453,66 @@ func (in *MySQLClusterStatus) DeepCopyInto(out *MySQLClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PrimarySince != nil {
+		in, out := &in.PrimarySince, &out.PrimarySince
+		*out = (*in).DeepCopy()
+	}
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]InstanceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.ErrantReplicaList != nil {
 		in, out := &in.ErrantReplicaList, &out.ErrantReplicaList
 		*out = make([]int, len(*in))
 		copy(*out, *in)
 	}
+	if in.OutOfSyncReplicas != nil {
+		in, out := &in.OutOfSyncReplicas, &out.OutOfSyncReplicas
+		*out = make([]OutOfSyncReplica, len(*in))
+		copy(*out, *in)
+	}
+	if in.SemiSyncAcknowledgers != nil {
+		in, out := &in.SemiSyncAcknowledgers, &out.SemiSyncAcknowledgers
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
 	in.Backup.DeepCopyInto(&out.Backup)
 	if in.RestoredTime != nil {
 		in, out := &in.RestoredTime, &out.RestoredTime
 		*out = (*in).DeepCopy()
 	}
+	if in.Clones != nil {
+		in, out := &in.Clones, &out.Clones
+		*out = make([]InstanceCloneStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastReCloneTime != nil {
+		in, out := &in.LastReCloneTime, &out.LastReCloneTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ReplicaRecoveries != nil {
+		in, out := &in.ReplicaRecoveries, &out.ReplicaRecoveries
+		*out = make([]ReplicaRecoveryStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.WaitingSince != nil {
+		in, out := &in.WaitingSince, &out.WaitingSince
+		*out = (*in).DeepCopy()
+	}
+	if in.DeferredOperations != nil {
+		in, out := &in.DeferredOperations, &out.DeferredOperations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
 	out.ReconcileInfo = in.ReconcileInfo
 }
 
@@ -424,6 +555,21 @@ func (in *ObjectMeta) DeepCopy() *ObjectMeta {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutOfSyncReplica) DeepCopyInto(out *OutOfSyncReplica) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutOfSyncReplica.
+func (in *OutOfSyncReplica) DeepCopy() *OutOfSyncReplica {
+	if in == nil {
+		return nil
+	}
+	out := new(OutOfSyncReplica)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OverwriteContainer) DeepCopyInto(out *OverwriteContainer) {
 	*out = *in
@@ -511,6 +657,21 @@ func (in *ReconcileInfo) DeepCopy() *ReconcileInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaRecoveryStatus) DeepCopyInto(out *ReplicaRecoveryStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaRecoveryStatus.
+func (in *ReplicaRecoveryStatus) DeepCopy() *ReplicaRecoveryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaRecoveryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceRequirementsApplyConfiguration) DeepCopyInto(out *ResourceRequirementsApplyConfiguration) {
 	clone := in.DeepCopy()
@@ -560,6 +721,21 @@ func (in *ServiceTemplate) DeepCopy() *ServiceTemplate {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TableSpec) DeepCopyInto(out *TableSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TableSpec.
+func (in *TableSpec) DeepCopy() *TableSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TableSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeApplyConfiguration) DeepCopyInto(out *VolumeApplyConfiguration) {
 	clone := in.DeepCopy()
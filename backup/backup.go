@@ -133,13 +133,19 @@ func (bm *BackupManager) Backup(ctx context.Context) error {
 	bm.sourceIndex = sourceIndex
 
 	op, err := newOperator(orderedPods[sourceIndex].Status.PodIP,
-		constants.MySQLPort, constants.BackupUser, bm.mysqlPassword, bm.threads)
+		int(bm.cluster.MySQLPort()), constants.BackupUser, bm.mysqlPassword, bm.threads)
 	if err != nil {
 		return fmt.Errorf("failed to create operator: %w", err)
 	}
 	defer op.Close()
 
 	if err := op.GetServerStatus(ctx, &bm.status); err != nil {
+		if errors.Is(err, bkop.ErrBinlogDisabled) {
+			ev := event.BackupBinlogDisabled.ToEvent(bm.clusterRef, sourceIndex)
+			if err := bm.client.Create(ctx, ev); err != nil {
+				bm.log.Error(err, "failed to create an event for binlog-disabled")
+			}
+		}
 		return fmt.Errorf("failed to get server status: %w", err)
 	}
 
@@ -209,7 +215,7 @@ func (bm *BackupManager) GetUUIDSet(ctx context.Context, pods []*corev1.Pod) (ma
 	for i := range pods {
 		if podIsReady(pods[i]) {
 			op, err := newOperator(cluster.PodHostname(i),
-				constants.MySQLPort,
+				int(cluster.MySQLPort()),
 				constants.BackupUser,
 				bm.mysqlPassword,
 				bm.threads)
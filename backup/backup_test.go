@@ -20,7 +20,7 @@ type getUUIDSetMockOp struct {
 	uuid   string
 }
 
-func (o *getUUIDSetMockOp) Ping() error {
+func (o *getUUIDSetMockOp) Ping(_ context.Context) error {
 	panic("not implemented")
 }
 
@@ -10,6 +10,7 @@ import (
 	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
 	"github.com/cybozu-go/moco/pkg/bkop"
 	"github.com/cybozu-go/moco/pkg/constants"
+	"github.com/cybozu-go/moco/pkg/event"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
@@ -352,4 +353,29 @@ var _ = Describe("Backup/Restore", func() {
 		bs := &cluster.Status.Backup
 		Expect(bs.Warnings).NotTo(BeEmpty())
 	})
+
+	It("should record that the binary logging is disabled", func() {
+		newOperator = func(host string, port int, user, password string, threads int) (bkop.Operator, error) {
+			op := &mockOperator{
+				uuid:     "123",
+				gtid:     "gtid1",
+				noBinlog: true,
+			}
+			ops = append(ops, op)
+			return op, nil
+		}
+
+		bm, err := NewBackupManager(cfg, bc, workDir, "test", "single", "", 3)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = bm.Backup(ctx)
+		Expect(err).To(MatchError(bkop.ErrBinlogDisabled))
+		Expect(bc.contents).To(BeEmpty())
+
+		events := &corev1.EventList{}
+		err = k8sClient.List(ctx, events, client.InNamespace("test"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events.Items).To(HaveLen(1))
+		Expect(events.Items[0].Reason).To(Equal(event.BackupBinlogDisabled.Reason))
+	})
 })
@@ -21,6 +21,7 @@ type mockOperator struct {
 	uuid       string
 	gtid       string
 	expectPiTR bool
+	noBinlog   bool
 
 	// status
 	alive    bool
@@ -33,7 +34,7 @@ type mockOperator struct {
 
 var _ bkop.Operator = &mockOperator{}
 
-func (o *mockOperator) Ping() error {
+func (o *mockOperator) Ping(_ context.Context) error {
 	if !o.alive {
 		o.alive = true
 		return errors.New("not alive")
@@ -46,6 +47,9 @@ func (o *mockOperator) Close() {
 }
 
 func (o *mockOperator) GetServerStatus(_ context.Context, st *bkop.ServerStatus) error {
+	if o.noBinlog {
+		return bkop.ErrBinlogDisabled
+	}
 	st.CurrentBinlog = o.binlogs[len(o.binlogs)-1]
 	st.UUID = o.uuid
 	st.SuperReadOnly = !o.writable
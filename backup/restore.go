@@ -79,8 +79,9 @@ func NewRestoreManager(cfg *rest.Config, bc bucket.Bucket, dir, srcNS, srcName,
 
 func (rm *RestoreManager) Restore(ctx context.Context) error {
 	cluster := &mocov1beta2.MySQLCluster{}
-	cluster.Namespace = rm.namespace
-	cluster.Name = rm.name
+	if err := rm.client.Get(ctx, client.ObjectKey{Namespace: rm.namespace, Name: rm.name}, cluster); err != nil {
+		return fmt.Errorf("failed to get MySQLCluster: %w", err)
+	}
 	podName := cluster.PodName(0)
 
 	rm.log.Info("waiting for a pod to become ready", "name", podName)
@@ -102,7 +103,7 @@ func (rm *RestoreManager) Restore(ctx context.Context) error {
 		}
 	}
 
-	op, err := newOperator(pod.Status.PodIP, constants.MySQLPort, constants.AdminUser, rm.password, rm.threads)
+	op, err := newOperator(pod.Status.PodIP, int(cluster.MySQLPort()), constants.AdminUser, rm.password, rm.threads)
 	if err != nil {
 		return fmt.Errorf("failed to create an operator: %w", err)
 	}
@@ -117,7 +118,7 @@ func (rm *RestoreManager) Restore(ctx context.Context) error {
 			return ctx.Err()
 		}
 
-		if err := op.Ping(); err != nil {
+		if err := op.Ping(ctx); err != nil {
 			continue
 		}
 		st := &bkop.ServerStatus{}
@@ -0,0 +1,79 @@
+package clustering
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors.  To test these errors, use `errors.Is`.
+var (
+	// errMissingCredentials is returned when the password secret of a cluster is missing or invalid.
+	errMissingCredentials = errors.New("missing credentials")
+
+	// errConstraintsViolation is returned when making the primary writable would let
+	// more than one instance accept writes.
+	errConstraintsViolation = errors.New("constraints violation")
+
+	// errAllInstancesExcluded is returned if `moco.cybozu.com/exclude-from-primary` annotation
+	// excludes every instance of the cluster.
+	errAllInstancesExcluded = errors.New("all instances are excluded from the primary")
+)
+
+// Machine-readable reasons of clusterError.
+// These are used as `Reason` of conditions, so they must be in CamelCase.
+const (
+	reasonCredentialsNotFound  = "CredentialsNotFound"
+	reasonWritableReplicas     = "WritableReplicas"
+	reasonAllInstancesExcluded = "AllInstancesExcluded"
+)
+
+// clusterError is an error that tells why MOCO cannot maintain a cluster.
+// In addition to the human-readable message, it carries a machine-readable reason
+// and the indices of the instances concerned, if any.
+//
+// `errors.Is(err, kind)` returns true for the sentinel error `kind` given to newClusterError.
+type clusterError struct {
+	kind      error
+	reason    string
+	instances []int
+	err       error
+}
+
+// newClusterError returns a clusterError of `kind` with `reason`.
+// `err` describes the details and may be nil.
+func newClusterError(kind error, reason string, err error, instances ...int) *clusterError {
+	return &clusterError{
+		kind:      kind,
+		reason:    reason,
+		instances: instances,
+		err:       err,
+	}
+}
+
+func (e *clusterError) Error() string {
+	msg := e.kind.Error()
+	if len(e.instances) > 0 {
+		msg = fmt.Sprintf("%s: instances %v", msg, e.instances)
+	}
+	if e.err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.err)
+	}
+	return msg
+}
+
+func (e *clusterError) Unwrap() []error {
+	if e.err == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.err}
+}
+
+// errorReason returns the reason of the clusterError in the chain of `err`.
+// If there is none, `fallback` is returned.
+func errorReason(err error, fallback string) string {
+	var cerr *clusterError
+	if errors.As(err, &cerr) {
+		return cerr.reason
+	}
+	return fallback
+}
@@ -0,0 +1,42 @@
+package clustering
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestClusterError(t *testing.T) {
+	cerr := newClusterError(errConstraintsViolation, reasonWritableReplicas, os.ErrPermission, 1, 2)
+	wrapped := fmt.Errorf("failed to configure: %w", cerr)
+
+	if !errors.Is(wrapped, errConstraintsViolation) {
+		t.Error("the error should be errConstraintsViolation")
+	}
+	if !errors.Is(wrapped, os.ErrPermission) {
+		t.Error("the underlying error should be unwrapped")
+	}
+	if errors.Is(wrapped, errMissingCredentials) {
+		t.Error("the error should not be errMissingCredentials")
+	}
+
+	expected := "failed to configure: constraints violation: instances [1 2]: permission denied"
+	if msg := wrapped.Error(); msg != expected {
+		t.Errorf("unexpected message: %s", msg)
+	}
+	if reason := errorReason(wrapped, "Failed"); reason != reasonWritableReplicas {
+		t.Errorf("unexpected reason: %s", reason)
+	}
+	if reason := errorReason(errors.New("unknown"), "Failed"); reason != "Failed" {
+		t.Errorf("unexpected reason for an unknown error: %s", reason)
+	}
+
+	cerr = newClusterError(errMissingCredentials, reasonCredentialsNotFound, nil)
+	if msg := cerr.Error(); msg != "missing credentials" {
+		t.Errorf("unexpected message: %s", msg)
+	}
+	if !errors.Is(cerr, errMissingCredentials) {
+		t.Error("the error should be errMissingCredentials")
+	}
+}
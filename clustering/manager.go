@@ -29,16 +29,20 @@ type ClusterManager interface {
 	StopAll()
 }
 
-func NewClusterManager(interval time.Duration, m manager.Manager, opf dbop.OperatorFactory, af AgentFactory, log logr.Logger) ClusterManager {
+// NewClusterManager returns a new ClusterManager.
+// `webhookHosts` is the list of hosts to which `spec.failoverWebhookURL` is allowed to point.
+// If it is empty, no notifications are sent to the webhooks.
+func NewClusterManager(interval time.Duration, m manager.Manager, opf dbop.OperatorFactory, af AgentFactory, webhookHosts []string, log logr.Logger) ClusterManager {
 	return &clusterManager{
-		client:    m.GetClient(),
-		reader:    m.GetAPIReader(),
-		recorder:  m.GetEventRecorderFor("moco-controller"),
-		dbf:       opf,
-		agentf:    af,
-		interval:  interval,
-		log:       log,
-		processes: make(map[string]*managerProcess),
+		client:       m.GetClient(),
+		reader:       m.GetAPIReader(),
+		recorder:     m.GetEventRecorderFor("moco-controller"),
+		dbf:          opf,
+		agentf:       af,
+		webhookHosts: webhookHosts,
+		interval:     interval,
+		log:          log,
+		processes:    make(map[string]*managerProcess),
 	}
 }
 
@@ -53,6 +57,8 @@ type clusterManager struct {
 	interval time.Duration
 	log      logr.Logger
 
+	webhookHosts []string
+
 	mu        sync.Mutex
 	processes map[string]*managerProcess
 	stopped   bool
@@ -88,10 +94,12 @@ func (m *clusterManager) update(name types.NamespacedName, noStart bool, origin
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	p = newManagerProcess(m.client, m.reader, m.recorder, m.dbf, m.agentf, name, cancel)
+	p = newManagerProcess(m.client, m.reader, m.recorder, m.dbf, m.agentf, m.webhookHosts, name, cancel)
 	m.wg.Add(1)
 	go func() {
 		p.Start(ctx, m.log.WithName(key), m.interval)
+		// the connection pools are no longer used once the process stops.
+		m.dbf.RemoveCluster(name.Namespace, name.Name, 0)
 		m.wg.Done()
 	}()
 	m.processes[key] = p
@@ -113,8 +113,11 @@ var _ = Describe("manager", func() {
 		ms.healthy = metrics.HealthyVec.WithLabelValues("test", "test")
 		ms.switchoverCount = metrics.SwitchoverCountVec.WithLabelValues("test", "test")
 		ms.failoverCount = metrics.FailoverCountVec.WithLabelValues("test", "test")
+		ms.primaryIndex = metrics.PrimaryIndexVec.WithLabelValues("test", "test")
 		ms.replicas = metrics.TotalReplicasVec.WithLabelValues("test", "test")
 		ms.readyReplicas = metrics.ReadyReplicasVec.WithLabelValues("test", "test")
+		ms.syncedReplicas = metrics.SyncedReplicasVec.WithLabelValues("test", "test")
+		ms.desiredReplicas = metrics.DesiredReplicasVec.WithLabelValues("test", "test")
 		ms.errantReplicas = metrics.ErrantReplicasVec.WithLabelValues("test", "test")
 		ms.backupTimestamp = metrics.BackupTimestamp.WithLabelValues("test", "test")
 		ms.backupElapsed = metrics.BackupElapsed.WithLabelValues("test", "test")
@@ -168,7 +171,7 @@ var _ = Describe("manager", func() {
 	It("should setup one-instance cluster and clean up metrics when the cluster is deleted", func() {
 		testSetupResources(ctx, 1, "")
 
-		cm := NewClusterManager(1*time.Second, mgr, of, af, stdr.New(nil))
+		cm := NewClusterManager(1*time.Second, mgr, of, af, nil, stdr.New(nil))
 		defer cm.StopAll()
 
 		cluster, err := testGetCluster(ctx)
@@ -219,7 +222,10 @@ var _ = Describe("manager", func() {
 		Expect(ms.healthy).To(MetricsIs("==", 1))
 		Expect(ms.replicas).To(MetricsIs("==", 1))
 		Expect(ms.readyReplicas).To(MetricsIs("==", 1))
+		Expect(ms.syncedReplicas).To(MetricsIs("==", 1))
+		Expect(ms.desiredReplicas).To(MetricsIs("==", 1))
 		Expect(ms.errantReplicas).To(MetricsIs("==", 0))
+		Expect(ms.primaryIndex).To(MetricsIs("==", 0))
 
 		By("set the instance 0 failing")
 		of.setFailing(cluster.PodHostname(0), true)
@@ -254,11 +260,22 @@ var _ = Describe("manager", func() {
 		time.Sleep(400 * time.Millisecond)
 		of.setFailing(cluster.PodHostname(0), false)
 
+		Eventually(func(g Gomega) {
+			replicas, ok := of.getRemovedCluster(cluster.Namespace, cluster.Name)
+			g.Expect(ok).To(BeTrue())
+			g.Expect(replicas).To(Equal(0))
+		}).Should(Succeed())
+
 		Eventually(func(g Gomega) {
 			ch := make(chan prometheus.Metric, 2)
 			metrics.ErrantReplicasVec.Collect(ch)
 			g.Expect(ch).NotTo(Receive())
 		}).Should(Succeed())
+		Eventually(func(g Gomega) {
+			ch := make(chan prometheus.Metric, 2)
+			metrics.PrimaryIndexVec.Collect(ch)
+			g.Expect(ch).NotTo(Receive())
+		}).Should(Succeed())
 
 		cluster, err = testGetCluster(ctx)
 		Expect(err).NotTo(HaveOccurred())
@@ -270,7 +287,7 @@ var _ = Describe("manager", func() {
 	It("should manage an intermediate primary, switchover, and scaling out the cluster", func() {
 		testSetupResources(ctx, 1, "source")
 
-		cm := NewClusterManager(1*time.Second, mgr, of, af, stdr.New(nil))
+		cm := NewClusterManager(1*time.Second, mgr, of, af, nil, stdr.New(nil))
 		defer cm.StopAll()
 
 		cluster, err := testGetCluster(ctx)
@@ -429,6 +446,8 @@ var _ = Describe("manager", func() {
 		Expect(ms.healthy).To(MetricsIs("==", 1))
 		Expect(ms.replicas).To(MetricsIs("==", 3))
 		Expect(ms.readyReplicas).To(MetricsIs("==", 3))
+		Expect(ms.syncedReplicas).To(MetricsIs("==", 3))
+		Expect(ms.desiredReplicas).To(MetricsIs("==", 3))
 
 		st0 := of.getInstanceStatus(cluster.PodHostname(0))
 		Expect(st0).NotTo(BeNil())
@@ -526,6 +545,7 @@ var _ = Describe("manager", func() {
 		Expect(ms.errantReplicas).To(MetricsIs("==", 0))
 		Expect(ms.switchoverCount).To(MetricsIs("==", 1))
 		Expect(ms.failoverCount).To(MetricsIs("==", 0))
+		Expect(ms.primaryIndex).To(MetricsIs("==", float64(newPrimary)))
 
 		for i := 0; i < 3; i++ {
 			st := of.getInstanceStatus(cluster.PodHostname(i))
@@ -602,7 +622,7 @@ var _ = Describe("manager", func() {
 	It("should handle failover", func() {
 		testSetupResources(ctx, 3, "")
 
-		cm := NewClusterManager(1*time.Second, mgr, of, af, stdr.New(nil))
+		cm := NewClusterManager(1*time.Second, mgr, of, af, nil, stdr.New(nil))
 		defer cm.StopAll()
 
 		cluster, err := testGetCluster(ctx)
@@ -684,6 +704,7 @@ var _ = Describe("manager", func() {
 		st1 := of.getInstanceStatus(cluster.PodHostname(1))
 		Expect(st1.GlobalVariables.ExecutedGTID).To(Equal("p0:1,p0:2,p0:3")) // confirm that MOCO waited fot the retrieved GTID set to be executed
 		Expect(st1.GlobalVariables.ReadOnly).To(BeFalse())
+		Expect(of.getRemoved()).To(ContainElement("0.0.0.0")) // the connections to the old primary are dropped
 
 		Expect(cluster.Status.ErrantReplicas).To(Equal(0))
 		Expect(cluster.Status.ErrantReplicaList).To(BeEmpty())
@@ -694,6 +715,7 @@ var _ = Describe("manager", func() {
 		Expect(ms.errantReplicas).To(MetricsIs("==", 0))
 		Expect(ms.switchoverCount).To(MetricsIs("==", 0))
 		Expect(ms.failoverCount).To(MetricsIs("==", 1))
+		Expect(ms.primaryIndex).To(MetricsIs("==", 1))
 
 		events := &corev1.EventList{}
 		err = k8sClient.List(ctx, events, client.InNamespace("test"))
@@ -762,7 +784,7 @@ var _ = Describe("manager", func() {
 	It("should handle errant replicas and lost", func() {
 		testSetupResources(ctx, 5, "")
 
-		cm := NewClusterManager(1*time.Second, mgr, of, af, stdr.New(nil))
+		cm := NewClusterManager(1*time.Second, mgr, of, af, nil, stdr.New(nil))
 		defer cm.StopAll()
 
 		cluster, err := testGetCluster(ctx)
@@ -978,12 +1000,41 @@ var _ = Describe("manager", func() {
 			g.Expect(condHealthy.Status).To(Equal(metav1.ConditionFalse))
 			g.Expect(condHealthy.Reason).To(Equal(StateLost.String()))
 		}).Should(Succeed())
+		Expect(cluster.Status.CurrentPrimaryIndex).To(Equal(3))
+
+		By("forcing a failover of the lost cluster")
+		newCluster := cluster.DeepCopy()
+		if newCluster.Annotations == nil {
+			newCluster.Annotations = make(map[string]string)
+		}
+		newCluster.Annotations[constants.AnnForceFailover] = "true"
+		err = k8sClient.Patch(ctx, newCluster, client.MergeFrom(cluster))
+		Expect(err).NotTo(HaveOccurred())
+
+		// instance 4 is the most advanced among the reachable replicas.
+		Eventually(func(g Gomega) {
+			cluster, err = testGetCluster(ctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(cluster.Status.CurrentPrimaryIndex).To(Equal(4), "the primary is not switched yet")
+			g.Expect(cluster.Annotations).NotTo(HaveKey(constants.AnnForceFailover))
+		}).Should(Succeed())
+
+		events = &corev1.EventList{}
+		err = k8sClient.List(ctx, events, client.InNamespace("test"))
+		Expect(err).NotTo(HaveOccurred())
+		var forcedEvents int
+		for _, ev := range events.Items {
+			if ev.Reason == event.FailOverForced.Reason {
+				forcedEvents++
+			}
+		}
+		Expect(forcedEvents).To(Equal(1))
 	})
 
 	It("should export backup related metrics", func() {
 		testSetupResources(ctx, 1, "")
 
-		cm := NewClusterManager(1*time.Second, mgr, of, af, stdr.New(nil))
+		cm := NewClusterManager(1*time.Second, mgr, of, af, nil, stdr.New(nil))
 		defer cm.StopAll()
 
 		var cluster *mocov1beta2.MySQLCluster
@@ -236,13 +236,16 @@ func (o *mockOperator) ConfigureReplica(ctx context.Context, source dbop.AccessI
 	if _, ok := hostnames[source.Host]; !ok {
 		return fmt.Errorf("configureReplica: wrong host: %s", source.Host)
 	}
+	if source.Host != "external" && source.Port != int(o.cluster.MySQLPort()) {
+		return fmt.Errorf("configureReplica: wrong port: %d", source.Port)
+	}
 
 	var validPasswd string
 	switch source.User {
 	case "external-donor":
 		validPasswd = "p1"
-	case "moco-repl":
-		validPasswd = mysqlPassword.Replicator()
+	case o.cluster.ReplicationUser():
+		validPasswd, _ = mysqlPassword.ReplicatorOf(source.User)
 	default:
 		return fmt.Errorf("configureReplica: invalid replication user: %s", source.User)
 	}
@@ -293,24 +296,33 @@ func (o *mockOperator) ConfigureReplica(ctx context.Context, source dbop.AccessI
 	gtid, _ := testGetGTID(source.Host)
 	o.mysql.status.ReplicaStatus = &dbop.ReplicaStatus{
 		MasterHost:       source.Host,
+		MasterPort:       source.Port,
+		MasterUser:       source.User,
 		RetrievedGtidSet: gtid,
 		SlaveIORunning:   "Yes",
 		SlaveSQLRunning:  "Yes",
+		AutoPosition:     "1",
 	}
 	o.mysql.status.GlobalVariables.SemiSyncSlaveEnabled = semisync
+	o.mysql.status.SemiSyncSlaveActive = semisync
 	return setPodReadiness(ctx, o.cluster.PodName(o.index), true)
 }
 
 // ConfigurePrimary configures server-side semi-synchronous replication.
-// For asynchronous replication, this method should not be called.
-func (o *mockOperator) ConfigurePrimary(ctx context.Context, waitForCount int) error {
+// If `waitForCount` is zero, it disables server-side semi-synchronous replication.
+func (o *mockOperator) ConfigurePrimary(ctx context.Context, waitForCount, timeoutMilliseconds int) error {
 	if o.failing {
 		return errors.New("mysqld is down")
 	}
 	o.mysql.mu.Lock()
 	defer o.mysql.mu.Unlock()
 
+	if waitForCount == 0 {
+		o.mysql.status.GlobalVariables.SemiSyncMasterEnabled = false
+		return nil
+	}
 	o.mysql.status.GlobalVariables.WaitForSlaveCount = waitForCount
+	o.mysql.status.GlobalVariables.SemiSyncMasterTimeout = timeoutMilliseconds
 	o.mysql.status.GlobalVariables.SemiSyncMasterEnabled = true
 	return nil
 }
@@ -330,6 +342,56 @@ func (o *mockOperator) StopReplicaIOThread(ctx context.Context) error {
 	return setPodReadiness(ctx, o.cluster.PodName(o.index), false)
 }
 
+// StartReplicaSQLThread executes `START SLAVE SQL_THREAD`.
+func (o *mockOperator) StartReplicaSQLThread(ctx context.Context) error {
+	if o.failing {
+		return errors.New("mysqld is down")
+	}
+	o.mysql.mu.Lock()
+	defer o.mysql.mu.Unlock()
+
+	if o.mysql.status.ReplicaStatus == nil {
+		return nil
+	}
+	o.mysql.status.ReplicaStatus.SlaveSQLRunning = "Yes"
+	return nil
+}
+
+// StartReplicaIOThread executes `START SLAVE IO_THREAD`.
+func (o *mockOperator) StartReplicaIOThread(ctx context.Context) error {
+	if o.failing {
+		return errors.New("mysqld is down")
+	}
+	o.mysql.mu.Lock()
+	defer o.mysql.mu.Unlock()
+
+	if o.mysql.status.ReplicaStatus == nil {
+		return nil
+	}
+	o.mysql.status.ReplicaStatus.SlaveIORunning = "Yes"
+	o.mysql.status.ReplicaStatus.LastIoErrno = 0
+	return nil
+}
+
+// ResetReplica executes `STOP SLAVE`, `RESET SLAVE`, and `START SLAVE`.
+func (o *mockOperator) ResetReplica(ctx context.Context) error {
+	if o.failing {
+		return errors.New("mysqld is down")
+	}
+	o.mysql.mu.Lock()
+	defer o.mysql.mu.Unlock()
+
+	rs := o.mysql.status.ReplicaStatus
+	if rs == nil {
+		return nil
+	}
+	rs.SlaveIORunning = "Yes"
+	rs.SlaveSQLRunning = "Yes"
+	rs.LastIoErrno = 0
+	rs.LastSQLErrno = 0
+	return nil
+}
+
 // WaitForGTID waits for `mysqld` to execute all GTIDs in `gtidSet`.
 // If timeout happens, this return ErrTimeout.
 // If `timeoutSeconds` is zero, this will not timeout.
@@ -415,6 +477,38 @@ func (o *mockOperator) KillConnections(ctx context.Context) error {
 	return nil
 }
 
+func (o *mockOperator) GetDataSize(ctx context.Context) (int64, error) {
+	if o.failing {
+		return 0, errors.New("mysqld is down")
+	}
+	return 0, nil
+}
+
+func (o *mockOperator) CheckHealth(ctx context.Context, query string) (bool, error) {
+	if o.failing {
+		return false, errors.New("mysqld is down")
+	}
+	return true, nil
+}
+
+func (o *mockOperator) EnsureTable(ctx context.Context, database, table, definition string) error {
+	if o.failing {
+		return errors.New("mysqld is down")
+	}
+	return nil
+}
+
+func (o *mockOperator) EnsureReplicationUser(ctx context.Context, user, password string) error {
+	if o.failing {
+		return errors.New("mysqld is down")
+	}
+	return nil
+}
+
+func (o *mockOperator) CommandCounts() dbop.CommandCounts {
+	return dbop.CommandCounts{}
+}
+
 type mockMySQL struct {
 	mu     sync.Mutex
 	status dbop.MySQLInstanceStatus
@@ -454,6 +548,8 @@ type mockOpFactory struct {
 	mysqls               map[string]*mockMySQL
 	failing              map[string]bool
 	countKillConnections map[string]int
+	removed              []string
+	removedClusters      map[string]int
 }
 
 func newMockOpFactory() *mockOpFactory {
@@ -461,6 +557,7 @@ func newMockOpFactory() *mockOpFactory {
 		mysqls:               make(map[string]*mockMySQL),
 		failing:              make(map[string]bool),
 		countKillConnections: make(map[string]int),
+		removedClusters:      make(map[string]int),
 	}
 }
 
@@ -478,6 +575,7 @@ func (f *mockOpFactory) New(ctx context.Context, cluster *mocov1beta2.MySQLClust
 		m.status.GlobalVariables.UUID = fmt.Sprintf("p%d", index)
 		m.status.GlobalVariables.ReadOnly = true
 		m.status.GlobalVariables.SuperReadOnly = true
+		m.status.GlobalVariables.LogBin = true
 		f.mysqls[hostname] = m
 	}
 	return &mockOperator{
@@ -491,6 +589,31 @@ func (f *mockOpFactory) New(ctx context.Context, cluster *mocov1beta2.MySQLClust
 	}, nil
 }
 
+func (f *mockOpFactory) Remove(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removed = append(f.removed, host)
+}
+
+func (f *mockOpFactory) RemoveCluster(namespace, name string, replicas int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removedClusters[namespace+"/"+name] = replicas
+}
+
+func (f *mockOpFactory) getRemovedCluster(namespace, name string) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	replicas, ok := f.removedClusters[namespace+"/"+name]
+	return replicas, ok
+}
+
+func (f *mockOpFactory) getRemoved() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.removed...)
+}
+
 func (f *mockOpFactory) Cleanup() {}
 
 func (f *mockOpFactory) getInstance(name string) *mockMySQL {
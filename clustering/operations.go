@@ -2,8 +2,15 @@ package clustering
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"time"
 
@@ -12,8 +19,12 @@ import (
 	"github.com/cybozu-go/moco/pkg/constants"
 	"github.com/cybozu-go/moco/pkg/dbop"
 	"github.com/cybozu-go/moco/pkg/event"
+	"github.com/go-logr/logr"
 	"google.golang.org/protobuf/types/known/durationpb"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -21,6 +32,11 @@ import (
 const (
 	switchOverTimeoutSeconds = 70
 	failOverTimeoutSeconds   = 3600
+
+	// fenceTimeout is the timeout to fence the old primary.
+	// The old primary is likely to be unresponsive in a failover,
+	// so this should be short not to delay the promotion.
+	fenceTimeout = 5 * time.Second
 )
 
 var (
@@ -60,7 +76,7 @@ func (p *managerProcess) isCloning(ctx context.Context, ss *StatusSet) bool {
 		return true
 	}
 	if pst.CloneStatus != nil && pst.CloneStatus.State.String != "Failed" {
-		log.Info("cloning...", "state", pst.CloneStatus.State.String)
+		log.Info("cloning...", "state", pst.CloneStatus.State.String, "progress", fmt.Sprintf("%.1f%%", pst.CloneStatus.EstimatedProgress))
 		return true
 	}
 	return false
@@ -149,6 +165,7 @@ func (p *managerProcess) clone(ctx context.Context, ss *StatusSet) (bool, error)
 func (p *managerProcess) switchover(ctx context.Context, ss *StatusSet) error {
 	log := logFromContext(ctx)
 	log.Info("begin switchover the primary", "current", ss.Primary, "next", ss.Candidate)
+	p.warnConnectionHeadroom(ctx, ss, ss.Candidate)
 
 	pdb := ss.DBOps[ss.Primary]
 	if err := pdb.SetReadOnly(ctx, true); err != nil {
@@ -164,6 +181,17 @@ func (p *managerProcess) switchover(ctx context.Context, ss *StatusSet) error {
 	}
 
 	err = ss.DBOps[ss.Candidate].WaitForGTID(ctx, pst.GlobalVariables.ExecutedGTID, switchOverTimeoutSeconds)
+	if err != nil && ss.PlannedSwitchover {
+		// abort the planned switchover and keep the old primary serving writes.
+		log.Error(err, "abort the switchover as the target did not catch up", "target", ss.Candidate)
+		if err2 := pdb.SetReadOnly(ctx, false); err2 != nil {
+			return fmt.Errorf("failed to make instance %d writable again: %w", ss.Primary, err2)
+		}
+		if err2 := p.removeAnnotation(ctx, ss, constants.AnnSwitchover); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("instance %d did not catch up with the primary: %w", ss.Candidate, err)
+	}
 	if err != nil {
 		return err
 	}
@@ -174,6 +202,7 @@ func (p *managerProcess) switchover(ctx context.Context, ss *StatusSet) error {
 			return err
 		}
 		cluster.Status.CurrentPrimaryIndex = ss.Candidate
+		cluster.Status.PrimarySince = &metav1.Time{Time: time.Now()}
 		return p.client.Status().Update(ctx, cluster)
 	})
 	if err != nil {
@@ -190,14 +219,133 @@ func (p *managerProcess) switchover(ctx context.Context, ss *StatusSet) error {
 			return fmt.Errorf("failed to remove moco.cybozu.com/demote annotation: %w", err)
 		}
 	}
+	if err := p.removeAnnotation(ctx, ss, constants.AnnSwitchover); err != nil {
+		return err
+	}
 	log.Info("switchover finished", "primary", ss.Candidate)
 	return nil
 }
 
+// removeAnnotation removes annotation `key` such as `moco.cybozu.com/switchover` from the MySQLCluster
+// to mark the requested operation as done or rejected.
+func (p *managerProcess) removeAnnotation(ctx context.Context, ss *StatusSet, key string) error {
+	if _, ok := ss.Cluster.Annotations[key]; !ok {
+		return nil
+	}
+	newCluster := ss.Cluster.DeepCopy()
+	delete(newCluster.Annotations, key)
+	if err := p.client.Patch(ctx, newCluster, client.MergeFrom(ss.Cluster)); err != nil {
+		return fmt.Errorf("failed to remove %s annotation: %w", key, err)
+	}
+	return nil
+}
+
+// warnConnectionHeadroom records a warning event if instance `index`, which is
+// about to be promoted, does not have enough connection headroom.
+// The promotion is not stopped because a primary is necessary anyway.
+func (p *managerProcess) warnConnectionHeadroom(ctx context.Context, ss *StatusSet, index int) {
+	if hasConnectionHeadroom(ss, index) {
+		return
+	}
+	ist := ss.MySQLStatus[index]
+	logFromContext(ctx).Info("the next primary does not have enough connection headroom", "index", index,
+		"threads_connected", ist.ThreadsConnected, "max_connections", ist.GlobalVariables.MaxConnections)
+	event.ConnectionHeadroomLow.Emit(ss.Cluster, p.recorder, index, ist.ThreadsConnected, ist.GlobalVariables.MaxConnections)
+}
+
+var defaultFailoverTieBreakers = []mocov1beta2.FailoverTieBreaker{
+	mocov1beta2.FailoverTieBreakerPriority,
+	mocov1beta2.FailoverTieBreakerSameZone,
+}
+
+// breakFailoverTie chooses the next primary from `tops`, the indices of replicas
+// that have the same most advanced GTID set, by applying `spec.failoverTieBreakers` in order.
+// The lowest index is chosen if the rules leave multiple replicas.
+func breakFailoverTie(ss *StatusSet, tops []int) int {
+	rules := ss.Cluster.Spec.FailoverTieBreakers
+	if rules == nil {
+		rules = defaultFailoverTieBreakers
+	}
+
+	for _, rule := range rules {
+		if len(tops) == 1 {
+			break
+		}
+		switch rule {
+		case mocov1beta2.FailoverTieBreakerPriority:
+			best := math.MinInt
+			var preferred []int
+			for _, i := range tops {
+				prio := failoverPriority(ss.Pods[i])
+				switch {
+				case prio > best:
+					best = prio
+					preferred = []int{i}
+				case prio == best:
+					preferred = append(preferred, i)
+				}
+			}
+			tops = preferred
+		case mocov1beta2.FailoverTieBreakerSameZone:
+			zone := podZone(ss.Pods[ss.Primary])
+			if zone == "" {
+				continue
+			}
+			var preferred []int
+			for _, i := range tops {
+				if podZone(ss.Pods[i]) == zone {
+					preferred = append(preferred, i)
+				}
+			}
+			if len(preferred) > 0 {
+				tops = preferred
+			}
+		}
+	}
+	return tops[0]
+}
+
+// eligibleTopRunners removes the instances excluded from the primary from `tops`.
+// As the other replicas lack some transactions of `tops`, this returns an error
+// instead of choosing a less advanced replica if all of `tops` are excluded.
+func eligibleTopRunners(ss *StatusSet, tops []int) ([]int, error) {
+	eligible := slices.DeleteFunc(slices.Clone(tops), func(i int) bool {
+		return slices.Contains(ss.Excluded, i)
+	})
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("the most advanced replicas %v are excluded by %s annotation", tops, constants.AnnExcludeFromPrimary)
+	}
+	return eligible, nil
+}
+
+// failoverPriority returns the value of `moco.cybozu.com/failover-priority` annotation of the Pod.
+// This is zero if the annotation is missing or invalid.
+func failoverPriority(pod *corev1.Pod) int {
+	if pod == nil {
+		return 0
+	}
+	prio, err := strconv.Atoi(pod.Annotations[constants.AnnFailoverPriority])
+	if err != nil {
+		return 0
+	}
+	return prio
+}
+
+func podZone(pod *corev1.Pod) string {
+	if pod == nil {
+		return ""
+	}
+	return pod.Labels[corev1.LabelTopologyZone]
+}
+
 func (p *managerProcess) failover(ctx context.Context, ss *StatusSet) error {
 	log := logFromContext(ctx)
 	log.Info("begin failover the primary", "current", ss.Primary)
 
+	if ss.Cluster.Spec.FenceOldPrimaryOnFailover {
+		p.fenceOldPrimary(ctx, ss)
+	}
+
 	// stop all replica IO threads
 	for i, ist := range ss.MySQLStatus {
 		if i == ss.Primary {
@@ -230,15 +378,40 @@ func (p *managerProcess) failover(ctx context.Context, ss *StatusSet) error {
 		if err != nil {
 			return fmt.Errorf("failed to recheck the status of instance %d: %w", i, err)
 		}
+		if reason := failoverExclusion(newStatus); reason != "" {
+			log.Info(reason+"; excluded from the candidates", "index", i)
+			continue
+		}
 		candidates[i] = newStatus
 	}
 
-	candidate, err := dbop.FindTopRunner(ctx, op, candidates)
+	tops, err := dbop.FindTopRunners(ctx, op, candidates)
+	if err != nil {
+		return fmt.Errorf("failed to choose the next primary: %w", err)
+	}
+	tops, err = eligibleTopRunners(ss, tops)
 	if err != nil {
 		return fmt.Errorf("failed to choose the next primary: %w", err)
 	}
+	candidate := breakFailoverTie(ss, preferConnectionHeadroom(ss, tops))
+	if len(tops) > 1 {
+		log.Info("chose the next primary among equally advanced replicas", "candidates", tops, "index", candidate)
+	}
+	// promoting another replica may lose transactions, so wait for the recovery.
+	if n := candidates[candidate].RecoveredTransactions; n > 0 {
+		return fmt.Errorf("instance %d is rolling back %d transactions recovered by the crash recovery", candidate, n)
+	}
+	p.warnConnectionHeadroom(ctx, ss, candidate)
 	ss.Candidate = candidate
 
+	// the SQL thread needs to be running to apply the retrieved transactions.
+	if candidates[candidate].ReplicaStatus.SlaveSQLRunning != "Yes" {
+		log.Info("start replica SQL thread to apply the retrieved transactions", "index", candidate)
+		if err := ss.DBOps[candidate].StartReplicaSQLThread(ctx); err != nil {
+			return fmt.Errorf("failed to start replica SQL thread for instance %d: %w", candidate, err)
+		}
+	}
+
 	gtid := candidates[candidate].ReplicaStatus.RetrievedGtidSet
 	log.Info("waiting for the new primary to execute all retrieved transactions", "index", candidate, "gtid", gtid)
 	err = ss.DBOps[candidate].WaitForGTID(ctx, gtid, failOverTimeoutSeconds)
@@ -252,6 +425,7 @@ func (p *managerProcess) failover(ctx context.Context, ss *StatusSet) error {
 			return err
 		}
 		cluster.Status.CurrentPrimaryIndex = candidate
+		cluster.Status.PrimarySince = &metav1.Time{Time: time.Now()}
 		return p.client.Status().Update(ctx, cluster)
 	})
 	if err != nil {
@@ -260,10 +434,38 @@ func (p *managerProcess) failover(ctx context.Context, ss *StatusSet) error {
 
 	p.metrics.failoverCount.Inc()
 
+	// the connections to the failed primary may hang until they time out.
+	if addr := ss.Pods[ss.Primary].Status.PodIP; addr != "" {
+		p.dbf.Remove(addr)
+	}
+
 	log.Info("failover finished", "primary", candidate)
 	return nil
 }
 
+// fenceOldPrimary kills the client connections of the old primary and makes it read-only
+// so that it cannot accept writes after a new primary is promoted.
+// As the old primary is often unreachable in a failover, the failure of fencing
+// is only recorded as a warning event and the failover continues.
+func (p *managerProcess) fenceOldPrimary(ctx context.Context, ss *StatusSet) {
+	log := logFromContext(ctx)
+	op := ss.DBOps[ss.Primary]
+
+	ctx, cancel := context.WithTimeout(ctx, fenceTimeout)
+	defer cancel()
+
+	// Connections running write events block `set super_read_only=1`, so kill them first.
+	log.Info("fence the old primary", "index", ss.Primary)
+	err := op.KillConnections(ctx)
+	if err == nil {
+		err = op.SetReadOnly(ctx, true)
+	}
+	if err != nil {
+		log.Error(err, "failed to fence the old primary", "index", ss.Primary)
+		event.FencingFailed.Emit(ss.Cluster, p.recorder, ss.Primary, err)
+	}
+}
+
 func (p *managerProcess) removeRoleLabel(ctx context.Context, ss *StatusSet) ([]int, error) {
 	var noRoles []int
 	for i, pod := range ss.Pods {
@@ -385,24 +587,143 @@ func (p *managerProcess) configure(ctx context.Context, ss *StatusSet) (bool, er
 
 	// make the primary writable if it is not an intermediate primary
 	if ss.Cluster.Spec.ReplicationSourceSecretName == nil {
-		pst := ss.MySQLStatus[ss.Primary]
-		op := ss.DBOps[ss.Primary]
-		if pst.GlobalVariables.ReadOnly {
-			redo = true
-			logFromContext(ctx).Info("set read_only=0", "instance", ss.Primary)
-			if err := op.SetReadOnly(ctx, false); err != nil {
-				return false, fmt.Errorf("failed to make the primary writable: %w", err)
-			}
-			event.SetWritable.Emit(ss.Cluster, p.recorder)
+		r, err := p.makePrimaryWritable(ctx, ss)
+		if err != nil {
+			return false, err
 		}
+		redo = redo || r
 	}
 	return redo, nil
 }
 
+// makePrimaryWritable turns off read_only of the primary instance, and then creates
+// `spec.replicationUser` and `spec.ensureTables` on it.  For a primary that is
+// writable already, `spec.replicationUser` is created by configurePrimary.
+// This is deferred while the primary has not been up for `spec.minPrimaryUptimeBeforeWritesSeconds`
+// or while the primary is being cloned, as the data of the primary is not complete yet.
+//
+// If any other instance was not `super_read_only` when the status was gathered, this returns
+// errConstraintsViolation to avoid a split-brain.  Such an instance may have accepted writes,
+// so MOCO leaves it to humans to inspect.  The check is repeated with the fresh status
+// in the next reconciliation.
+func (p *managerProcess) makePrimaryWritable(ctx context.Context, ss *StatusSet) (bool, error) {
+	log := logFromContext(ctx)
+	pst := ss.MySQLStatus[ss.Primary]
+	op := ss.DBOps[ss.Primary]
+	if pst == nil {
+		// DecideState never chooses to configure a cluster whose primary is unreachable,
+		// but the primary should not be made writable without knowing its status anyway.
+		return false, fmt.Errorf("the status of the primary instance %d is not available", ss.Primary)
+	}
+	if isWritable(pst) {
+		return false, nil
+	}
+	if isRecovering(pst) {
+		logRecovering(log, ss, ss.Primary)
+		return false, nil
+	}
+
+	if isCloneInProgress(pst) {
+		log.Info("defer making the primary writable while it is being cloned", "instance", ss.Primary,
+			"progress", fmt.Sprintf("%.1f%%", pst.CloneStatus.EstimatedProgress))
+		return false, nil
+	}
+	if writable := writableReplicas(ss); len(writable) > 0 {
+		cerr := newClusterError(errConstraintsViolation, reasonWritableReplicas,
+			errors.New("replicas are not super_read_only"), writable...)
+		if err := p.setFailureCondition(ctx, mocov1beta2.ConditionConstraintsViolated, cerr); err != nil {
+			log.Error(err, "failed to set ConstraintsViolated condition")
+		}
+		return false, cerr
+	}
+	if meta.IsStatusConditionTrue(ss.Cluster.Status.Conditions, mocov1beta2.ConditionConstraintsViolated) {
+		// the violation has been resolved, so go on to make the primary writable.
+		log.Info("the constraints are satisfied again", "instance", ss.Primary)
+		if err := p.setCondition(ctx, constraintsSatisfiedCondition()); err != nil {
+			log.Error(err, "failed to clear ConstraintsViolated condition")
+		}
+	}
+	if wait := writeWaitDuration(ss.Cluster, primarySince(ss.Cluster), time.Now()); wait > 0 {
+		log.Info("defer making the primary writable", "instance", ss.Primary, "wait", wait)
+		p.wakeUp(wait, "primary-uptime")
+		ss.deferOperation(operationSetWritable)
+		return false, nil
+	}
+
+	log.Info("set read_only=0", "instance", ss.Primary)
+	if err := op.SetReadOnly(ctx, false); err != nil {
+		return false, fmt.Errorf("failed to make the primary writable: %w", err)
+	}
+	event.SetWritable.Emit(ss.Cluster, p.recorder)
+
+	if err := p.ensureReplicationUser(ctx, ss); err != nil {
+		return false, err
+	}
+	for _, t := range ss.Cluster.Spec.EnsureTables {
+		if err := op.EnsureTable(ctx, t.Database, t.Name, t.Definition); err != nil {
+			return false, fmt.Errorf("failed to ensure table on the primary: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// ensureReplicationUser creates `spec.replicationUser` on the primary instance.
+// moco-repl is created by moco-init, but the other replication users are not.
+// As the primary has to be writable to create the user, the caller should check it.
+//
+// The user is ensured only once for each primary Pod and password because
+// `ALTER USER` is written to the binary log.  The password is remembered only
+// as its SHA-256 digest so that it is not kept in memory in plaintext.
+func (p *managerProcess) ensureReplicationUser(ctx context.Context, ss *StatusSet) error {
+	user := ss.Cluster.ReplicationUser()
+	if user == constants.ReplicationUser {
+		return nil
+	}
+	pwd, ok := ss.Password.ReplicatorOf(user)
+	if !ok {
+		return fmt.Errorf("no password for the replication user %s", user)
+	}
+
+	digest := sha256.Sum256([]byte(pwd))
+	key := fmt.Sprintf("%d/%s/%s", ss.Primary, user, hex.EncodeToString(digest[:]))
+	if ss.Primary < len(ss.Pods) && ss.Pods[ss.Primary] != nil {
+		key = string(ss.Pods[ss.Primary].UID) + "/" + key
+	}
+	if key == p.replicationUserKey {
+		return nil
+	}
+
+	logFromContext(ctx).Info("ensure the replication user", "instance", ss.Primary, "user", user)
+	if err := ss.DBOps[ss.Primary].EnsureReplicationUser(ctx, user, pwd); err != nil {
+		return fmt.Errorf("failed to ensure the replication user on the primary: %w", err)
+	}
+	p.replicationUserKey = key
+	return nil
+}
+
+// writableReplicas returns the indices of reachable instances other than the primary
+// whose `super_read_only` is OFF.
+func writableReplicas(ss *StatusSet) []int {
+	var writable []int
+	for i, ist := range ss.MySQLStatus {
+		if i == ss.Primary || ist == nil {
+			continue
+		}
+		if !ist.GlobalVariables.SuperReadOnly {
+			writable = append(writable, i)
+		}
+	}
+	return writable
+}
+
 func (p *managerProcess) configureIntermediatePrimary(ctx context.Context, ss *StatusSet) (redo bool, e error) {
 	log := logFromContext(ctx)
 	pst := ss.MySQLStatus[ss.Primary]
 	op := ss.DBOps[ss.Primary]
+	if isRecovering(pst) {
+		logRecovering(log, ss, ss.Primary)
+		return false, nil
+	}
 	if !pst.GlobalVariables.SuperReadOnly {
 		redo = true
 		log.Info("set super_read_only=1", "instance", ss.Primary)
@@ -441,6 +762,10 @@ func (p *managerProcess) configurePrimary(ctx context.Context, ss *StatusSet) (r
 	log := logFromContext(ctx)
 	pst := ss.MySQLStatus[ss.Primary]
 	op := ss.DBOps[ss.Primary]
+	if isRecovering(pst) {
+		logRecovering(log, ss, ss.Primary)
+		return false, nil
+	}
 
 	// wait for all retrieved transactions to be executed if this used to be an intermediate replica
 	if pst.ReplicaStatus != nil && pst.ReplicaStatus.SlaveIORunning == "Yes" {
@@ -459,15 +784,20 @@ func (p *managerProcess) configurePrimary(ctx context.Context, ss *StatusSet) (r
 		}
 	}
 
-	if ss.Cluster.Spec.Replicas == 1 {
-		return
+	waitFor := primaryWaitForCount(ss)
+	timeout := semiSyncTimeout(ss.Cluster)
+	if needPrimaryConfiguration(pst, waitFor, timeout) {
+		redo = true
+		log.Info("configure semi-sync primary", "waitForCount", waitFor, "timeout", timeout)
+		if err := op.ConfigurePrimary(ctx, waitFor, timeout); err != nil {
+			return false, err
+		}
 	}
 
-	waitFor := int(ss.Cluster.Spec.Replicas / 2)
-	if !pst.GlobalVariables.SemiSyncMasterEnabled || pst.GlobalVariables.WaitForSlaveCount != waitFor {
-		redo = true
-		log.Info("enable semi-sync primary")
-		if err := op.ConfigurePrimary(ctx, waitFor); err != nil {
+	// the replicas configured after this need the replication user.
+	// A read-only primary gets the user when it is made writable.
+	if isWritable(pst) {
+		if err := p.ensureReplicationUser(ctx, ss); err != nil {
 			return false, err
 		}
 	}
@@ -478,6 +808,10 @@ func (p *managerProcess) configureReplica(ctx context.Context, ss *StatusSet, in
 	log := logFromContext(ctx)
 	st := ss.MySQLStatus[index]
 	op := ss.DBOps[index]
+	if isRecovering(st) {
+		logRecovering(log, ss, index)
+		return false, nil
+	}
 
 	// for an errant replica, stop replication
 	if st.IsErrant {
@@ -494,7 +828,7 @@ func (p *managerProcess) configureReplica(ctx context.Context, ss *StatusSet, in
 		return true, nil
 	}
 
-	if !st.GlobalVariables.SuperReadOnly {
+	if !isReadOnly(st) {
 		redo = true
 
 		// When a primary is demoted due to network failure, old connections via the primary service may remain.
@@ -509,70 +843,651 @@ func (p *managerProcess) configureReplica(ctx context.Context, ss *StatusSet, in
 		}
 	}
 
-	// clone and start replication for all non-errant replicas
-	if st.GlobalVariables.ExecutedGTID == "" && ss.ExecutedGTID != "" && st.ReplicaStatus == nil {
-		addr := ss.Pods[ss.Primary].Status.PodIP
-		if addr == "0.0.0.0" {
-			addr = ss.Cluster.PodHostname(ss.Primary)
+	if ss.Cluster.Spec.AutoReCloneOnGap {
+		r, err := p.reCloneOnGap(ctx, ss, index)
+		if err != nil {
+			return false, err
 		}
-		if addr == "" {
-			return false, fmt.Errorf("pod %s has not been assigned an IP address", ss.Pods[ss.Primary].Name)
+		if r {
+			return true, nil
 		}
+	}
 
+	// clone and start replication for all non-errant replicas
+	if st.GlobalVariables.ExecutedGTID == "" && ss.ExecutedGTID != "" && st.ReplicaStatus == nil {
 		redo = true
-		req := &agent.CloneRequest{
-			Host:         addr,
-			Port:         constants.MySQLAdminPort,
-			User:         constants.CloneDonorUser,
-			Password:     ss.Password.Donor(),
-			InitUser:     constants.AdminUser,
-			InitPassword: ss.Password.Admin(),
+		if err := p.cloneReplica(ctx, ss, index); err != nil {
+			return false, err
 		}
+	}
 
-		ag, err := p.agentf.New(ctx, ss.Cluster, index)
-		if err != nil {
-			return false, fmt.Errorf("failed to connect moco-agent of instance %d: %w", index, err)
-		}
-		defer ag.Close()
-
-		log.Info("begin cloning data", "instance", index)
-		if _, err := ag.Clone(ctx, req); err != nil {
-			event.CloneFailed.Emit(ss.Cluster, p.recorder, index, err)
-			log.Error(err, "clone failed", "instance", index)
-			return false, fmt.Errorf("failed to clone data on instance %d: %w", index, err)
-		}
-		event.CloneSucceeded.Emit(ss.Cluster, p.recorder, index)
-		log.Info("clone succeeded", "instance", index)
-
-		// wait until the instance restarts after clone
-		time.Sleep(waitForCloneRestartDuration)
-		for i := 0; i < 60; i++ {
-			select {
-			case <-time.After(1 * time.Second):
-			case <-ctx.Done():
-				return false, ctx.Err()
+	ai := replicationSource(ss)
+	pwd, ok := ss.Password.ReplicatorOf(ai.User)
+	if !ok {
+		return false, fmt.Errorf("no password for the replication user %s", ai.User)
+	}
+	ai.Password = pwd
+	semisync := ss.Cluster.Spec.ReplicationSourceSecretName == nil && !isLagging(ss, index)
+	if isRetryExhausted(st.ReplicaStatus, ai.Host) {
+		count := p.ioThreadRevivals[index]
+		if count < maxIOThreadRevivals {
+			p.ioThreadRevivals[index] = count + 1
+			log.Info("start replica IO thread that has exhausted connection retries", "instance", index, "revivals", count+1, "errno", st.ReplicaStatus.LastIoErrno)
+			if err := op.StartReplicaIOThread(ctx); err != nil {
+				return false, err
 			}
+			return true, nil
+		}
+		if count == maxIOThreadRevivals {
+			// record the event only once, then fall back to the full re-configuration below.
+			p.ioThreadRevivals[index] = count + 1
+			event.ReplicaIOThreadExhausted.Emit(ss.Cluster, p.recorder, index, maxIOThreadRevivals)
+		}
+	} else if st.ReplicaStatus != nil && st.ReplicaStatus.SlaveIORunning == "Yes" {
+		delete(p.ioThreadRevivals, index)
+	}
 
-			_, err := op.GetStatus(ctx)
-			if err == nil {
-				break
+	if isRecoverableSQLError(st.ReplicaStatus, ai.Host) {
+		errno := st.ReplicaStatus.LastSQLErrno
+		attempts := replicaRecoveryAttempts(ss.Cluster, index)
+		if attempts < maxReplicaRecoveries {
+			if err := p.recordReplicaRecovery(ctx, index, attempts+1, errno); err != nil {
+				return false, err
+			}
+			log.Info("reset replica to recover from a transient SQL error", "instance", index, "attempts", attempts+1, "errno", errno)
+			if err := op.ResetReplica(ctx); err != nil {
+				return false, err
+			}
+			event.ReplicaReset.Emit(ss.Cluster, p.recorder, index, errno, attempts+1)
+			return true, nil
+		}
+		if attempts == maxReplicaRecoveries {
+			// record the exhaustion only once, then leave the replica to humans.
+			if err := p.recordReplicaRecovery(ctx, index, attempts+1, errno); err != nil {
+				return false, err
 			}
+			log.Info("gave up recovering replica from a SQL error", "instance", index, "errno", errno)
+			event.ReplicaRecoveryExhausted.Emit(ss.Cluster, p.recorder, index, errno, maxReplicaRecoveries)
 		}
 	}
 
-	ai := dbop.AccessInfo{
-		Host:     ss.Cluster.PodHostname(ss.Primary),
-		Port:     constants.MySQLPort,
-		User:     constants.ReplicationUser,
-		Password: ss.Password.Replicator(),
+	selfReplicating := isSelfReplicating(st, ss.Cluster.PodHostname(index))
+	need := selfReplicating || needReplicaConfiguration(st, ai.Host, semisync, requireAutoPosition(ss.Cluster))
+	// this must be called in every check to track the errors.
+	if p.hasPersistentIOError(st, index, ai.Host) && !need {
+		// the error may be caused by stale credentials.  `CHANGE MASTER TO` re-applies them
+		// and `START SLAVE` clears the error.
+		log.Info("replica keeps reporting an IO thread error", "instance", index, "errno", st.ReplicaStatus.LastIoErrno)
+		need = true
+	}
+	if need && !selfReplicating && toleratesSourceMismatch(ss, st, index, semisync) {
+		log.Info("ignore the mismatch of the replication source configured already", "instance", index, "source", st.ReplicaStatus.MasterHost)
+		need = false
 	}
-	semisync := ss.Cluster.Spec.ReplicationSourceSecretName == nil
-	if st.ReplicaStatus == nil || st.ReplicaStatus.SlaveIORunning != "Yes" || st.ReplicaStatus.MasterHost != ai.Host || st.GlobalVariables.SemiSyncSlaveEnabled != semisync {
+	if need {
 		redo = true
+		if selfReplicating {
+			log.Info("replica is replicating from itself", "instance", index, "source", st.ReplicaStatus.MasterHost)
+		}
 		log.Info("start replication", "instance", index, "semisync", semisync)
 		if err := op.ConfigureReplica(ctx, ai, semisync); err != nil {
 			return false, err
 		}
+		delete(p.ioErrors, index)
+		if selfReplicating {
+			event.ReplicaSelfReplication.Emit(ss.Cluster, p.recorder, index)
+		}
 	}
 	return
 }
+
+// failoverExclusion returns the reason why the replica of status `st` cannot be
+// the new primary in a failover, or an empty string if it can.
+//
+// A replica whose SQL thread stopped with an error may not be able to apply the
+// retrieved transactions, so it is excluded.  IO thread errors are expected as the
+// primary is gone, so they do not exclude the replica.
+func failoverExclusion(st *dbop.MySQLInstanceStatus) string {
+	switch {
+	case !st.GlobalVariables.LogBin:
+		return "binary logging is disabled"
+	case st.ReplicaStatus != nil && st.ReplicaStatus.LastSQLErrno != 0:
+		return fmt.Sprintf("the SQL thread stopped with error %d", st.ReplicaStatus.LastSQLErrno)
+	}
+	return ""
+}
+
+// logRecovering logs that the instance `index` is not operated during the crash recovery.
+func logRecovering(log logr.Logger, ss *StatusSet, index int) {
+	log.Info("skip operating the instance while InnoDB rolls back recovered transactions",
+		"instance", index, "transactions", ss.MySQLStatus[index].RecoveredTransactions)
+}
+
+// replicationSource returns the replication source of the replicas, i.e. the primary.
+// The password is not filled.
+func replicationSource(ss *StatusSet) dbop.AccessInfo {
+	return dbop.AccessInfo{
+		Host: ss.Cluster.PodHostname(ss.Primary),
+		Port: int(ss.Cluster.MySQLPort()),
+		User: ss.Cluster.ReplicationUser(),
+	}
+}
+
+// replicatesFrom returns true if the replica is replicating from `ai`.
+func replicatesFrom(st *dbop.MySQLInstanceStatus, ai dbop.AccessInfo) bool {
+	if st == nil || st.ReplicaStatus == nil {
+		return false
+	}
+	rs := st.ReplicaStatus
+	return rs.SlaveIORunning == "Yes" && rs.MasterHost == ai.Host && rs.MasterPort == ai.Port && rs.MasterUser == ai.User
+}
+
+// replicationConfigHash returns the hash of the replication source in `ai`.
+// The password is not included because it is not a part of the source.
+func replicationConfigHash(ai dbop.AccessInfo) string {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s\x00%d\x00%s", ai.Host, ai.Port, ai.User)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// configuredReplicationHash returns the replication config hash of instance `index`
+// recorded in `status.instances`.
+func configuredReplicationHash(cluster *mocov1beta2.MySQLCluster, index int) string {
+	for _, st := range cluster.Status.Instances {
+		if st.Index == index {
+			return st.ReplicationConfigHash
+		}
+	}
+	return ""
+}
+
+// toleratesSourceMismatch returns true if replica `index` should be left as is although
+// its `Master_Host` differs from the primary.  This is the case only when
+//
+//   - the mismatch is the only reason to reconfigure the replica, and
+//   - the replica was observed replicating from the primary as recorded in `status.instances`.
+//
+// Otherwise, a transient mismatch would stop the replication of a healthy replica.
+// The mismatch clears the hash in `status.instances`, so a replica that keeps
+// replicating from a wrong host is reconfigured in the next check.  A replica that
+// actually stopped replicating from the primary is reconfigured for the other reasons
+// right away, e.g. its IO thread is not running.
+func toleratesSourceMismatch(ss *StatusSet, st *dbop.MySQLInstanceStatus, index int, semisync bool) bool {
+	source := replicationSource(ss)
+	rs := st.ReplicaStatus
+	if rs == nil || rs.MasterHost == source.Host {
+		return false
+	}
+	if configuredReplicationHash(ss.Cluster, index) != replicationConfigHash(source) {
+		return false
+	}
+	// check the other reasons by pretending that the source is right.
+	return !needReplicaConfiguration(st, rs.MasterHost, semisync, requireAutoPosition(ss.Cluster))
+}
+
+// hasPersistentIOError returns true if the IO thread of replica `index` replicating
+// from `sourceHost` has reported the same error in this and the last check.
+// A single error is often transient, e.g. a network blip, and restarting the
+// replication for it is unnecessary.
+//
+// The IO thread that cannot resolve the name of the source is not reported
+// because it keeps retrying the connection by itself.
+func (p *managerProcess) hasPersistentIOError(st *dbop.MySQLInstanceStatus, index int, sourceHost string) bool {
+	rs := st.ReplicaStatus
+	if rs == nil || rs.LastIoErrno == 0 || isResolvingSource(rs, sourceHost) {
+		delete(p.ioErrors, index)
+		return false
+	}
+	last := p.ioErrors[index]
+	p.ioErrors[index] = rs.LastIoErrno
+	return last == rs.LastIoErrno
+}
+
+// cloneReplica clones the data of the primary to the replica instance `index`
+// and waits for the instance to restart.  The replication is not started.
+func (p *managerProcess) cloneReplica(ctx context.Context, ss *StatusSet, index int) error {
+	log := logFromContext(ctx)
+
+	addr := ss.Pods[ss.Primary].Status.PodIP
+	if addr == "0.0.0.0" {
+		addr = ss.Cluster.PodHostname(ss.Primary)
+	}
+	if addr == "" {
+		return fmt.Errorf("pod %s has not been assigned an IP address", ss.Pods[ss.Primary].Name)
+	}
+
+	req := &agent.CloneRequest{
+		Host:         addr,
+		Port:         constants.MySQLAdminPort,
+		User:         constants.CloneDonorUser,
+		Password:     ss.Password.Donor(),
+		InitUser:     constants.AdminUser,
+		InitPassword: ss.Password.Admin(),
+	}
+
+	ag, err := p.agentf.New(ctx, ss.Cluster, index)
+	if err != nil {
+		return fmt.Errorf("failed to connect moco-agent of instance %d: %w", index, err)
+	}
+	defer ag.Close()
+
+	log.Info("begin cloning data", "instance", index)
+	if _, err := ag.Clone(ctx, req); err != nil {
+		event.CloneFailed.Emit(ss.Cluster, p.recorder, index, err)
+		log.Error(err, "clone failed", "instance", index)
+		return fmt.Errorf("failed to clone data on instance %d: %w", index, err)
+	}
+	event.CloneSucceeded.Emit(ss.Cluster, p.recorder, index)
+	log.Info("clone succeeded", "instance", index)
+
+	// wait until the instance restarts after clone
+	time.Sleep(waitForCloneRestartDuration)
+	for i := 0; i < 60; i++ {
+		select {
+		case <-time.After(1 * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		_, err := ss.DBOps[index].GetStatus(ctx)
+		if err == nil {
+			break
+		}
+	}
+	return nil
+}
+
+// minReCloneInterval is the minimum interval between re-clones by `spec.autoReCloneOnGap`.
+// This is a variable for testing.
+var minReCloneInterval = 10 * time.Minute
+
+// reCloneOnGap re-clones the data of the primary to the replica instance `index`
+// if the replica lacks transactions purged from the primary.  It returns true if
+// the replica has been re-cloned.  The re-clone may be skipped by the guardrails
+// described in reCloneBlocker.
+func (p *managerProcess) reCloneOnGap(ctx context.Context, ss *StatusSet, index int) (bool, error) {
+	log := logFromContext(ctx)
+
+	gap, err := hasPurgedGap(ctx, ss, index)
+	if err != nil {
+		return false, fmt.Errorf("failed to check the purged transactions on instance %d: %w", index, err)
+	}
+	if !gap {
+		return false, nil
+	}
+
+	reason, err := p.reCloneBlocker(ctx, ss, index, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if reason != "" {
+		log.Info("skip re-cloning the replica that lacks purged transactions", "instance", index, "reason", reason)
+		ss.deferOperation(fmt.Sprintf("%s/%d", operationReClone, index))
+		return false, nil
+	}
+
+	now := metav1.Now()
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := p.reader.Get(ctx, p.name, cluster); err != nil {
+			return err
+		}
+		cluster.Status.LastReCloneTime = &now
+		return p.client.Status().Update(ctx, cluster)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to record the re-clone time: %w", err)
+	}
+	// prevent other replicas from being re-cloned in this reconciliation.
+	ss.Cluster.Status.LastReCloneTime = &now
+
+	log.Info("re-clone the replica that lacks purged transactions", "instance", index)
+	event.ReCloneStarted.Emit(ss.Cluster, p.recorder, index)
+	if err := p.cloneReplica(ctx, ss, index); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// hasPurgedGap returns true if the replica instance `index` lacks transactions
+// that have been purged from the binary logs of the primary.  Such a replica
+// can never catch up by replication.  Replicas whose IO thread is running are
+// receiving transactions, so they are not checked.
+func hasPurgedGap(ctx context.Context, ss *StatusSet, index int) (bool, error) {
+	pst := ss.MySQLStatus[ss.Primary]
+	if pst == nil || pst.GlobalVariables.PurgedGTID == "" {
+		return false, nil
+	}
+	st := ss.MySQLStatus[index]
+	if st.ReplicaStatus == nil || st.ReplicaStatus.SlaveIORunning == "Yes" {
+		return false, nil
+	}
+
+	contained, err := ss.DBOps[index].IsSubsetGTID(ctx, pst.GlobalVariables.PurgedGTID, st.GlobalVariables.ExecutedGTID)
+	if err != nil {
+		return false, err
+	}
+	return !contained, nil
+}
+
+// reCloneBlocker returns the reason why MOCO should not re-clone the replica
+// instance `index` now, or an empty string if nothing blocks the re-clone.
+//
+// - Only one instance is cloned at a time.
+// - Re-clones are done at most once in minReCloneInterval.
+// - The data volume of the replica should be larger than the data of the primary.
+func (p *managerProcess) reCloneBlocker(ctx context.Context, ss *StatusSet, index int, now time.Time) (string, error) {
+	for i, ist := range ss.MySQLStatus {
+		if i == index || ist == nil || ist.CloneStatus == nil {
+			continue
+		}
+		if isCloneInProgress(ist) {
+			return fmt.Sprintf("instance %d is being cloned (%.1f%%)", i, ist.CloneStatus.EstimatedProgress), nil
+		}
+	}
+
+	if last := ss.Cluster.Status.LastReCloneTime; last != nil && now.Sub(last.Time) < minReCloneInterval {
+		return fmt.Sprintf("a replica was re-cloned at %s", last.UTC().Format(time.RFC3339)), nil
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	pvcName := client.ObjectKey{Namespace: ss.Cluster.Namespace, Name: constants.MySQLDataVolumeName + "-" + ss.Cluster.PodName(index)}
+	err := p.reader.Get(ctx, pvcName, pvc)
+	if apierrors.IsNotFound(err) {
+		// the capacity is unknown
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get PVC %s: %w", pvcName.String(), err)
+	}
+	capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+	if !ok {
+		return "", nil
+	}
+
+	size, err := ss.DBOps[ss.Primary].GetDataSize(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the data size of the primary: %w", err)
+	}
+	if capacity.Value() < size {
+		event.ReCloneSkipped.Emit(ss.Cluster, p.recorder, index, size, capacity.Value())
+		return fmt.Sprintf("the data size %d exceeds the capacity %d", size, capacity.Value()), nil
+	}
+	return "", nil
+}
+
+// maxIOThreadRevivals is the maximum number of times MOCO restarts the IO thread
+// of a replica that has exhausted its connection retries before giving up and
+// re-configuring the replication from scratch.
+const maxIOThreadRevivals = 5
+
+// isRetryExhausted returns true if the IO thread of a replica has stopped
+// because it could not connect to `sourceHost` within `MASTER_RETRY_COUNT`.
+// Such a thread, unlike one that is still retrying ("Connecting"), stays
+// stopped until it is started again.
+func isRetryExhausted(rs *dbop.ReplicaStatus, sourceHost string) bool {
+	return rs != nil &&
+		rs.MasterHost == sourceHost &&
+		rs.SlaveIORunning == "No" &&
+		rs.LastIoErrno != 0
+}
+
+// maxReplicaRecoveries is the maximum number of times MOCO resets the replication
+// of a replica whose SQL thread has stopped with a transient error.
+const maxReplicaRecoveries = 3
+
+// transientSQLErrors is the set of `Last_SQL_Errno` that may go away by
+// fetching and applying the transactions again.
+var transientSQLErrors = map[int]bool{
+	1205: true, // ER_LOCK_WAIT_TIMEOUT
+	1213: true, // ER_LOCK_DEADLOCK
+	1594: true, // ER_SLAVE_RELAY_LOG_READ_FAILURE
+}
+
+// isRecoverableSQLError returns true if the SQL thread of a replica of `sourceHost`
+// has stopped with a transient error.
+func isRecoverableSQLError(rs *dbop.ReplicaStatus, sourceHost string) bool {
+	return rs != nil &&
+		rs.MasterHost == sourceHost &&
+		rs.SlaveSQLRunning == "No" &&
+		transientSQLErrors[rs.LastSQLErrno]
+}
+
+// replicaRecoveryAttempts returns the number of attempts to recover instance `index`
+// recorded in `status.replicaRecoveries`.
+func replicaRecoveryAttempts(cluster *mocov1beta2.MySQLCluster, index int) int {
+	for _, r := range cluster.Status.ReplicaRecoveries {
+		if r.Index == index {
+			return r.Attempts
+		}
+	}
+	return 0
+}
+
+// recordReplicaRecovery records the number of attempts to recover instance `index`
+// in `status.replicaRecoveries` so that MOCO does not retry forever even if it restarts.
+func (p *managerProcess) recordReplicaRecovery(ctx context.Context, index, attempts, errno int) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := p.reader.Get(ctx, p.name, cluster); err != nil {
+			return err
+		}
+		var recoveries []mocov1beta2.ReplicaRecoveryStatus
+		for _, r := range cluster.Status.ReplicaRecoveries {
+			if r.Index != index {
+				recoveries = append(recoveries, r)
+			}
+		}
+		recoveries = append(recoveries, mocov1beta2.ReplicaRecoveryStatus{Index: index, Attempts: attempts, LastSQLErrno: errno})
+		sort.Slice(recoveries, func(i, j int) bool { return recoveries[i].Index < recoveries[j].Index })
+		cluster.Status.ReplicaRecoveries = recoveries
+		return p.client.Status().Update(ctx, cluster)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record the recovery of instance %d: %w", index, err)
+	}
+	return nil
+}
+
+// isSelfReplicating returns true if the replication source of an instance is
+// the instance itself.  Such a loop never makes progress, so it must be
+// reconfigured regardless of the other replication states.
+func isSelfReplicating(st *dbop.MySQLInstanceStatus, selfHost string) bool {
+	return st.ReplicaStatus != nil && st.ReplicaStatus.MasterHost == selfHost
+}
+
+// isLagging returns true if `Seconds_Behind_Master` of the replica instance `index`
+// exceeds `spec.maxReplicationLagSeconds`.  Such a replica is excluded from the
+// semi-synchronous replicas until it catches up.
+func isLagging(ss *StatusSet, index int) bool {
+	maxLag := ss.Cluster.Spec.MaxReplicationLagSeconds
+	if maxLag <= 0 {
+		return false
+	}
+	ist := ss.MySQLStatus[index]
+	if ist == nil || ist.ReplicaStatus == nil {
+		return false
+	}
+	sbm := ist.ReplicaStatus.SecondsBehindMaster
+	return sbm.Valid && sbm.Int64 > int64(maxLag)
+}
+
+// lagChangedReplicas returns the replicas of a healthy cluster whose semi-synchronous
+// replication should be toggled because they have started or stopped lagging.
+// This is always empty if `spec.maxReplicationLagSeconds` is not set.
+func lagChangedReplicas(ss *StatusSet) []int {
+	if ss.Cluster.Spec.MaxReplicationLagSeconds <= 0 || ss.Cluster.Spec.ReplicationSourceSecretName != nil {
+		return nil
+	}
+	var replicas []int
+	for i, ist := range ss.MySQLStatus {
+		if i == ss.Primary || ist == nil {
+			continue
+		}
+		if ist.GlobalVariables.SemiSyncSlaveEnabled == isLagging(ss, i) {
+			replicas = append(replicas, i)
+		}
+	}
+	return replicas
+}
+
+// semiSyncWaitForCount returns `rpl_semi_sync_master_wait_for_slave_count` for the primary of `cluster`.
+// This is zero for a single instance cluster, which should not enable semi-synchronous replication
+// because there is no replica to acknowledge transactions.
+//
+// `spec.semiSyncWaitForCount` is validated by the webhook, but the count is capped
+// by the number of replicas just in case because the primary could never commit otherwise.
+func semiSyncWaitForCount(cluster *mocov1beta2.MySQLCluster) int {
+	if cluster.Spec.Replicas == 1 {
+		return 0
+	}
+	if n := cluster.Spec.SemiSyncWaitForCount; n != nil && *n > 0 {
+		if *n > cluster.Spec.Replicas-1 {
+			return int(cluster.Spec.Replicas - 1)
+		}
+		return int(*n)
+	}
+	return int(cluster.Spec.Replicas / 2)
+}
+
+// primaryNeedsConfiguration returns true if the primary of a healthy cluster
+// has lost its semi-synchronous replication settings, e.g. by a restart or
+// a manual `SET GLOBAL`.  Such a primary still looks healthy from replicas.
+func primaryNeedsConfiguration(ss *StatusSet) bool {
+	if ss.Cluster.Spec.ReplicationSourceSecretName != nil {
+		return false
+	}
+	pst := ss.MySQLStatus[ss.Primary]
+	if pst == nil {
+		return false
+	}
+	return needPrimaryConfiguration(pst, primaryWaitForCount(ss), semiSyncTimeout(ss.Cluster))
+}
+
+// semiSyncTimeout returns `rpl_semi_sync_master_timeout` in milliseconds to be set to the primary.
+func semiSyncTimeout(cluster *mocov1beta2.MySQLCluster) int {
+	if cluster.Spec.SemiSyncTimeoutSeconds == nil {
+		return dbop.DefaultSemiSyncMasterTimeout
+	}
+	return int(*cluster.Spec.SemiSyncTimeoutSeconds) * 1000
+}
+
+// primaryWaitForCount returns `rpl_semi_sync_master_wait_for_slave_count` to be set to
+// the primary in the current status.  If `spec.allReplicasDownPolicy` is "Continue"
+// and all replicas are down, this returns zero to let the primary keep accepting writes.
+func primaryWaitForCount(ss *StatusSet) int {
+	if ss.Cluster.Spec.AllReplicasDownPolicy == mocov1beta2.AllReplicasDownPolicyContinue && allReplicasDown(ss) {
+		return 0
+	}
+	return semiSyncWaitForCount(ss.Cluster)
+}
+
+// allReplicasDown returns true if the primary is writable and healthy while
+// none of the replica instances can be reached.
+func allReplicasDown(ss *StatusSet) bool {
+	if ss.Cluster.Spec.Replicas == 1 || ss.Cluster.Spec.ReplicationSourceSecretName != nil {
+		return false
+	}
+	if !isPodReady(ss.Pods[ss.Primary]) || lostData(ss) {
+		return false
+	}
+	pst := ss.MySQLStatus[ss.Primary]
+	if pst == nil || !isWritable(pst) {
+		return false
+	}
+	for i, ist := range ss.MySQLStatus {
+		if i == ss.Primary {
+			continue
+		}
+		if ist != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// needPrimaryConfiguration returns true if server-side semi-synchronous replication
+// of the primary instance needs to be (re-)configured.
+//
+// The settings are not persisted, so they are lost when `mysqld` restarts.
+// As the settings are compared with the observed values in every check,
+// they are re-applied without detecting the restart explicitly.
+func needPrimaryConfiguration(st *dbop.MySQLInstanceStatus, waitForCount, timeout int) bool {
+	if waitForCount == 0 {
+		return st.GlobalVariables.SemiSyncMasterEnabled
+	}
+	return !st.GlobalVariables.SemiSyncMasterEnabled ||
+		st.GlobalVariables.WaitForSlaveCount != waitForCount ||
+		st.GlobalVariables.SemiSyncMasterTimeout != timeout
+}
+
+// needReplicaConfiguration returns true if the replication of a replica instance
+// needs to be (re-)configured to replicate data from `sourceHost`.
+//
+// A replica that cannot resolve the name of the right source is not reconfigured.
+// This happens temporarily while the source Pod is being re-created, and
+// the IO thread keeps retrying the connection by itself.
+//
+// The other errors of the IO thread are not considered here as they may be transient.
+// See hasPersistentIOError.
+//
+// If `autoPosition` is true, a replica that does not use GTID auto-positioning
+// is also reconfigured because `CHANGE MASTER TO` always sets `MASTER_AUTO_POSITION=1`.
+func needReplicaConfiguration(st *dbop.MySQLInstanceStatus, sourceHost string, semisync, autoPosition bool) bool {
+	rs := st.ReplicaStatus
+	switch {
+	case rs == nil:
+		return true
+	case autoPosition && rs.AutoPosition == "0":
+		return true
+	case isResolvingSource(rs, sourceHost) && st.GlobalVariables.SemiSyncSlaveEnabled == semisync:
+		return false
+	case rs.SlaveIORunning != "Yes":
+		return true
+	case rs.MasterHost != sourceHost:
+		return true
+	case st.GlobalVariables.SemiSyncSlaveEnabled != semisync:
+		return true
+	}
+	return false
+}
+
+// requireAutoPosition returns true if replicas of `cluster` must use GTID auto-positioning.
+func requireAutoPosition(cluster *mocov1beta2.MySQLCluster) bool {
+	v := cluster.Spec.RequireGTIDAutoPosition
+	return v == nil || *v
+}
+
+// primarySince returns `status.primarySince` of the cluster.
+// If it has not been recorded yet, the current time is returned.
+func primarySince(cluster *mocov1beta2.MySQLCluster) time.Time {
+	if t := cluster.Status.PrimarySince; t != nil {
+		return t.Time
+	}
+	return time.Now()
+}
+
+// writeWaitDuration returns the remaining duration before the primary that has been
+// the primary since `since` may be made writable.
+func writeWaitDuration(cluster *mocov1beta2.MySQLCluster, since, now time.Time) time.Duration {
+	minUptime := time.Duration(cluster.Spec.MinPrimaryUptimeBeforeWritesSeconds) * time.Second
+	if minUptime <= 0 {
+		return 0
+	}
+	wait := since.Add(minUptime).Sub(now)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// errUnknownHost is the client error code CR_UNKNOWN_HOST.
+const errUnknownHost = 2005
+
+// isResolvingSource returns true if the IO thread of a replica is retrying
+// to connect to `sourceHost` because the name cannot be resolved.
+func isResolvingSource(rs *dbop.ReplicaStatus, sourceHost string) bool {
+	return rs.MasterHost == sourceHost &&
+		rs.SlaveIORunning == "Connecting" &&
+		rs.LastIoErrno == errUnknownHost
+}
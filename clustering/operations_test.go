@@ -0,0 +1,2018 @@
+package clustering
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	agent "github.com/cybozu-go/moco-agent/proto"
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/constants"
+	"github.com/cybozu-go/moco/pkg/dbop"
+	"github.com/cybozu-go/moco/pkg/event"
+	"github.com/cybozu-go/moco/pkg/password"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNeedReplicaConfiguration(t *testing.T) {
+	const source = "moco-test-0.moco-test.ns.svc"
+
+	testCases := []struct {
+		name         string
+		status       *dbop.MySQLInstanceStatus
+		semisync     bool
+		autoPosition bool
+		expected     bool
+	}{
+		{
+			name:     "not-configured",
+			status:   &dbop.MySQLInstanceStatus{},
+			semisync: true,
+			expected: true,
+		},
+		{
+			name: "running",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus:   &dbop.ReplicaStatus{MasterHost: source, SlaveIORunning: "Yes"},
+			},
+			semisync: true,
+			expected: false,
+		},
+		{
+			name: "io-thread-stopped",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus:   &dbop.ReplicaStatus{MasterHost: source, SlaveIORunning: "No"},
+			},
+			semisync: true,
+			expected: true,
+		},
+		{
+			name: "wrong-source",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus:   &dbop.ReplicaStatus{MasterHost: "moco-test-1.moco-test.ns.svc", SlaveIORunning: "Yes"},
+			},
+			semisync: true,
+			expected: true,
+		},
+		{
+			name: "matching-host-but-broken-io",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus: &dbop.ReplicaStatus{
+					MasterHost:     source,
+					SlaveIORunning: "Yes",
+					LastIoErrno:    1045,
+					LastIoError:    "Access denied for user 'moco-repl'",
+				},
+			},
+			semisync: true,
+			expected: false,
+		},
+		{
+			name: "matching-host-but-connecting",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus: &dbop.ReplicaStatus{
+					MasterHost:     source,
+					SlaveIORunning: "Connecting",
+					LastIoErrno:    2003,
+				},
+			},
+			semisync: true,
+			expected: true,
+		},
+		{
+			name: "matching-host-but-unresolvable",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus: &dbop.ReplicaStatus{
+					MasterHost:     source,
+					SlaveIORunning: "Connecting",
+					LastIoErrno:    2005,
+					LastIoError:    "error connecting to master: Unknown MySQL server host",
+				},
+			},
+			semisync: true,
+			expected: false,
+		},
+		{
+			name: "wrong-host-and-unresolvable",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus: &dbop.ReplicaStatus{
+					MasterHost:     "moco-test-1.moco-test.ns.svc",
+					SlaveIORunning: "Connecting",
+					LastIoErrno:    2005,
+				},
+			},
+			semisync: true,
+			expected: true,
+		},
+		{
+			name: "unresolvable-and-semisync-mismatch",
+			status: &dbop.MySQLInstanceStatus{
+				ReplicaStatus: &dbop.ReplicaStatus{
+					MasterHost:     source,
+					SlaveIORunning: "Connecting",
+					LastIoErrno:    2005,
+				},
+			},
+			semisync: true,
+			expected: true,
+		},
+		{
+			name: "auto-position-disabled",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus:   &dbop.ReplicaStatus{MasterHost: source, SlaveIORunning: "Yes", AutoPosition: "0"},
+			},
+			semisync:     true,
+			autoPosition: true,
+			expected:     true,
+		},
+		{
+			name: "auto-position-disabled-but-not-required",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus:   &dbop.ReplicaStatus{MasterHost: source, SlaveIORunning: "Yes", AutoPosition: "0"},
+			},
+			semisync: true,
+			expected: false,
+		},
+		{
+			name: "auto-position-enabled",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{SemiSyncSlaveEnabled: true},
+				ReplicaStatus:   &dbop.ReplicaStatus{MasterHost: source, SlaveIORunning: "Yes", AutoPosition: "1"},
+			},
+			semisync:     true,
+			autoPosition: true,
+			expected:     false,
+		},
+		{
+			name: "semisync-mismatch",
+			status: &dbop.MySQLInstanceStatus{
+				ReplicaStatus: &dbop.ReplicaStatus{MasterHost: source, SlaveIORunning: "Yes"},
+			},
+			semisync: true,
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual := needReplicaConfiguration(tc.status, source, tc.semisync, tc.autoPosition)
+			if actual != tc.expected {
+				t.Errorf("unexpected result: expected=%v, actual=%v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+type replicaOperator struct {
+	dbop.NopOperator
+	sources  []string
+	last     dbop.AccessInfo
+	ioStarts int
+	resets   int
+}
+
+func (o *replicaOperator) StartReplicaIOThread(ctx context.Context) error {
+	o.ioStarts++
+	return nil
+}
+
+func (o *replicaOperator) ResetReplica(ctx context.Context) error {
+	o.resets++
+	return nil
+}
+
+func (o *replicaOperator) ConfigureReplica(ctx context.Context, source dbop.AccessInfo, semisync bool) error {
+	o.sources = append(o.sources, source.Host)
+	o.last = source
+	return nil
+}
+
+func TestConfigureReplicaUser(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+	cluster.Spec.ReplicationUser = "tenant_repl"
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op := &replicaOperator{}
+	p := &managerProcess{recorder: record.NewFakeRecorder(10), ioErrors: make(map[int]int)}
+	ss := &StatusSet{
+		Cluster:     cluster,
+		Password:    passwd,
+		Primary:     0,
+		MySQLStatus: []*dbop.MySQLInstanceStatus{nil, {GlobalVariables: dbop.GlobalVariables{ReadOnly: true, SuperReadOnly: true}}, nil},
+		DBOps:       []dbop.Operator{nil, op, nil},
+	}
+
+	// the password of the user has not been generated yet.
+	if _, err := p.configureReplica(context.Background(), ss, 1); err == nil {
+		t.Fatal("configureReplica should fail without the password")
+	}
+	if len(op.sources) != 0 {
+		t.Fatalf("replica should not be configured: %v", op.sources)
+	}
+
+	if _, err := passwd.AddReplicator("tenant_repl"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.configureReplica(context.Background(), ss, 1); err != nil {
+		t.Fatal(err)
+	}
+	expectedPasswd, _ := passwd.ReplicatorOf("tenant_repl")
+	if op.last.User != "tenant_repl" || op.last.Password != expectedPasswd {
+		t.Errorf("unexpected replication user: %s", op.last.User)
+	}
+	if expectedPasswd == passwd.Replicator() {
+		t.Error("the password should differ from the one of moco-repl")
+	}
+}
+
+func TestConfigureReplicaSourceMismatch(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newStatus := func(source string) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{
+			GlobalVariables: dbop.GlobalVariables{
+				ExecutedGTID:         "1234",
+				ReadOnly:             true,
+				SuperReadOnly:        true,
+				SemiSyncSlaveEnabled: true,
+			},
+			ReplicaStatus: &dbop.ReplicaStatus{
+				MasterHost:     source,
+				MasterPort:     constants.MySQLPort,
+				MasterUser:     constants.ReplicationUser,
+				SlaveIORunning: "Yes",
+				AutoPosition:   "1",
+			},
+		}
+	}
+
+	op := &replicaOperator{}
+	p := &managerProcess{recorder: record.NewFakeRecorder(10), ioErrors: make(map[int]int)}
+	ss := &StatusSet{
+		Cluster:     cluster,
+		Password:    passwd,
+		Primary:     0,
+		MySQLStatus: []*dbop.MySQLInstanceStatus{newStatus(""), newStatus(cluster.PodHostname(0)), newStatus(cluster.PodHostname(0))},
+		DBOps:       []dbop.Operator{nil, op, nil},
+	}
+	// check runs a check of the replica.  `status.instances` is updated before the
+	// operations, but they refer to the MySQLCluster gathered at the start of the check.
+	check := func(source string) {
+		t.Helper()
+		ss.MySQLStatus[1] = newStatus(source)
+		next := instanceStatuses(ss, cluster.Status.Instances)
+		if _, err := p.configureReplica(context.Background(), ss, 1); err != nil {
+			t.Fatal(err)
+		}
+		cluster.Status.Instances = next
+	}
+
+	// reconfiguring a replica that MOCO has not observed replicating from the primary.
+	check("other")
+	if len(op.sources) != 1 {
+		t.Fatalf("replica should be reconfigured: %v", op.sources)
+	}
+
+	// recording the hash after the replica is observed replicating from the primary.
+	check(cluster.PodHostname(0))
+	if cluster.Status.Instances[1].ReplicationConfigHash == "" {
+		t.Fatal("the hash was not recorded")
+	}
+	if cluster.Status.Instances[0].ReplicationConfigHash != "" {
+		t.Error("the hash should not be recorded for the primary")
+	}
+
+	// ignoring a mismatch of the replica that has been replicating from the primary
+	// only in a single check.  The hash is cleared by the mismatch.
+	op.sources = nil
+	check("other")
+	if len(op.sources) != 0 {
+		t.Fatalf("replica should not be reconfigured: %v", op.sources)
+	}
+	if cluster.Status.Instances[1].ReplicationConfigHash != "" {
+		t.Fatal("the hash was not cleared")
+	}
+	check(cluster.PodHostname(0))
+	check("other")
+	if len(op.sources) != 0 {
+		t.Fatalf("replica should not be reconfigured: %v", op.sources)
+	}
+
+	// reconfiguring a replica that keeps replicating from a wrong host.
+	check("other")
+	if len(op.sources) != 1 || op.sources[0] != cluster.PodHostname(0) {
+		t.Fatalf("replica should be reconfigured: %v", op.sources)
+	}
+	op.sources = nil
+
+	// reconfiguring a replica that has stopped replicating.
+	ss.MySQLStatus[1] = newStatus("other")
+	ss.MySQLStatus[1].ReplicaStatus.SlaveIORunning = "No"
+	if _, err := p.configureReplica(context.Background(), ss, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(op.sources) != 1 || op.sources[0] != cluster.PodHostname(0) {
+		t.Fatalf("replica should be reconfigured: %v", op.sources)
+	}
+
+	// reconfiguring replicas after the primary changes.
+	op.sources = nil
+	ss.Primary = 2
+	check(cluster.PodHostname(0))
+	if len(op.sources) != 1 || op.sources[0] != cluster.PodHostname(2) {
+		t.Fatalf("replica should be reconfigured: %v", op.sources)
+	}
+}
+
+func TestConfigureReplicaIOError(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newStatus := func(errno int) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{
+			GlobalVariables: dbop.GlobalVariables{
+				ExecutedGTID:         "1234",
+				ReadOnly:             true,
+				SuperReadOnly:        true,
+				SemiSyncSlaveEnabled: true,
+			},
+			ReplicaStatus: &dbop.ReplicaStatus{
+				MasterHost:     cluster.PodHostname(0),
+				SlaveIORunning: "Yes",
+				AutoPosition:   "1",
+				LastIoErrno:    errno,
+			},
+		}
+	}
+
+	op := &replicaOperator{}
+	p := &managerProcess{recorder: record.NewFakeRecorder(10), ioErrors: make(map[int]int)}
+	ss := &StatusSet{
+		Cluster:     cluster,
+		Password:    passwd,
+		Primary:     0,
+		MySQLStatus: []*dbop.MySQLInstanceStatus{nil, nil, nil},
+		DBOps:       []dbop.Operator{nil, op, nil},
+	}
+	check := func(errno int) {
+		t.Helper()
+		ss.MySQLStatus[1] = newStatus(errno)
+		if _, err := p.configureReplica(context.Background(), ss, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ignoring transient errors.
+	check(2003)
+	check(0)
+	check(2003)
+	check(1045)
+	if len(op.sources) != 0 {
+		t.Fatalf("replica should not be reconfigured: %v", op.sources)
+	}
+
+	// reconfiguring a replica whose error persists.
+	check(1045)
+	if len(op.sources) != 1 || op.sources[0] != cluster.PodHostname(0) {
+		t.Fatalf("replica should be reconfigured: %v", op.sources)
+	}
+
+	// the error should persist again after the reconfiguration.
+	check(1045)
+	if len(op.sources) != 1 {
+		t.Fatalf("replica should not be reconfigured: %v", op.sources)
+	}
+	check(1045)
+	if len(op.sources) != 2 {
+		t.Fatalf("replica should be reconfigured: %v", op.sources)
+	}
+}
+
+func TestConfigureReplicaSelfReplication(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newStatus := func(source string) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{
+			GlobalVariables: dbop.GlobalVariables{
+				ExecutedGTID:         "1234",
+				ReadOnly:             true,
+				SuperReadOnly:        true,
+				SemiSyncSlaveEnabled: true,
+			},
+			ReplicaStatus: &dbop.ReplicaStatus{MasterHost: source, SlaveIORunning: "Yes", AutoPosition: "1"},
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		source      string
+		expectRedo  bool
+		expectEvent bool
+	}{
+		{
+			name:   "replicating-from-primary",
+			source: cluster.PodHostname(0),
+		},
+		{
+			name:        "replicating-from-itself",
+			source:      cluster.PodHostname(1),
+			expectRedo:  true,
+			expectEvent: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			op := &replicaOperator{}
+			recorder := record.NewFakeRecorder(10)
+			p := &managerProcess{recorder: recorder, ioErrors: make(map[int]int)}
+			ss := &StatusSet{
+				Cluster:     cluster,
+				Password:    passwd,
+				Primary:     0,
+				MySQLStatus: []*dbop.MySQLInstanceStatus{newStatus(""), newStatus(tc.source), newStatus(cluster.PodHostname(0))},
+				DBOps:       []dbop.Operator{nil, op, nil},
+			}
+
+			redo, err := p.configureReplica(context.Background(), ss, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if redo != tc.expectRedo {
+				t.Errorf("unexpected redo: expected=%v, actual=%v", tc.expectRedo, redo)
+			}
+			if tc.expectRedo {
+				if len(op.sources) != 1 || op.sources[0] != cluster.PodHostname(0) {
+					t.Errorf("replica should be reconfigured to the primary: %v", op.sources)
+				}
+			} else if len(op.sources) != 0 {
+				t.Errorf("replica should not be reconfigured: %v", op.sources)
+			}
+
+			select {
+			case ev := <-recorder.Events:
+				if !tc.expectEvent {
+					t.Errorf("unexpected event: %s", ev)
+				} else if !strings.HasPrefix(ev, corev1.EventTypeWarning+" ReplicaSelfReplication ") {
+					t.Errorf("unexpected event: %s", ev)
+				}
+			default:
+				if tc.expectEvent {
+					t.Error("no event was recorded")
+				}
+			}
+		})
+	}
+}
+
+func TestBreakFailoverTie(t *testing.T) {
+	newPod := func(zone, priority string) *corev1.Pod {
+		pod := &corev1.Pod{}
+		pod.Labels = map[string]string{}
+		pod.Annotations = map[string]string{}
+		if zone != "" {
+			pod.Labels[corev1.LabelTopologyZone] = zone
+		}
+		if priority != "" {
+			pod.Annotations[constants.AnnFailoverPriority] = priority
+		}
+		return pod
+	}
+	newReplica := func(gtid string) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{ReplicaStatus: &dbop.ReplicaStatus{ExecutedGtidSet: gtid}}
+	}
+
+	testCases := []struct {
+		name     string
+		rules    []mocov1beta2.FailoverTieBreaker
+		pods     []*corev1.Pod
+		expected int
+	}{
+		{
+			name:     "lowest-index",
+			pods:     []*corev1.Pod{newPod("", ""), newPod("", ""), newPod("", ""), newPod("", "")},
+			expected: 1,
+		},
+		{
+			name:     "priority",
+			pods:     []*corev1.Pod{newPod("a", ""), newPod("a", "1"), newPod("b", "10"), newPod("a", "")},
+			expected: 2,
+		},
+		{
+			name:     "invalid-priority",
+			pods:     []*corev1.Pod{newPod("", ""), newPod("", "high"), newPod("", "-1"), newPod("", "")},
+			expected: 1,
+		},
+		{
+			name:     "same-zone",
+			pods:     []*corev1.Pod{newPod("a", ""), newPod("b", ""), newPod("c", ""), newPod("a", "")},
+			expected: 3,
+		},
+		{
+			name:     "same-zone-after-priority",
+			pods:     []*corev1.Pod{newPod("a", ""), newPod("b", "1"), newPod("a", "1"), newPod("a", "")},
+			expected: 2,
+		},
+		{
+			name:     "no-replica-in-same-zone",
+			pods:     []*corev1.Pod{newPod("a", ""), newPod("b", ""), newPod("c", ""), newPod("d", "")},
+			expected: 1,
+		},
+		{
+			name:     "zone-before-priority",
+			rules:    []mocov1beta2.FailoverTieBreaker{mocov1beta2.FailoverTieBreakerSameZone, mocov1beta2.FailoverTieBreakerPriority},
+			pods:     []*corev1.Pod{newPod("a", ""), newPod("b", "10"), newPod("a", "1"), newPod("a", "2")},
+			expected: 3,
+		},
+		{
+			name:     "no-rules",
+			rules:    []mocov1beta2.FailoverTieBreaker{},
+			pods:     []*corev1.Pod{newPod("a", ""), newPod("b", "10"), newPod("a", "1"), newPod("a", "")},
+			expected: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &mocov1beta2.MySQLCluster{}
+			cluster.Spec.Replicas = 4
+			cluster.Spec.FailoverTieBreakers = tc.rules
+			ss := &StatusSet{Cluster: cluster, Primary: 0, Pods: tc.pods}
+
+			// instances 1 to 3 have the same most advanced GTID set.
+			candidates := []*dbop.MySQLInstanceStatus{nil, newReplica("p0:1-10"), newReplica("p0:1-10"), newReplica("p0:1-10")}
+			tops, err := dbop.FindTopRunners(context.Background(), &mockOperator{}, candidates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tops) != 3 {
+				t.Fatalf("unexpected top runners: %v", tops)
+			}
+
+			if actual := breakFailoverTie(ss, tops); actual != tc.expected {
+				t.Errorf("unexpected candidate: expected=%d, actual=%d", tc.expected, actual)
+			}
+		})
+	}
+
+	t.Run("not-tied", func(t *testing.T) {
+		candidates := []*dbop.MySQLInstanceStatus{nil, newReplica("p0:1-5"), newReplica("p0:1-5,p0:6-10"), newReplica("p0:1-5")}
+		tops, err := dbop.FindTopRunners(context.Background(), &mockOperator{}, candidates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tops) != 1 || tops[0] != 2 {
+			t.Errorf("unexpected top runners: %v", tops)
+		}
+	})
+}
+
+func TestEligibleTopRunners(t *testing.T) {
+	ss := &StatusSet{Excluded: []int{1}}
+
+	tops, err := eligibleTopRunners(ss, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tops, []int{2}) {
+		t.Errorf("unexpected top runners: %v", tops)
+	}
+
+	// a less advanced replica must not be chosen instead of the excluded one.
+	if _, err := eligibleTopRunners(ss, []int{1}); err == nil {
+		t.Error("an error should be returned")
+	}
+
+	ss.Excluded = nil
+	tops, err = eligibleTopRunners(ss, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tops, []int{1}) {
+		t.Errorf("unexpected top runners: %v", tops)
+	}
+}
+
+func TestFailoverExclusion(t *testing.T) {
+	newStatus := func(logBin bool, rs *dbop.ReplicaStatus) *dbop.MySQLInstanceStatus {
+		st := &dbop.MySQLInstanceStatus{ReplicaStatus: rs}
+		st.GlobalVariables.LogBin = logBin
+		return st
+	}
+
+	testCases := []struct {
+		name     string
+		status   *dbop.MySQLInstanceStatus
+		excluded bool
+	}{
+		{"healthy", newStatus(true, &dbop.ReplicaStatus{SlaveIORunning: "Yes", SlaveSQLRunning: "Yes"}), false},
+		{"no-replica-status", newStatus(true, nil), false},
+		{"io-error", newStatus(true, &dbop.ReplicaStatus{SlaveIORunning: "No", LastIoErrno: 2003, SlaveSQLRunning: "Yes"}), false},
+		{"sql-stopped", newStatus(true, &dbop.ReplicaStatus{SlaveIORunning: "No", SlaveSQLRunning: "No"}), false},
+		{"sql-error", newStatus(true, &dbop.ReplicaStatus{SlaveIORunning: "No", SlaveSQLRunning: "No", LastSQLErrno: 1062}), true},
+		{"binlog-disabled", newStatus(false, &dbop.ReplicaStatus{SlaveIORunning: "Yes", SlaveSQLRunning: "Yes"}), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason := failoverExclusion(tc.status)
+			if excluded := reason != ""; excluded != tc.excluded {
+				t.Errorf("unexpected exclusion: expected=%v, reason=%q", tc.excluded, reason)
+			}
+		})
+	}
+}
+
+func TestFailoverConnectionHeadroom(t *testing.T) {
+	newStatus := func(threads, maxConns int) *dbop.MySQLInstanceStatus {
+		st := &dbop.MySQLInstanceStatus{ThreadsConnected: threads}
+		st.GlobalVariables.MaxConnections = maxConns
+		return st
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 4
+	pods := []*corev1.Pod{{}, {}, {}, {}}
+	pods[1].Annotations = map[string]string{constants.AnnFailoverPriority: "10"}
+
+	recorder := record.NewFakeRecorder(10)
+	p := &managerProcess{recorder: recorder}
+
+	// the old primary is unreachable, and instance 1 is preferred by its priority
+	// but is near its connection capacity.
+	ss := &StatusSet{
+		Cluster:     cluster,
+		Primary:     0,
+		Pods:        pods,
+		MySQLStatus: []*dbop.MySQLInstanceStatus{nil, newStatus(95, 100), newStatus(10, 100), newStatus(10, 100)},
+	}
+	if actual := breakFailoverTie(ss, preferConnectionHeadroom(ss, []int{1, 2, 3})); actual != 2 {
+		t.Errorf("the replica near connection capacity should be deprioritized: %d", actual)
+	}
+	p.warnConnectionHeadroom(context.Background(), ss, 2)
+	select {
+	case ev := <-recorder.Events:
+		t.Errorf("unexpected event: %s", ev)
+	default:
+	}
+
+	// if no replica has enough headroom, the tie-breakers decide and a warning is recorded.
+	ss.MySQLStatus = []*dbop.MySQLInstanceStatus{nil, newStatus(95, 100), newStatus(99, 100), newStatus(100, 100)}
+	if actual := breakFailoverTie(ss, preferConnectionHeadroom(ss, []int{1, 2, 3})); actual != 1 {
+		t.Errorf("unexpected candidate: %d", actual)
+	}
+	p.warnConnectionHeadroom(context.Background(), ss, 1)
+	select {
+	case ev := <-recorder.Events:
+		if !strings.HasPrefix(ev, corev1.EventTypeWarning+" ConnectionHeadroomLow ") {
+			t.Errorf("unexpected event: %s", ev)
+		}
+	default:
+		t.Error("no event was recorded")
+	}
+}
+
+type fenceOperator struct {
+	dbop.NopOperator
+	calls []string
+}
+
+func (o *fenceOperator) KillConnections(ctx context.Context) error {
+	o.calls = append(o.calls, "kill")
+	return nil
+}
+
+func (o *fenceOperator) SetReadOnly(ctx context.Context, readOnly bool) error {
+	if readOnly {
+		o.calls = append(o.calls, "read-only")
+	} else {
+		o.calls = append(o.calls, "writable")
+	}
+	return nil
+}
+
+func (o *fenceOperator) ConfigureReplica(ctx context.Context, source dbop.AccessInfo, semisync bool) error {
+	o.calls = append(o.calls, "replicate")
+	return nil
+}
+
+func (o *fenceOperator) EnsureReplicationUser(ctx context.Context, user, password string) error {
+	o.calls = append(o.calls, "user:"+user)
+	return nil
+}
+
+func TestFenceOldPrimary(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+	cluster.Spec.FenceOldPrimaryOnFailover = true
+
+	t.Run("reachable", func(t *testing.T) {
+		op := &fenceOperator{}
+		recorder := record.NewFakeRecorder(10)
+		p := &managerProcess{recorder: recorder}
+		ss := &StatusSet{Cluster: cluster, Primary: 1, DBOps: []dbop.Operator{nil, op, nil}}
+
+		p.fenceOldPrimary(context.Background(), ss)
+		if strings.Join(op.calls, ",") != "kill,read-only" {
+			t.Errorf("unexpected operations: %v", op.calls)
+		}
+		select {
+		case ev := <-recorder.Events:
+			t.Errorf("unexpected event: %s", ev)
+		default:
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		p := &managerProcess{recorder: recorder}
+		ss := &StatusSet{Cluster: cluster, Primary: 1, DBOps: []dbop.Operator{nil, dbop.NopOperator{}, nil}}
+
+		p.fenceOldPrimary(context.Background(), ss)
+		select {
+		case ev := <-recorder.Events:
+			if !strings.HasPrefix(ev, corev1.EventTypeWarning+" FencingFailed ") {
+				t.Errorf("unexpected event: %s", ev)
+			}
+		default:
+			t.Error("no event was recorded")
+		}
+	})
+}
+
+func TestNeedPrimaryConfiguration(t *testing.T) {
+	const timeout = dbop.DefaultSemiSyncMasterTimeout
+	configured := &dbop.MySQLInstanceStatus{
+		GlobalVariables: dbop.GlobalVariables{SemiSyncMasterEnabled: true, WaitForSlaveCount: 1, SemiSyncMasterTimeout: timeout},
+	}
+	if needPrimaryConfiguration(configured, 1, timeout) {
+		t.Error("configured primary should not be configured again")
+	}
+	if !needPrimaryConfiguration(configured, 2, timeout) {
+		t.Error("primary should be configured when the number of replicas changes")
+	}
+	if !needPrimaryConfiguration(configured, 1, 3000) {
+		t.Error("primary should be configured when the timeout changes")
+	}
+
+	// a single instance has no replica to wait for.
+	if !needPrimaryConfiguration(configured, 0, timeout) {
+		t.Error("semi-sync of a single instance should be disabled")
+	}
+	if needPrimaryConfiguration(&dbop.MySQLInstanceStatus{}, 0, timeout) {
+		t.Error("single instance without semi-sync should not be configured")
+	}
+
+	// global variables set by `SET GLOBAL` are reset to the defaults after a restart.
+	restarted := &dbop.MySQLInstanceStatus{
+		GlobalVariables: dbop.GlobalVariables{SemiSyncMasterEnabled: false, WaitForSlaveCount: 1, SemiSyncMasterTimeout: 10000},
+	}
+	if !needPrimaryConfiguration(restarted, 1, timeout) {
+		t.Error("restarted primary should be configured again")
+	}
+}
+
+func TestSemiSyncWaitForCount(t *testing.T) {
+	testCases := []struct {
+		name     string
+		replicas int32
+		count    *int32
+		expected int
+	}{
+		{name: "single-instance", replicas: 1, expected: 0},
+		{name: "default-3", replicas: 3, expected: 1},
+		{name: "default-5", replicas: 5, expected: 2},
+		{name: "specified", replicas: 3, count: pointer.Int32(2), expected: 2},
+		{name: "specified-single-instance", replicas: 1, count: pointer.Int32(1), expected: 0},
+		{name: "too-large", replicas: 5, count: pointer.Int32(5), expected: 4},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &mocov1beta2.MySQLCluster{}
+			cluster.Spec.Replicas = tc.replicas
+			cluster.Spec.SemiSyncWaitForCount = tc.count
+			if actual := semiSyncWaitForCount(cluster); actual != tc.expected {
+				t.Errorf("unexpected count: expected=%d, actual=%d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPrimaryNeedsConfiguration(t *testing.T) {
+	newHealthySS := func(replicas int32, intermediate bool) *StatusSet {
+		b := newSS(replicas, 0, intermediate, false, false, false)
+		for i := int32(0); i < replicas; i++ {
+			b = b.withPod(true, false, false)
+		}
+		primary := newMySQL("1234", intermediate, false, false)
+		for i := int32(1); i < replicas; i++ {
+			primary = primary.withReplica(10+i, "replica")
+		}
+		b = b.withMySQL(primary.build())
+		for i := int32(1); i < replicas; i++ {
+			b = b.withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build())
+		}
+		ss := b.build()
+		ss.DecideState()
+		return ss
+	}
+
+	testCases := []struct {
+		name         string
+		replicas     int32
+		intermediate bool
+		semisync     bool
+		waitForCount int
+		timeout      *int32
+		expected     bool
+	}{
+		{name: "semisync-enabled", replicas: 3, semisync: true, expected: false},
+		{name: "timeout-changed", replicas: 3, semisync: true, timeout: pointer.Int32(3), expected: true},
+		{name: "semisync-lost", replicas: 3, semisync: false, expected: true},
+		{name: "wait-for-count-drifted", replicas: 3, semisync: true, waitForCount: 2, expected: true},
+		{name: "single-instance", replicas: 1, semisync: false, expected: false},
+		{name: "single-instance-semisync-enabled", replicas: 1, semisync: true, expected: true},
+		{name: "intermediate", replicas: 3, intermediate: true, semisync: false, expected: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := newHealthySS(tc.replicas, tc.intermediate)
+			ss.Cluster.Spec.SemiSyncTimeoutSeconds = tc.timeout
+			if ss.State != StateHealthy {
+				t.Fatalf("unexpected state: %s", ss.State.String())
+			}
+			if tc.semisync {
+				pst := ss.MySQLStatus[ss.Primary]
+				pst.GlobalVariables.SemiSyncMasterEnabled = true
+				pst.GlobalVariables.WaitForSlaveCount = int(tc.replicas / 2)
+				pst.GlobalVariables.SemiSyncMasterTimeout = dbop.DefaultSemiSyncMasterTimeout
+				if tc.waitForCount != 0 {
+					pst.GlobalVariables.WaitForSlaveCount = tc.waitForCount
+				}
+			}
+			if actual := primaryNeedsConfiguration(ss); actual != tc.expected {
+				t.Errorf("unexpected result: expected=%v, actual=%v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReplicationLag(t *testing.T) {
+	newSSWithLag := func(maxLag int32) *StatusSet {
+		ss := newSS(3, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(newMySQL("1234", false, false, false).build()).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			build()
+		ss.Cluster.Spec.MaxReplicationLagSeconds = maxLag
+		for i, delay := range []int64{0, 100, 5} {
+			if i == ss.Primary {
+				continue
+			}
+			ist := ss.MySQLStatus[i]
+			ist.GlobalVariables.SemiSyncSlaveEnabled = true
+			ist.ReplicaStatus.SecondsBehindMaster = sql.NullInt64{Int64: delay, Valid: true}
+		}
+		return ss
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		ss := newSSWithLag(0)
+		if isLagging(ss, 1) {
+			t.Error("the lag check should be disabled")
+		}
+		if replicas := lagChangedReplicas(ss); len(replicas) != 0 {
+			t.Errorf("unexpected replicas: %v", replicas)
+		}
+		cond := outOfSyncCondition(ss)
+		if cond.Status != metav1.ConditionFalse || cond.Reason != "LagCheckDisabled" {
+			t.Errorf("unexpected condition: %+v", cond)
+		}
+	})
+
+	t.Run("lagging", func(t *testing.T) {
+		ss := newSSWithLag(30)
+		if !isLagging(ss, 1) {
+			t.Error("instance 1 should be lagging")
+		}
+		if isLagging(ss, 2) {
+			t.Error("instance 2 should not be lagging")
+		}
+		if replicas := lagChangedReplicas(ss); !slices.Equal(replicas, []int{1}) {
+			t.Errorf("unexpected replicas: %v", replicas)
+		}
+		cond := outOfSyncCondition(ss)
+		if cond.Status != metav1.ConditionTrue || cond.Reason != "ReplicationLagExceeded" {
+			t.Errorf("unexpected condition: %+v", cond)
+		}
+		if cond.Message != "replicas delayed over the threshold: 1" {
+			t.Errorf("unexpected condition message: %s", cond.Message)
+		}
+
+		// after semi-sync is disabled on the lagging replica, nothing needs to be changed.
+		ss.MySQLStatus[1].GlobalVariables.SemiSyncSlaveEnabled = false
+		if replicas := lagChangedReplicas(ss); len(replicas) != 0 {
+			t.Errorf("unexpected replicas: %v", replicas)
+		}
+	})
+
+	t.Run("caught-up", func(t *testing.T) {
+		ss := newSSWithLag(30)
+		ss.MySQLStatus[1].ReplicaStatus.SecondsBehindMaster.Int64 = 0
+		ss.MySQLStatus[2].GlobalVariables.SemiSyncSlaveEnabled = false
+		if replicas := lagChangedReplicas(ss); !slices.Equal(replicas, []int{2}) {
+			t.Errorf("unexpected replicas: %v", replicas)
+		}
+		cond := outOfSyncCondition(ss)
+		if cond.Status != metav1.ConditionFalse || cond.Reason != "ReplicasInSync" {
+			t.Errorf("unexpected condition: %+v", cond)
+		}
+	})
+}
+
+func TestPrimaryWaitForCount(t *testing.T) {
+	newSSWithReplicas := func(policy mocov1beta2.AllReplicasDownPolicy, replicasUp, primaryReadOnly bool) *StatusSet {
+		b := newSS(3, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(replicasUp, false, false).
+			withPod(replicasUp, false, false).
+			withMySQL(newMySQL("1234", primaryReadOnly, false, false).build())
+		for i := 0; i < 2; i++ {
+			if replicasUp {
+				b = b.withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build())
+			} else {
+				b = b.withMySQL(nil)
+			}
+		}
+		ss := b.build()
+		ss.Cluster.Spec.AllReplicasDownPolicy = policy
+		ss.DecideState()
+		return ss
+	}
+
+	testCases := []struct {
+		name            string
+		policy          mocov1beta2.AllReplicasDownPolicy
+		replicasUp      bool
+		primaryReadOnly bool
+		expectDown      bool
+		expectCount     int
+	}{
+		{name: "halt-replicas-up", policy: mocov1beta2.AllReplicasDownPolicyHalt, replicasUp: true, expectCount: 1},
+		{name: "halt-replicas-down", policy: mocov1beta2.AllReplicasDownPolicyHalt, expectDown: true, expectCount: 1},
+		{name: "default-replicas-down", expectDown: true, expectCount: 1},
+		{name: "continue-replicas-up", policy: mocov1beta2.AllReplicasDownPolicyContinue, replicasUp: true, expectCount: 1},
+		{name: "continue-replicas-down", policy: mocov1beta2.AllReplicasDownPolicyContinue, expectDown: true, expectCount: 0},
+		{name: "continue-read-only-primary", policy: mocov1beta2.AllReplicasDownPolicyContinue, primaryReadOnly: true, expectCount: 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := newSSWithReplicas(tc.policy, tc.replicasUp, tc.primaryReadOnly)
+			if down := allReplicasDown(ss); down != tc.expectDown {
+				t.Errorf("unexpected allReplicasDown: expected=%v, actual=%v", tc.expectDown, down)
+			}
+			if count := primaryWaitForCount(ss); count != tc.expectCount {
+				t.Errorf("unexpected wait for count: expected=%d, actual=%d", tc.expectCount, count)
+			}
+			cond := allReplicasDownCondition(ss)
+			if (cond.Status == metav1.ConditionTrue) != tc.expectDown {
+				t.Errorf("unexpected condition status: %s", cond.Status)
+			}
+			if tc.expectDown && cond.Reason != "AllReplicasDown" || !tc.expectDown && cond.Reason != "ReplicasAvailable" {
+				t.Errorf("unexpected condition reason: %s", cond.Reason)
+			}
+		})
+	}
+
+	t.Run("semisync-disabled-while-replicas-down", func(t *testing.T) {
+		ss := newSSWithReplicas(mocov1beta2.AllReplicasDownPolicyContinue, false, false)
+		pst := ss.MySQLStatus[ss.Primary]
+		pst.GlobalVariables.SemiSyncMasterEnabled = true
+		pst.GlobalVariables.WaitForSlaveCount = 1
+		pst.GlobalVariables.SemiSyncMasterTimeout = dbop.DefaultSemiSyncMasterTimeout
+		if !primaryNeedsConfiguration(ss) {
+			t.Error("semi-sync of the primary should be disabled")
+		}
+		pst.GlobalVariables.SemiSyncMasterEnabled = false
+		pst.GlobalVariables.WaitForSlaveCount = 0
+		if primaryNeedsConfiguration(ss) {
+			t.Error("the primary should not be reconfigured")
+		}
+	})
+}
+
+func TestWriteWaitDuration(t *testing.T) {
+	since := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name      string
+		minUptime int32
+		elapsed   time.Duration
+		expected  time.Duration
+	}{
+		{name: "disabled", minUptime: 0, elapsed: 0, expected: 0},
+		{name: "held", minUptime: 60, elapsed: 10 * time.Second, expected: 50 * time.Second},
+		{name: "just-before", minUptime: 60, elapsed: 59 * time.Second, expected: time.Second},
+		{name: "reached", minUptime: 60, elapsed: 60 * time.Second, expected: 0},
+		{name: "passed", minUptime: 60, elapsed: 10 * time.Minute, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &mocov1beta2.MySQLCluster{}
+			cluster.Spec.MinPrimaryUptimeBeforeWritesSeconds = tc.minUptime
+			actual := writeWaitDuration(cluster, since, since.Add(tc.elapsed))
+			if actual != tc.expected {
+				t.Errorf("unexpected wait: expected=%v, actual=%v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMakePrimaryWritableMinUptime(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 1
+	cluster.Spec.MinPrimaryUptimeBeforeWritesSeconds = 60
+	cluster.Status.PrimarySince = &metav1.Time{Time: time.Now().Add(-50 * time.Second)}
+
+	pst := &dbop.MySQLInstanceStatus{}
+	pst.GlobalVariables.ReadOnly = true
+	pst.GlobalVariables.SuperReadOnly = true
+
+	op := &fenceOperator{}
+	p := &managerProcess{recorder: record.NewFakeRecorder(10), ch: make(chan string, 1)}
+	defer p.stopWakeUp()
+	ss := &StatusSet{Cluster: cluster, Primary: 0, MySQLStatus: []*dbop.MySQLInstanceStatus{pst}, DBOps: []dbop.Operator{op}}
+
+	// writes are held until the primary has been the primary for the duration.
+	var timers []*time.Timer
+	for i := 0; i < 3; i++ {
+		redo, err := p.makePrimaryWritable(context.Background(), ss)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if redo || len(op.calls) != 0 {
+			t.Fatalf("the primary was made writable too early: %v", op.calls)
+		}
+		timers = append(timers, p.wakeUpTimer)
+	}
+	if !slices.Contains(ss.Deferred, "SetWritable") {
+		t.Errorf("making the primary writable should be reported as deferred: %v", ss.Deferred)
+	}
+	// only the last check is scheduled.
+	for _, timer := range timers[:len(timers)-1] {
+		if timer.Stop() {
+			t.Error("the previous timer was not stopped")
+		}
+	}
+
+	// the time is taken from the status, so it survives restarts of the controller.
+	cluster.Status.PrimarySince = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+	p = &managerProcess{recorder: record.NewFakeRecorder(10)}
+	redo, err := p.makePrimaryWritable(context.Background(), ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !redo || strings.Join(op.calls, ",") != "writable" {
+		t.Errorf("the primary should be made writable: %v", op.calls)
+	}
+	if p.wakeUpTimer != nil {
+		t.Error("no check should be scheduled")
+	}
+}
+
+func TestMakePrimaryWritableWhileCloning(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 1
+
+	op := &fenceOperator{}
+	pst := &dbop.MySQLInstanceStatus{}
+	pst.GlobalVariables.ReadOnly = true
+	pst.GlobalVariables.SuperReadOnly = true
+	pst.CloneStatus = &dbop.CloneStatus{State: sql.NullString{Valid: true, String: "In Progress"}, EstimatedProgress: 42}
+
+	recorder := record.NewFakeRecorder(10)
+	p := &managerProcess{recorder: recorder}
+	ss := &StatusSet{Cluster: cluster, Primary: 0, MySQLStatus: []*dbop.MySQLInstanceStatus{pst}, DBOps: []dbop.Operator{op}}
+
+	redo, err := p.makePrimaryWritable(context.Background(), ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redo || len(op.calls) != 0 {
+		t.Errorf("the primary was made writable while it is being cloned: %v", op.calls)
+	}
+	select {
+	case ev := <-recorder.Events:
+		t.Errorf("unexpected event: %s", ev)
+	default:
+	}
+
+	pst.CloneStatus.State.String = "Completed"
+	redo, err = p.makePrimaryWritable(context.Background(), ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !redo || strings.Join(op.calls, ",") != "writable" {
+		t.Errorf("the primary should be made writable after the clone completed: %v", op.calls)
+	}
+	select {
+	case ev := <-recorder.Events:
+		if !strings.HasPrefix(ev, corev1.EventTypeNormal+" "+event.SetWritable.Reason+" ") {
+			t.Errorf("unexpected event: %s", ev)
+		}
+	default:
+		t.Error("no event was recorded")
+	}
+}
+
+func TestOperationsWhileRecovering(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 2
+
+	op0 := &fenceOperator{}
+	op1 := &fenceOperator{}
+	pst := &dbop.MySQLInstanceStatus{RecoveredTransactions: 5}
+	pst.GlobalVariables.ReadOnly = true
+	pst.GlobalVariables.SuperReadOnly = true
+	rst := &dbop.MySQLInstanceStatus{RecoveredTransactions: 1}
+	rst.GlobalVariables.ReadOnly = true
+	rst.GlobalVariables.SuperReadOnly = true
+
+	p := &managerProcess{recorder: record.NewFakeRecorder(10)}
+	ss := &StatusSet{
+		Cluster:     cluster,
+		Primary:     0,
+		MySQLStatus: []*dbop.MySQLInstanceStatus{pst, rst},
+		DBOps:       []dbop.Operator{op0, op1},
+	}
+
+	redo, err := p.configurePrimary(context.Background(), ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redo || len(op0.calls) != 0 {
+		t.Errorf("the recovering primary was configured: %v", op0.calls)
+	}
+	redo, err = p.makePrimaryWritable(context.Background(), ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redo || len(op0.calls) != 0 {
+		t.Errorf("the recovering primary was made writable: %v", op0.calls)
+	}
+	redo, err = p.configureReplica(context.Background(), ss, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redo || len(op1.calls) != 0 {
+		t.Errorf("the recovering replica was configured: %v", op1.calls)
+	}
+
+	pst.RecoveredTransactions = 0
+	redo, err = p.makePrimaryWritable(context.Background(), ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !redo || strings.Join(op0.calls, ",") != "writable" {
+		t.Errorf("the primary should be made writable after the recovery: %v", op0.calls)
+	}
+}
+
+func TestMakePrimaryWritableWithWritableReplica(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+	getCondition := func() *metav1.Condition {
+		t.Helper()
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: "test"}, cluster); err != nil {
+			t.Fatal(err)
+		}
+		return meta.FindStatusCondition(cluster.Status.Conditions, mocov1beta2.ConditionConstraintsViolated)
+	}
+
+	statuses := make([]*dbop.MySQLInstanceStatus, 3)
+	for i := range statuses {
+		statuses[i] = &dbop.MySQLInstanceStatus{}
+		statuses[i].GlobalVariables.ReadOnly = true
+		statuses[i].GlobalVariables.SuperReadOnly = true
+	}
+	statuses[2].GlobalVariables.ReadOnly = false
+	statuses[2].GlobalVariables.SuperReadOnly = false
+
+	op := &fenceOperator{}
+	rop := &fenceOperator{}
+	p := &managerProcess{
+		client:   c,
+		reader:   c,
+		recorder: record.NewFakeRecorder(10),
+		name:     types.NamespacedName{Namespace: "ns", Name: "test"},
+	}
+	ss := &StatusSet{Cluster: cluster, Primary: 0, MySQLStatus: statuses, DBOps: []dbop.Operator{op, nil, rop}}
+
+	// a writable replica blocks the primary and is left as is.
+	_, err := p.makePrimaryWritable(context.Background(), ss)
+	if !errors.Is(err, errConstraintsViolation) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(op.calls) != 0 {
+		t.Errorf("the primary was made writable while a replica is writable: %v", op.calls)
+	}
+	if len(rop.calls) != 0 {
+		t.Errorf("the writable replica was modified: %v", rop.calls)
+	}
+	cond := getCondition()
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != reasonWritableReplicas {
+		t.Fatalf("ConstraintsViolated condition is not set: %v", cond)
+	}
+
+	// once the replica becomes read-only, the violation is cleared and the primary is made writable.
+	ss.Cluster.Status.Conditions = []metav1.Condition{*cond}
+	statuses[2].GlobalVariables.ReadOnly = true
+	statuses[2].GlobalVariables.SuperReadOnly = true
+	if _, err := p.makePrimaryWritable(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	cond = getCondition()
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "ConstraintsSatisfied" {
+		t.Errorf("ConstraintsViolated condition is not cleared: %v", cond)
+	}
+	ss.Cluster.Status.Conditions = nil
+	if len(rop.calls) != 0 {
+		t.Errorf("unexpected operations on the replica: %v", rop.calls)
+	}
+	if strings.Join(op.calls, ",") != "writable" {
+		t.Errorf("unexpected operations: %v", op.calls)
+	}
+
+	// unreachable replicas are not checked.
+	statuses[2] = nil
+	op.calls = nil
+	if _, err := p.makePrimaryWritable(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(op.calls, ",") != "writable" {
+		t.Errorf("unexpected operations: %v", op.calls)
+	}
+}
+
+func TestMakePrimaryWritableSuperReadOnly(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 1
+
+	// an external actor may leave only super_read_only ON.
+	pst := &dbop.MySQLInstanceStatus{}
+	pst.GlobalVariables.ReadOnly = false
+	pst.GlobalVariables.SuperReadOnly = true
+
+	op := &fenceOperator{}
+	p := &managerProcess{recorder: record.NewFakeRecorder(10)}
+	ss := &StatusSet{Cluster: cluster, Primary: 0, MySQLStatus: []*dbop.MySQLInstanceStatus{pst}, DBOps: []dbop.Operator{op}}
+
+	redo, err := p.makePrimaryWritable(context.Background(), ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !redo || strings.Join(op.calls, ",") != "writable" {
+		t.Errorf("the primary should be made writable: %v", op.calls)
+	}
+
+	// nothing is done for a writable primary.
+	op.calls = nil
+	pst.GlobalVariables.SuperReadOnly = false
+	redo, err = p.makePrimaryWritable(context.Background(), ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redo || len(op.calls) != 0 {
+		t.Errorf("unexpected operations: %v", op.calls)
+	}
+}
+
+func TestEnsureReplicationUser(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 1
+	cluster.Spec.ReplicationUser = "tenant_repl"
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := passwd.AddReplicator("tenant_repl"); err != nil {
+		t.Fatal(err)
+	}
+
+	pst := &dbop.MySQLInstanceStatus{}
+	op := &fenceOperator{}
+	p := &managerProcess{recorder: record.NewFakeRecorder(10)}
+	ss := &StatusSet{Cluster: cluster, Password: passwd, Primary: 0, MySQLStatus: []*dbop.MySQLInstanceStatus{pst}, DBOps: []dbop.Operator{op}}
+
+	// the user is created on a primary that is writable already.
+	for i := 0; i < 2; i++ {
+		if _, err := p.configurePrimary(context.Background(), ss); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(op.calls, ",") != "user:tenant_repl" {
+		t.Errorf("the user should be ensured once: %v", op.calls)
+	}
+	pwd, _ := passwd.ReplicatorOf("tenant_repl")
+	if strings.Contains(p.replicationUserKey, pwd) {
+		t.Errorf("the password is kept in plaintext: %s", p.replicationUserKey)
+	}
+
+	// the user is ensured again for a new password.
+	op.calls = nil
+	passwd2, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := passwd2.AddReplicator("tenant_repl"); err != nil {
+		t.Fatal(err)
+	}
+	ss.Password = passwd2
+	if _, err := p.configurePrimary(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(op.calls, ",") != "user:tenant_repl" {
+		t.Errorf("the user should be ensured for the new password: %v", op.calls)
+	}
+
+	// a read-only primary gets the user when it is made writable.
+	op.calls = nil
+	p = &managerProcess{recorder: record.NewFakeRecorder(10)}
+	pst.GlobalVariables.ReadOnly = true
+	pst.GlobalVariables.SuperReadOnly = true
+	if _, err := p.configurePrimary(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	if len(op.calls) != 0 {
+		t.Errorf("unexpected operations: %v", op.calls)
+	}
+	if _, err := p.makePrimaryWritable(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(op.calls, ",") != "writable,user:tenant_repl" {
+		t.Errorf("unexpected operations: %v", op.calls)
+	}
+}
+
+func TestMakePrimaryWritableWithoutStatus(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+
+	op := &fenceOperator{}
+	p := &managerProcess{recorder: record.NewFakeRecorder(10)}
+	ss := &StatusSet{Cluster: cluster, Primary: 0, MySQLStatus: make([]*dbop.MySQLInstanceStatus, 3), DBOps: []dbop.Operator{op, nil, nil}}
+
+	_, err := p.makePrimaryWritable(context.Background(), ss)
+	if err == nil {
+		t.Error("the primary without status should not be made writable")
+	}
+	if len(op.calls) != 0 {
+		t.Errorf("unexpected operations: %v", op.calls)
+	}
+	if writable := writableReplicas(ss); len(writable) != 0 {
+		t.Errorf("replicas without status should be skipped: %v", writable)
+	}
+}
+
+func TestConfigureReplicaRetryExhausted(t *testing.T) {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newStatus := func(ioRunning string, errno int) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{
+			GlobalVariables: dbop.GlobalVariables{
+				ExecutedGTID:         "1234",
+				ReadOnly:             true,
+				SuperReadOnly:        true,
+				SemiSyncSlaveEnabled: true,
+			},
+			ReplicaStatus: &dbop.ReplicaStatus{
+				MasterHost:     cluster.PodHostname(0),
+				SlaveIORunning: ioRunning,
+				LastIoErrno:    errno,
+				AutoPosition:   "1",
+			},
+		}
+	}
+
+	op := &replicaOperator{}
+	recorder := record.NewFakeRecorder(10)
+	p := &managerProcess{recorder: recorder, ioThreadRevivals: make(map[int]int), ioErrors: make(map[int]int)}
+	ss := &StatusSet{
+		Cluster:     cluster,
+		Password:    passwd,
+		Primary:     0,
+		MySQLStatus: []*dbop.MySQLInstanceStatus{nil, newStatus("No", 2003), nil},
+		DBOps:       []dbop.Operator{nil, op, nil},
+	}
+
+	for i := 1; i <= maxIOThreadRevivals; i++ {
+		redo, err := p.configureReplica(context.Background(), ss, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !redo {
+			t.Error("redo should be true after reviving the IO thread")
+		}
+		if op.ioStarts != i {
+			t.Errorf("IO thread should be started %d times: %d", i, op.ioStarts)
+		}
+		if len(op.sources) != 0 {
+			t.Errorf("replica should not be reconfigured: %v", op.sources)
+		}
+	}
+	select {
+	case ev := <-recorder.Events:
+		t.Errorf("unexpected event: %s", ev)
+	default:
+	}
+
+	// once the cap is reached, MOCO gives up reviving and re-configures the replica.
+	for i := 0; i < 2; i++ {
+		redo, err := p.configureReplica(context.Background(), ss, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !redo {
+			t.Error("redo should be true after re-configuring the replica")
+		}
+	}
+	if op.ioStarts != maxIOThreadRevivals {
+		t.Errorf("IO thread should not be started beyond the cap: %d", op.ioStarts)
+	}
+	if len(op.sources) != 2 {
+		t.Errorf("replica should be reconfigured: %v", op.sources)
+	}
+	select {
+	case ev := <-recorder.Events:
+		if !strings.HasPrefix(ev, corev1.EventTypeWarning+" ReplicaIOThreadExhausted ") {
+			t.Errorf("unexpected event: %s", ev)
+		}
+	default:
+		t.Error("no event was recorded")
+	}
+	select {
+	case ev := <-recorder.Events:
+		t.Errorf("the event should be recorded only once: %s", ev)
+	default:
+	}
+
+	// a replica that has recovered gets its own retry budget again.
+	ss.MySQLStatus[1] = newStatus("Yes", 0)
+	if _, err := p.configureReplica(context.Background(), ss, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.ioThreadRevivals[1]; ok {
+		t.Error("the revival count should be reset")
+	}
+
+	// an IO thread that is still retrying is left alone.
+	ss.MySQLStatus[1] = newStatus("Connecting", 2003)
+	op.ioStarts = 0
+	if _, err := p.configureReplica(context.Background(), ss, 1); err != nil {
+		t.Fatal(err)
+	}
+	if op.ioStarts != 0 {
+		t.Errorf("IO thread that is retrying should not be started: %d", op.ioStarts)
+	}
+}
+
+func TestConfigureReplicaSQLErrorRecovery(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 3
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newStatus := func(sqlRunning string, errno int) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{
+			GlobalVariables: dbop.GlobalVariables{
+				ExecutedGTID:         "1234",
+				ReadOnly:             true,
+				SuperReadOnly:        true,
+				SemiSyncSlaveEnabled: true,
+			},
+			ReplicaStatus: &dbop.ReplicaStatus{
+				MasterHost:      cluster.PodHostname(0),
+				SlaveIORunning:  "Yes",
+				SlaveSQLRunning: sqlRunning,
+				LastSQLErrno:    errno,
+				AutoPosition:    "1",
+			},
+		}
+	}
+
+	op := &replicaOperator{}
+	recorder := record.NewFakeRecorder(10)
+	p := &managerProcess{
+		client:           c,
+		reader:           c,
+		recorder:         recorder,
+		name:             types.NamespacedName{Namespace: "ns", Name: "test"},
+		ioThreadRevivals: make(map[int]int),
+		ioErrors:         make(map[int]int),
+	}
+	reconcile := func(st *dbop.MySQLInstanceStatus) bool {
+		t.Helper()
+		// the status is read again in each reconciliation.
+		current := &mocov1beta2.MySQLCluster{}
+		if err := c.Get(context.Background(), p.name, current); err != nil {
+			t.Fatal(err)
+		}
+		ss := &StatusSet{
+			Cluster:     current,
+			Password:    passwd,
+			Primary:     0,
+			MySQLStatus: []*dbop.MySQLInstanceStatus{nil, st, nil},
+			DBOps:       []dbop.Operator{nil, op, nil},
+		}
+		redo, err := p.configureReplica(context.Background(), ss, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return redo
+	}
+	recoveries := func() []mocov1beta2.ReplicaRecoveryStatus {
+		t.Helper()
+		current := &mocov1beta2.MySQLCluster{}
+		if err := c.Get(context.Background(), p.name, current); err != nil {
+			t.Fatal(err)
+		}
+		return current.Status.ReplicaRecoveries
+	}
+
+	// a permanent error is left alone.
+	if reconcile(newStatus("No", 1062)) {
+		t.Error("redo should be false for a permanent error")
+	}
+	if op.resets != 0 || len(recoveries()) != 0 {
+		t.Errorf("replica with a permanent error should not be reset: %d, %v", op.resets, recoveries())
+	}
+
+	for i := 1; i <= maxReplicaRecoveries; i++ {
+		if !reconcile(newStatus("No", 1205)) {
+			t.Error("redo should be true after resetting the replica")
+		}
+		if op.resets != i {
+			t.Errorf("replica should be reset %d times: %d", i, op.resets)
+		}
+		expected := []mocov1beta2.ReplicaRecoveryStatus{{Index: 1, Attempts: i, LastSQLErrno: 1205}}
+		if diff := cmp.Diff(expected, recoveries()); diff != "" {
+			t.Errorf("unexpected recoveries (-want +got):\n%s", diff)
+		}
+		select {
+		case ev := <-recorder.Events:
+			if !strings.HasPrefix(ev, corev1.EventTypeNormal+" ReplicaReset ") {
+				t.Errorf("unexpected event: %s", ev)
+			}
+		default:
+			t.Error("no event was recorded")
+		}
+	}
+
+	// once the cap is reached, MOCO gives up.
+	for i := 0; i < 2; i++ {
+		reconcile(newStatus("No", 1205))
+	}
+	if op.resets != maxReplicaRecoveries {
+		t.Errorf("replica should not be reset beyond the cap: %d", op.resets)
+	}
+	if attempts := recoveries()[0].Attempts; attempts != maxReplicaRecoveries+1 {
+		t.Errorf("unexpected attempts: %d", attempts)
+	}
+	select {
+	case ev := <-recorder.Events:
+		if !strings.HasPrefix(ev, corev1.EventTypeWarning+" ReplicaRecoveryExhausted ") {
+			t.Errorf("unexpected event: %s", ev)
+		}
+	default:
+		t.Error("no event was recorded")
+	}
+	select {
+	case ev := <-recorder.Events:
+		t.Errorf("the event should be recorded only once: %s", ev)
+	default:
+	}
+}
+
+type switchoverOperator struct {
+	dbop.NopOperator
+	calls []string
+}
+
+func (o *switchoverOperator) SetReadOnly(ctx context.Context, readOnly bool) error {
+	if readOnly {
+		o.calls = append(o.calls, "read-only")
+	} else {
+		o.calls = append(o.calls, "writable")
+	}
+	return nil
+}
+
+func (o *switchoverOperator) KillConnections(ctx context.Context) error {
+	o.calls = append(o.calls, "kill")
+	return nil
+}
+
+func (o *switchoverOperator) GetStatus(ctx context.Context) (*dbop.MySQLInstanceStatus, error) {
+	return &dbop.MySQLInstanceStatus{GlobalVariables: dbop.GlobalVariables{ExecutedGTID: "p:1-10"}}, nil
+}
+
+func (o *switchoverOperator) WaitForGTID(ctx context.Context, gtidSet string, timeoutSeconds int) error {
+	o.calls = append(o.calls, "wait")
+	return dbop.ErrTimeout
+}
+
+func TestSwitchoverAbort(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Annotations = map[string]string{constants.AnnSwitchover: "1"}
+	cluster.Spec.Replicas = 3
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build()
+
+	primary := &switchoverOperator{}
+	target := &switchoverOperator{}
+	p := &managerProcess{client: c, reader: c, name: types.NamespacedName{Namespace: "ns", Name: "test"}}
+	ss := &StatusSet{
+		Cluster:           cluster,
+		Primary:           0,
+		Candidate:         1,
+		NeedSwitch:        true,
+		PlannedSwitchover: true,
+		MySQLStatus:       make([]*dbop.MySQLInstanceStatus, 3),
+		DBOps:             []dbop.Operator{primary, target, nil},
+	}
+
+	err := p.switchover(context.Background(), ss)
+	if !errors.Is(err, dbop.ErrTimeout) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(primary.calls, ",") != "read-only,kill,writable" {
+		t.Errorf("the old primary should be writable again: %v", primary.calls)
+	}
+	if strings.Join(target.calls, ",") != "wait" {
+		t.Errorf("unexpected operations on the target: %v", target.calls)
+	}
+
+	updated := &mocov1beta2.MySQLCluster{}
+	if err := c.Get(context.Background(), p.name, updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := updated.Annotations[constants.AnnSwitchover]; ok {
+		t.Error("the switchover request should be removed")
+	}
+	if updated.Status.CurrentPrimaryIndex != 0 {
+		t.Errorf("the primary should not be changed: %d", updated.Status.CurrentPrimaryIndex)
+	}
+}
+
+type gapOperator struct {
+	dbop.NopOperator
+	contained bool
+	dataSize  int64
+}
+
+func (o *gapOperator) IsSubsetGTID(ctx context.Context, set1, set2 string) (bool, error) {
+	return o.contained, nil
+}
+
+func (o *gapOperator) GetDataSize(ctx context.Context) (int64, error) {
+	return o.dataSize, nil
+}
+
+func (o *gapOperator) GetStatus(ctx context.Context) (*dbop.MySQLInstanceStatus, error) {
+	return &dbop.MySQLInstanceStatus{}, nil
+}
+
+func (o *gapOperator) StartReplicaIOThread(ctx context.Context) error {
+	return nil
+}
+
+func (o *gapOperator) ConfigureReplica(ctx context.Context, source dbop.AccessInfo, semisync bool) error {
+	return nil
+}
+
+type cloneAgentFactory struct {
+	clones []int
+}
+
+type cloneAgentConn struct {
+	f     *cloneAgentFactory
+	index int
+}
+
+func (f *cloneAgentFactory) New(ctx context.Context, cluster *mocov1beta2.MySQLCluster, index int) (AgentConn, error) {
+	return cloneAgentConn{f: f, index: index}, nil
+}
+
+func (c cloneAgentConn) Clone(ctx context.Context, in *agent.CloneRequest, opts ...grpc.CallOption) (*agent.CloneResponse, error) {
+	c.f.clones = append(c.f.clones, c.index)
+	return &agent.CloneResponse{}, nil
+}
+
+func (c cloneAgentConn) Close() error {
+	return nil
+}
+
+func TestReCloneOnGap(t *testing.T) {
+	origWait, origInterval := waitForCloneRestartDuration, minReCloneInterval
+	t.Cleanup(func() {
+		waitForCloneRestartDuration, minReCloneInterval = origWait, origInterval
+	})
+	waitForCloneRestartDuration = 0
+	minReCloneInterval = time.Hour
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		enabled      bool
+		contained    bool
+		ioRunning    string
+		lastReClone  time.Duration
+		otherCloning bool
+		capacity     string
+		expectClone  bool
+		expectEvent  string
+		expectDefer  bool
+	}{
+		{name: "disabled", ioRunning: "No"},
+		{name: "gapped", enabled: true, ioRunning: "No", capacity: "10Gi", expectClone: true, expectEvent: "ReCloneStarted"},
+		{name: "unknown-capacity", enabled: true, ioRunning: "No", expectClone: true, expectEvent: "ReCloneStarted"},
+		{name: "not-gapped", enabled: true, contained: true, ioRunning: "No", capacity: "10Gi"},
+		{name: "io-running", enabled: true, ioRunning: "Yes", capacity: "10Gi"},
+		{name: "rate-limited", enabled: true, ioRunning: "No", lastReClone: 10 * time.Minute, capacity: "10Gi", expectDefer: true},
+		{name: "rate-limit-passed", enabled: true, ioRunning: "No", lastReClone: 2 * time.Hour, capacity: "10Gi", expectClone: true, expectEvent: "ReCloneStarted"},
+		{name: "other-cloning", enabled: true, ioRunning: "No", otherCloning: true, capacity: "10Gi", expectDefer: true},
+		{name: "insufficient-space", enabled: true, ioRunning: "No", capacity: "1Mi", expectEvent: "ReCloneSkipped", expectDefer: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &mocov1beta2.MySQLCluster{}
+			cluster.Namespace = "ns"
+			cluster.Name = "test"
+			cluster.Spec.Replicas = 3
+			cluster.Spec.AutoReCloneOnGap = tc.enabled
+			if tc.lastReClone > 0 {
+				last := metav1.NewTime(time.Now().Add(-tc.lastReClone).Truncate(time.Second))
+				cluster.Status.LastReCloneTime = &last
+			}
+
+			objs := []client.Object{cluster}
+			if tc.capacity != "" {
+				pvc := &corev1.PersistentVolumeClaim{}
+				pvc.Namespace = "ns"
+				pvc.Name = constants.MySQLDataVolumeName + "-" + cluster.PodName(1)
+				pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(tc.capacity)}
+				objs = append(objs, pvc)
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objs...).
+				WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+				Build()
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(cluster), cluster); err != nil {
+				t.Fatal(err)
+			}
+
+			pods := []*corev1.Pod{{}, {}, {}}
+			pods[0].Status.PodIP = "10.0.0.1"
+			primary := &dbop.MySQLInstanceStatus{}
+			primary.GlobalVariables.ExecutedGTID = "p0:1-10"
+			primary.GlobalVariables.PurgedGTID = "p0:1-5"
+			replica := &dbop.MySQLInstanceStatus{
+				ReplicaStatus: &dbop.ReplicaStatus{
+					MasterHost:     cluster.PodHostname(0),
+					SlaveIORunning: tc.ioRunning,
+					LastIoErrno:    1236,
+					AutoPosition:   "1",
+				},
+			}
+			replica.GlobalVariables.ReadOnly = true
+			replica.GlobalVariables.SuperReadOnly = true
+			other := &dbop.MySQLInstanceStatus{}
+			if tc.otherCloning {
+				other.CloneStatus = &dbop.CloneStatus{State: sql.NullString{Valid: true, String: "In Progress"}}
+			}
+
+			op := &gapOperator{contained: tc.contained, dataSize: 100 << 20}
+			af := &cloneAgentFactory{}
+			recorder := record.NewFakeRecorder(10)
+			p := &managerProcess{
+				client:           c,
+				reader:           c,
+				agentf:           af,
+				recorder:         recorder,
+				name:             types.NamespacedName{Namespace: "ns", Name: "test"},
+				ioThreadRevivals: make(map[int]int),
+				ioErrors:         make(map[int]int),
+			}
+			ss := &StatusSet{
+				Cluster:      cluster,
+				Password:     passwd,
+				Primary:      0,
+				Pods:         pods,
+				ExecutedGTID: "p0:1-10",
+				MySQLStatus:  []*dbop.MySQLInstanceStatus{primary, replica, other},
+				DBOps:        []dbop.Operator{op, op, op},
+			}
+
+			redo, err := p.configureReplica(context.Background(), ss, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.expectDefer {
+				if !slices.Equal(ss.Deferred, []string{"ReClone/1"}) {
+					t.Errorf("the re-clone should be reported as deferred: %v", ss.Deferred)
+				}
+			} else if len(ss.Deferred) != 0 {
+				t.Errorf("nothing should be deferred: %v", ss.Deferred)
+			}
+
+			updated := &mocov1beta2.MySQLCluster{}
+			if err := c.Get(context.Background(), p.name, updated); err != nil {
+				t.Fatal(err)
+			}
+			if tc.expectClone {
+				if !redo {
+					t.Error("redo should be true after re-cloning")
+				}
+				if len(af.clones) != 1 || af.clones[0] != 1 {
+					t.Errorf("instance 1 should be re-cloned: %v", af.clones)
+				}
+				if updated.Status.LastReCloneTime == nil || time.Since(updated.Status.LastReCloneTime.Time) > time.Minute {
+					t.Errorf("the re-clone time is not recorded: %v", updated.Status.LastReCloneTime)
+				}
+			} else {
+				if len(af.clones) != 0 {
+					t.Errorf("no instance should be re-cloned: %v", af.clones)
+				}
+				if !equalTimePtr(updated.Status.LastReCloneTime, cluster.Status.LastReCloneTime) {
+					t.Errorf("the re-clone time should not be changed: %v", updated.Status.LastReCloneTime)
+				}
+			}
+
+			var reasons []string
+		L:
+			for {
+				select {
+				case ev := <-recorder.Events:
+					reasons = append(reasons, strings.Fields(ev)[1])
+				default:
+					break L
+				}
+			}
+			if tc.expectEvent != "" && !slices.Contains(reasons, tc.expectEvent) {
+				t.Errorf("event %s is not recorded: %v", tc.expectEvent, reasons)
+			}
+			if tc.expectEvent == "" && len(reasons) != 0 {
+				t.Errorf("unexpected events: %v", reasons)
+			}
+		})
+	}
+}
+
+func equalTimePtr(a, b *metav1.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b)
+}
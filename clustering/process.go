@@ -2,25 +2,55 @@ package clustering
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/constants"
 	"github.com/cybozu-go/moco/pkg/dbop"
 	"github.com/cybozu-go/moco/pkg/event"
 	"github.com/cybozu-go/moco/pkg/metrics"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	minCheckInterval = 5 * time.Second
+	maxCheckInterval = 1 * time.Hour
+
+	// maxWaitInterval is the upper limit of the check interval backed off
+	// while the cluster is waiting for a clone or a restore to finish.
+	maxWaitInterval = 1 * time.Minute
+
+	// outOfSyncWarningThreshold is the number of consecutive checks
+	// after which an out-of-sync replica is reported.
+	outOfSyncWarningThreshold = 10
+
+	// commandWarningThreshold is the number of SQL commands in a single check
+	// above which a warning is logged.  Exceeding it usually means that MOCO
+	// repeats the same operation, e.g. re-configuring replication every time.
+	commandWarningThreshold = 200
+
+	// lastReconcileResolution is the resolution of `status.lastReconcileTime`.
+	// The field is not updated on every check to save writes to kube-apiserver.
+	lastReconcileResolution = 5 * time.Minute
+)
+
 type metricsSet struct {
 	checkCount      prometheus.Counter
 	errorCount      prometheus.Counter
@@ -30,8 +60,23 @@ type metricsSet struct {
 	failoverCount   prometheus.Counter
 	replicas        prometheus.Gauge
 	readyReplicas   prometheus.Gauge
+	syncedReplicas  prometheus.Gauge
+	desiredReplicas prometheus.Gauge
 	errantReplicas  prometheus.Gauge
+	outOfSync       prometheus.Gauge
+	maxDelay        prometheus.Gauge
+	primaryIndex    prometheus.Gauge
+	queryCommands   prometheus.Counter
+	execCommands    prometheus.Counter
 	processingTime  prometheus.Observer
+	gatherTime      prometheus.Observer
+	decisionTime    prometheus.Observer
+	lastSuccess     prometheus.Gauge
+
+	cloneErrors      prometheus.Counter
+	switchoverErrors prometheus.Counter
+	failoverErrors   prometheus.Counter
+	configureErrors  prometheus.Counter
 
 	backupTimestamp    prometheus.Gauge
 	backupElapsed      prometheus.Gauge
@@ -50,12 +95,54 @@ type managerProcess struct {
 	name     types.NamespacedName
 	cancel   func()
 
+	// webhookHosts is the list of hosts allowed for `spec.failoverWebhookURL`.
+	webhookHosts []string
+
 	ch            chan string
 	metrics       metricsSet
 	deleteMetrics func()
+
+	// primaryIndex and primarySince record the primary instance observed last
+	// and the time when it was first observed as the primary by this process.
+	// These are only for recording the transitions; see `status.primarySince`
+	// for the durable record.
+	primaryIndex int
+	primarySince time.Time
+
+	// wakeUpTimer is the timer to trigger a check scheduled by wakeUp.
+	wakeUpTimer *time.Timer
+
+	// checkIntervalSeconds is `spec.checkIntervalSeconds` observed last.
+	checkIntervalSeconds int32
+
+	// outOfSyncCounts records the number of consecutive checks for which
+	// each replica has been out of sync.
+	outOfSyncCounts map[int]int
+
+	// ioThreadRevivals records the number of times the IO thread of each
+	// replica has been restarted after exhausting its connection retries.
+	ioThreadRevivals map[int]int
+
+	// ioErrors records the error number of the IO thread of each replica
+	// observed in the last check.
+	ioErrors map[int]int
+
+	// replicationUserKey identifies the primary Pod and the digest of the password
+	// for which `spec.replicationUser` was ensured last.
+	replicationUserKey string
+
+	// lastState is the cluster state decided last.
+	lastState ClusterState
+
+	// waitingSince is the time when the cluster started waiting for a clone
+	// or a restore to finish.  It is zero if the cluster is not waiting.
+	waitingSince time.Time
+
+	// lastSuccess is the time when a check finished without errors last.
+	lastSuccess time.Time
 }
 
-func newManagerProcess(c client.Client, r client.Reader, recorder record.EventRecorder, dbf dbop.OperatorFactory, agentf AgentFactory, name types.NamespacedName, cancel func()) *managerProcess {
+func newManagerProcess(c client.Client, r client.Reader, recorder record.EventRecorder, dbf dbop.OperatorFactory, agentf AgentFactory, webhookHosts []string, name types.NamespacedName, cancel func()) *managerProcess {
 	return &managerProcess{
 		client:   c,
 		reader:   r,
@@ -65,6 +152,12 @@ func newManagerProcess(c client.Client, r client.Reader, recorder record.EventRe
 		name:     name,
 		cancel:   cancel,
 		ch:       make(chan string, 1),
+
+		webhookHosts: webhookHosts,
+
+		outOfSyncCounts:  make(map[int]int),
+		ioThreadRevivals: make(map[int]int),
+		ioErrors:         make(map[int]int),
 		metrics: metricsSet{
 			checkCount:         metrics.CheckCountVec.WithLabelValues(name.Name, name.Namespace),
 			errorCount:         metrics.ErrorCountVec.WithLabelValues(name.Name, name.Namespace),
@@ -74,8 +167,22 @@ func newManagerProcess(c client.Client, r client.Reader, recorder record.EventRe
 			failoverCount:      metrics.FailoverCountVec.WithLabelValues(name.Name, name.Namespace),
 			replicas:           metrics.TotalReplicasVec.WithLabelValues(name.Name, name.Namespace),
 			readyReplicas:      metrics.ReadyReplicasVec.WithLabelValues(name.Name, name.Namespace),
+			syncedReplicas:     metrics.SyncedReplicasVec.WithLabelValues(name.Name, name.Namespace),
+			desiredReplicas:    metrics.DesiredReplicasVec.WithLabelValues(name.Name, name.Namespace),
 			errantReplicas:     metrics.ErrantReplicasVec.WithLabelValues(name.Name, name.Namespace),
+			outOfSync:          metrics.OutOfSyncReplicasVec.WithLabelValues(name.Name, name.Namespace),
+			maxDelay:           metrics.ReplicationDelayVec.WithLabelValues(name.Name, name.Namespace),
+			primaryIndex:       metrics.PrimaryIndexVec.WithLabelValues(name.Name, name.Namespace),
+			queryCommands:      metrics.MySQLCommandsVec.WithLabelValues(name.Name, name.Namespace, "query"),
+			execCommands:       metrics.MySQLCommandsVec.WithLabelValues(name.Name, name.Namespace, "exec"),
+			cloneErrors:        metrics.OperationErrorsVec.WithLabelValues(name.Name, name.Namespace, "clone"),
+			switchoverErrors:   metrics.OperationErrorsVec.WithLabelValues(name.Name, name.Namespace, "switchover"),
+			failoverErrors:     metrics.OperationErrorsVec.WithLabelValues(name.Name, name.Namespace, "failover"),
+			configureErrors:    metrics.OperationErrorsVec.WithLabelValues(name.Name, name.Namespace, "configure"),
 			processingTime:     metrics.ProcessingTimeVec.WithLabelValues(name.Name, name.Namespace),
+			gatherTime:         metrics.GatherTimeVec.WithLabelValues(name.Name, name.Namespace),
+			decisionTime:       metrics.DecisionTimeVec.WithLabelValues(name.Name, name.Namespace),
+			lastSuccess:        metrics.LastSuccessVec.WithLabelValues(name.Name, name.Namespace),
 			backupTimestamp:    metrics.BackupTimestamp.WithLabelValues(name.Name, name.Namespace),
 			backupElapsed:      metrics.BackupElapsed.WithLabelValues(name.Name, name.Namespace),
 			backupDumpSize:     metrics.BackupDumpSize.WithLabelValues(name.Name, name.Namespace),
@@ -92,8 +199,22 @@ func newManagerProcess(c client.Client, r client.Reader, recorder record.EventRe
 			metrics.FailoverCountVec.DeleteLabelValues(name.Name, name.Namespace)
 			metrics.TotalReplicasVec.DeleteLabelValues(name.Name, name.Namespace)
 			metrics.ReadyReplicasVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.SyncedReplicasVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.DesiredReplicasVec.DeleteLabelValues(name.Name, name.Namespace)
 			metrics.ErrantReplicasVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.OutOfSyncReplicasVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.ReplicationDelayVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.PrimaryIndexVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.MySQLCommandsVec.DeleteLabelValues(name.Name, name.Namespace, "query")
+			metrics.MySQLCommandsVec.DeleteLabelValues(name.Name, name.Namespace, "exec")
+			metrics.OperationErrorsVec.DeleteLabelValues(name.Name, name.Namespace, "clone")
+			metrics.OperationErrorsVec.DeleteLabelValues(name.Name, name.Namespace, "switchover")
+			metrics.OperationErrorsVec.DeleteLabelValues(name.Name, name.Namespace, "failover")
+			metrics.OperationErrorsVec.DeleteLabelValues(name.Name, name.Namespace, "configure")
 			metrics.ProcessingTimeVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.GatherTimeVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.DecisionTimeVec.DeleteLabelValues(name.Name, name.Namespace)
+			metrics.LastSuccessVec.DeleteLabelValues(name.Name, name.Namespace)
 			metrics.BackupTimestamp.DeleteLabelValues(name.Name, name.Namespace)
 			metrics.BackupElapsed.DeleteLabelValues(name.Name, name.Namespace)
 			metrics.BackupDumpSize.DeleteLabelValues(name.Name, name.Namespace)
@@ -115,10 +236,27 @@ func (p *managerProcess) Cancel() {
 	p.cancel()
 }
 
+// wakeUp schedules a check after `d` in addition to the regular interval.
+// Only one check is scheduled at a time; a new schedule replaces the pending one.
+func (p *managerProcess) wakeUp(d time.Duration, origin string) {
+	p.stopWakeUp()
+	p.wakeUpTimer = time.AfterFunc(d, func() { p.Update(origin) })
+}
+
+// stopWakeUp cancels the check scheduled by wakeUp, if any.
+func (p *managerProcess) stopWakeUp() {
+	if p.wakeUpTimer != nil {
+		p.wakeUpTimer.Stop()
+		p.wakeUpTimer = nil
+	}
+}
+
 func (p *managerProcess) Start(ctx context.Context, rootLog logr.Logger, interval time.Duration) {
-	tick := time.NewTicker(interval)
+	current := interval
+	tick := time.NewTicker(current)
 	defer func() {
 		tick.Stop()
+		p.stopWakeUp()
 		p.deleteMetrics()
 	}()
 
@@ -141,10 +279,23 @@ func (p *managerProcess) Start(ctx context.Context, rootLog logr.Logger, interva
 		p.metrics.processingTime.Observe(duration.Seconds())
 		if err != nil {
 			p.metrics.errorCount.Inc()
+			var cerr *clusterError
+			if errors.As(err, &cerr) {
+				log = log.WithValues("reason", cerr.reason, "instances", cerr.instances)
+			}
 			log.Error(err, "error", "duration", duration)
 			continue
 		}
 		log.Info("finish", "duration", duration)
+		p.lastSuccess = time.Now()
+		p.metrics.lastSuccess.Set(float64(p.lastSuccess.Unix()))
+
+		next := waitInterval(checkInterval(interval, p.checkIntervalSeconds), p.waitingSince, time.Now())
+		if next != current {
+			log.Info("change the check interval", "interval", next)
+			current = next
+			tick.Reset(current)
+		}
 
 		if redo {
 			// to update status quickly
@@ -154,25 +305,85 @@ func (p *managerProcess) Start(ctx context.Context, rootLog logr.Logger, interva
 }
 
 func (p *managerProcess) do(ctx context.Context) (bool, error) {
+	gatherStart := time.Now()
 	ss, err := p.GatherStatus(ctx)
+	if errors.Is(err, errMissingCredentials) {
+		if err := p.setFailureCondition(ctx, mocov1beta2.ConditionMissingCredentials, err); err != nil {
+			logFromContext(ctx).Error(err, "failed to set MissingCredentials condition")
+		}
+		return false, err
+	}
+	if apierrors.IsNotFound(err) {
+		// the cluster has been deleted, and this process will be stopped soon.
+		logFromContext(ctx).Info("MySQLCluster is not found")
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
 	defer ss.Close()
+	defer p.recordCommands(ctx, ss)
+	p.metrics.gatherTime.Observe(time.Since(gatherStart).Seconds())
+
+	decideStart := time.Now()
+	ss.DecideState()
+	p.metrics.decisionTime.Observe(time.Since(decideStart).Seconds())
+	if _, err := primaryExclusions(ss.Cluster); err != nil {
+		logFromContext(ctx).Error(err, "ignored the annotation", "annotation", constants.AnnExcludeFromPrimary)
+	}
+	if ss.State == StateFailed {
+		ss.PrimaryRestartWait = primaryRestartWait(ss, time.Now())
+	}
+
+	p.checkIntervalSeconds = ss.Cluster.Spec.CheckIntervalSeconds
 
 	if err := p.updateStatus(ctx, ss); err != nil {
+		if apierrors.IsNotFound(err) {
+			logFromContext(ctx).Info("MySQLCluster is not found")
+			return false, nil
+		}
 		return false, fmt.Errorf("failed to update status fields in MySQLCluster: %w", err)
 	}
+	defer func() {
+		if err := p.updateDeferredOperations(ctx, ss); err != nil {
+			logFromContext(ctx).Error(err, "failed to update the deferred operations")
+		}
+	}()
+
+	for _, index := range updateOutOfSyncCounts(p.outOfSyncCounts, ss) {
+		event.ReplicaOutOfSync.Emit(ss.Cluster, p.recorder, index, outOfSyncWarningThreshold)
+		p.publish(StreamEventReplicaOutOfSync, "instance %d has been out of sync for %d checks", index, outOfSyncWarningThreshold)
+	}
+	var longOutOfSync int
+	for _, count := range p.outOfSyncCounts {
+		if count >= outOfSyncWarningThreshold {
+			longOutOfSync++
+		}
+	}
+	p.metrics.outOfSync.Set(float64(longOutOfSync))
+
+	p.recordTransitions(ss)
 
 	logFromContext(ctx).Info("cluster state is " + ss.State.String())
+	cloning := ss.State == StateCloning && p.isCloning(ctx, ss)
+	p.setWaiting(cloning || ss.State == StateRestoring)
+
+	if forceFailoverRequested(ss.Cluster) && ss.State != StateFailed && ss.State != StateLost {
+		logFromContext(ctx).Info("reject the forced failover request as the primary is not failed", "state", ss.State.String())
+		if err := p.removeAnnotation(ctx, ss, constants.AnnForceFailover); err != nil {
+			return false, err
+		}
+	}
+
 	switch ss.State {
 	case StateCloning:
-		if p.isCloning(ctx, ss) {
+		if cloning {
 			return false, nil
 		}
 
 		redo, err := p.clone(ctx, ss)
 		if err != nil {
+			p.metrics.cloneErrors.Inc()
 			event.InitCloneFailed.Emit(ss.Cluster, p.recorder, err)
 			return false, fmt.Errorf("failed to clone data: %w", err)
 		}
@@ -183,40 +394,117 @@ func (p *managerProcess) do(ctx context.Context) (bool, error) {
 		return false, nil
 
 	case StateHealthy, StateDegraded:
-		if ss.NeedSwitch {
+		if target, ok := ss.Cluster.Annotations[constants.AnnSwitchover]; ok && !ss.PlannedSwitchover {
+			logFromContext(ctx).Info("reject the switchover request as the target is not a healthy replica", "target", target)
+			event.SwitchOverFailed.Emit(ss.Cluster, p.recorder, fmt.Errorf("instance %s is not a healthy replica", target))
+			if err := p.removeAnnotation(ctx, ss, constants.AnnSwitchover); err != nil {
+				return false, err
+			}
+		}
+		if ss.NeedSwitch && deferSwitchover(ss, time.Now()) {
+			logFromContext(ctx).Info("switchover is deferred during a blackout window")
+			ss.deferOperation(operationSwitchover)
+		} else if ss.NeedSwitch {
 			if err := p.switchover(ctx, ss); err != nil {
+				p.metrics.switchoverErrors.Inc()
 				event.SwitchOverFailed.Emit(ss.Cluster, p.recorder, err)
 				return false, fmt.Errorf("failed to switchover: %w", err)
 			}
 			event.SwitchOverSucceeded.Emit(ss.Cluster, p.recorder, ss.Candidate)
+			p.notifyPrimaryChange(ctx, ss, PrimaryChangeReasonSwitchOver)
 			// do not configure the cluster after a switchover.
 			return true, nil
 		}
 		if ss.State == StateDegraded {
-			return p.configure(ctx, ss)
+			redo, err := p.configure(ctx, ss)
+			return redo, countError(p.metrics.configureErrors, err)
+		}
+		if primaryNeedsConfiguration(ss) {
+			// only the semi-sync settings of the primary have drifted, e.g. by a restart
+			// or a manual `SET GLOBAL`, so correct them without touching the other instances.
+			pst := ss.MySQLStatus[ss.Primary]
+			logFromContext(ctx).Info("semi-sync settings of the primary have drifted", "instance", ss.Primary,
+				"enabled", pst.GlobalVariables.SemiSyncMasterEnabled, "waitForCount", pst.GlobalVariables.WaitForSlaveCount,
+				"timeout", pst.GlobalVariables.SemiSyncMasterTimeout)
+			redo, err := p.configurePrimary(ctx, ss)
+			return redo, countError(p.metrics.configureErrors, err)
+		}
+		if replicas := lagChangedReplicas(ss); len(replicas) > 0 {
+			// replicas delayed over `spec.maxReplicationLagSeconds` are excluded from
+			// the semi-synchronous replicas, and included again when they catch up.
+			logFromContext(ctx).Info("replication lag of replicas crossed the threshold", "instances", replicas)
+			redo := false
+			for _, index := range replicas {
+				r, err := p.configureReplica(ctx, ss, index)
+				if err != nil {
+					return false, countError(p.metrics.configureErrors, fmt.Errorf("failed to configure replica instance %d: %w", index, err))
+				}
+				redo = redo || r
+			}
+			return redo, nil
 		}
 		return false, nil
 
 	case StateFailed:
-		// in this case, only applicable operation is a failover.
-		if err := p.failover(ctx, ss); err != nil {
-			event.FailOverFailed.Emit(ss.Cluster, p.recorder, err)
-			return false, fmt.Errorf("failed to failover: %w", err)
+		forced := forceFailoverRequested(ss.Cluster)
+		if wait := ss.PrimaryRestartWait; wait > 0 && !forced {
+			logFromContext(ctx).Info("defer failover while the primary Pod is terminating", "wait", wait)
+			p.wakeUp(wait, "primary-restart")
+			ss.deferOperation(operationFailover)
+			return false, nil
 		}
-		event.FailOverSucceeded.Emit(ss.Cluster, p.recorder, ss.Candidate)
-		return true, nil
+		// in this case, only applicable operation is a failover.
+		return p.runFailover(ctx, ss, forced)
 
 	case StateLost:
+		if forceFailoverRequested(ss.Cluster) {
+			return p.runFailover(ctx, ss, true)
+		}
 		// nothing can be done
 		return false, nil
 
 	case StateIncomplete:
-		return p.configure(ctx, ss)
+		redo, err := p.configure(ctx, ss)
+		return redo, countError(p.metrics.configureErrors, err)
 	}
 
 	return false, nil
 }
 
+// runFailover promotes a replica to the new primary and reports the result.
+// If `forced` is true, the failover is requested by `moco.cybozu.com/force-failover` annotation,
+// which is removed after the failover succeeds.
+func (p *managerProcess) runFailover(ctx context.Context, ss *StatusSet, forced bool) (bool, error) {
+	log := logFromContext(ctx)
+	if forced {
+		log.Info("WARNING: forcing a failover as requested; transactions not replicated to reachable replicas will be lost",
+			"annotation", constants.AnnForceFailover, "state", ss.State.String(), "primary", ss.Primary)
+		event.FailOverForced.Emit(ss.Cluster, p.recorder, ss.Primary, ss.State.String())
+	}
+	if err := p.failover(ctx, ss); err != nil {
+		p.metrics.failoverErrors.Inc()
+		event.FailOverFailed.Emit(ss.Cluster, p.recorder, err)
+		return false, fmt.Errorf("failed to failover: %w", err)
+	}
+	event.FailOverSucceeded.Emit(ss.Cluster, p.recorder, ss.Candidate)
+	p.notifyPrimaryChange(ctx, ss, PrimaryChangeReasonFailOver)
+	if forced {
+		if err := p.removeAnnotation(ctx, ss, constants.AnnForceFailover); err != nil {
+			// the annotation will be removed in the next reconciliation as the primary is no longer failed.
+			log.Error(err, "failed to remove the annotation", "annotation", constants.AnnForceFailover)
+		}
+	}
+	return true, nil
+}
+
+// countError increments `c` if `err` is not nil, and returns `err` as is.
+func countError(c prometheus.Counter, err error) error {
+	if err != nil {
+		c.Inc()
+	}
+	return err
+}
+
 func (p *managerProcess) updateStatus(ctx context.Context, ss *StatusSet) error {
 	bs := &ss.Cluster.Status.Backup
 	if !bs.Time.IsZero() {
@@ -228,18 +516,9 @@ func (p *managerProcess) updateStatus(ctx context.Context, ss *StatusSet) error
 		p.metrics.backupWarnings.Set(float64(len(bs.Warnings)))
 	}
 
-	ststr := ss.State.String()
-	updateCond := func(typ string, val metav1.ConditionStatus) metav1.Condition {
-		updated := metav1.Condition{
-			Type:    typ,
-			Status:  val,
-			Reason:  ststr,
-			Message: "the current state is " + ststr,
-		}
-		return updated
-	}
-
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	var changed []metav1.Condition
+	var maxWaitSeconds int32
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		cluster := &mocov1beta2.MySQLCluster{}
 		if err := p.reader.Get(ctx, p.name, cluster); err != nil {
 			return err
@@ -262,9 +541,45 @@ func (p *managerProcess) updateStatus(ctx context.Context, ss *StatusSet) error
 		case StateIncomplete:
 		}
 
-		meta.SetStatusCondition(&cluster.Status.Conditions, updateCond(mocov1beta2.ConditionInitialized, initialized))
-		meta.SetStatusCondition(&cluster.Status.Conditions, updateCond(mocov1beta2.ConditionAvailable, available))
-		meta.SetStatusCondition(&cluster.Status.Conditions, updateCond(mocov1beta2.ConditionHealthy, healthy))
+		initializing := initializingCondition(cluster.Status.Conditions, available)
+		newStateCondition := stateCondition
+		if initializing.Status == metav1.ConditionTrue {
+			newStateCondition = startupStateCondition
+		}
+
+		meta.SetStatusCondition(&cluster.Status.Conditions, initializing)
+		meta.SetStatusCondition(&cluster.Status.Conditions, stateCondition(mocov1beta2.ConditionInitialized, initialized, ss.State))
+		meta.SetStatusCondition(&cluster.Status.Conditions, newStateCondition(mocov1beta2.ConditionAvailable, available, ss.State))
+		meta.SetStatusCondition(&cluster.Status.Conditions, newStateCondition(mocov1beta2.ConditionHealthy, healthy, ss.State))
+		meta.SetStatusCondition(&cluster.Status.Conditions, newStateCondition(mocov1beta2.ConditionReady, readyStatus(cluster.Spec.ReadinessPolicy, available, healthy), ss.State))
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type:    mocov1beta2.ConditionMissingCredentials,
+			Status:  metav1.ConditionFalse,
+			Reason:  "CredentialsFound",
+			Message: "the password secret is valid",
+		})
+		meta.SetStatusCondition(&cluster.Status.Conditions, binlogCondition(ss))
+		if meta.FindStatusCondition(cluster.Status.Conditions, mocov1beta2.ConditionConstraintsViolated) != nil && len(writableReplicas(ss)) == 0 {
+			meta.SetStatusCondition(&cluster.Status.Conditions, constraintsSatisfiedCondition())
+		}
+		meta.SetStatusCondition(&cluster.Status.Conditions, multiSourceCondition(ss))
+		meta.SetStatusCondition(&cluster.Status.Conditions, charsetCondition(ss))
+		meta.SetStatusCondition(&cluster.Status.Conditions, autoPositionCondition(ss))
+		meta.SetStatusCondition(&cluster.Status.Conditions, cloneFailedCondition(ss))
+		meta.SetStatusCondition(&cluster.Status.Conditions, allReplicasDownCondition(ss))
+		meta.SetStatusCondition(&cluster.Status.Conditions, errantCondition(ss))
+		meta.SetStatusCondition(&cluster.Status.Conditions, outOfSyncCondition(ss))
+
+		now := time.Now()
+		cluster.Status.RequeueReason = requeueReason(ss)
+		cluster.Status.WaitingSince = waitingSince(cluster.Status.WaitingSince, cluster.Status.RequeueReason, now)
+		meta.SetStatusCondition(&cluster.Status.Conditions, waitTimeoutCondition(cluster, now))
+		maxWaitSeconds = cluster.Spec.MaxWaitSeconds
+		if cluster.Status.PrimarySince == nil {
+			// failovers and switchovers update this; the initial primary is recorded here.
+			cluster.Status.PrimarySince = &metav1.Time{Time: now}
+		}
+		cluster.Status.LastReconcileTime = lastReconcileTime(cluster.Status.LastReconcileTime, p.lastSuccess)
 
 		if available == metav1.ConditionTrue {
 			p.metrics.available.Set(1)
@@ -277,18 +592,24 @@ func (p *managerProcess) updateStatus(ctx context.Context, ss *StatusSet) error
 			p.metrics.healthy.Set(0)
 		}
 
-		var syncedReplicas int
+		var readyReplicas int
 		for _, pod := range ss.Pods {
 			if isPodReady(pod) {
-				syncedReplicas++
+				readyReplicas++
 			}
 		}
-		cluster.Status.SyncedReplicas = syncedReplicas
+		cluster.Status.SyncedReplicas = countSyncedReplicas(ss)
+		cluster.Status.OutOfSyncReplicas = outOfSyncReplicas(ss)
 		cluster.Status.ErrantReplicas = len(ss.Errants)
 		cluster.Status.ErrantReplicaList = ss.Errants
+		cluster.Status.Instances = instanceStatuses(ss, cluster.Status.Instances)
 		p.metrics.replicas.Set(float64(len(ss.Pods)))
-		p.metrics.readyReplicas.Set(float64(syncedReplicas))
+		p.metrics.readyReplicas.Set(float64(readyReplicas))
+		p.metrics.syncedReplicas.Set(float64(cluster.Status.SyncedReplicas))
+		p.metrics.desiredReplicas.Set(float64(cluster.Spec.Replicas))
 		p.metrics.errantReplicas.Set(float64(len(ss.Errants)))
+		p.metrics.maxDelay.Set(float64(maxReplicationDelay(ss)))
+		p.metrics.primaryIndex.Set(float64(ss.Primary))
 
 		// the completion of initial cloning is recorded in the status
 		// to make it possible to determine the cloning status even while
@@ -297,6 +618,14 @@ func (p *managerProcess) updateStatus(ctx context.Context, ss *StatusSet) error
 			cluster.Status.Cloned = true
 		}
 
+		cluster.Status.SemiSyncClients, cluster.Status.SemiSyncWaitForCount = semiSyncStatus(ss)
+		cluster.Status.SemiSyncAcknowledgers = semiSyncAcknowledgers(ss)
+		cluster.Status.PrimaryGTIDPurged = primaryGTIDPurged(ss, cluster.Status.PrimaryGTIDPurged)
+		cluster.Status.Clones = mergeCloneStatuses(cluster.Status.Clones, ss)
+		cluster.Status.ReplicaRecoveries = pruneReplicaRecoveries(cluster.Status.ReplicaRecoveries, ss)
+
+		changed = changedConditions(orig.Status.Conditions, cluster.Status.Conditions)
+
 		// if nothing has changed, skip updating.
 		if equality.Semantic.DeepEqual(orig, cluster) {
 			return nil
@@ -305,4 +634,846 @@ func (p *managerProcess) updateStatus(ctx context.Context, ss *StatusSet) error
 		logFromContext(ctx).Info("update the status information")
 		return p.client.Status().Update(ctx, cluster)
 	})
+	if err != nil {
+		return err
+	}
+
+	for _, cond := range changed {
+		p.publish(StreamEventConditionChanged, "%s=%s (%s)", cond.Type, cond.Status, cond.Reason)
+		if cond.Type == mocov1beta2.ConditionWaitTimeout && cond.Status == metav1.ConditionTrue {
+			event.WaitTimeout.Emit(ss.Cluster, p.recorder, maxWaitSeconds, requeueReason(ss))
+		}
+	}
+	return nil
+}
+
+// updateDeferredOperations records the operations deferred in this check in
+// `status.deferredOperations`.  As the operations run after updateStatus,
+// this updates the status only when the list has changed.
+func (p *managerProcess) updateDeferredOperations(ctx context.Context, ss *StatusSet) error {
+	if slices.Equal(ss.Cluster.Status.DeferredOperations, ss.Deferred) {
+		return nil
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := p.reader.Get(ctx, p.name, cluster); err != nil {
+			return err
+		}
+		cluster.Status.DeferredOperations = ss.Deferred
+		return p.client.Status().Update(ctx, cluster)
+	})
+}
+
+// changedConditions returns the conditions in `current` whose status or reason differs from `orig`.
+func changedConditions(orig, current []metav1.Condition) []metav1.Condition {
+	var changed []metav1.Condition
+	for _, cond := range current {
+		old := meta.FindStatusCondition(orig, cond.Type)
+		if old == nil || old.Status != cond.Status || old.Reason != cond.Reason {
+			changed = append(changed, cond)
+		}
+	}
+	return changed
+}
+
+// recordTransitions records the changes of the cluster state and the primary
+// instance since the last reconciliation as events.
+func (p *managerProcess) recordTransitions(ss *StatusSet) {
+	if ss.State != p.lastState {
+		p.publish(StreamEventStateChanged, "the state changed from %s to %s", p.lastState.String(), ss.State.String())
+		if ss.State == StateLost {
+			event.ClusterLost.Emit(ss.Cluster, p.recorder)
+		}
+		// the scheduled check was for the previous state.
+		p.stopWakeUp()
+		p.lastState = ss.State
+	}
+	if p.primarySince.IsZero() || p.primaryIndex != ss.Primary {
+		if !p.primarySince.IsZero() {
+			event.PrimaryChanged.Emit(ss.Cluster, p.recorder, p.primaryIndex, ss.Primary)
+			p.publish(StreamEventPrimaryChanged, "the primary changed from instance %d to %d", p.primaryIndex, ss.Primary)
+		}
+		p.primaryIndex = ss.Primary
+		p.primarySince = time.Now()
+	}
+}
+
+// publish sends an event of the cluster to the subscribers of the event stream.
+func (p *managerProcess) publish(typ, format string, args ...interface{}) {
+	defaultStream.Publish(StreamEvent{
+		Namespace: p.name.Namespace,
+		Name:      p.name.Name,
+		Type:      typ,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// requeueReason returns the reason why the manager has to wait for the cluster
+// to converge.  An empty string is returned if the manager is not waiting for anything,
+// i.e. the cluster is healthy or the manager will take an action immediately.
+func requeueReason(ss *StatusSet) string {
+	switch ss.State {
+	case StateCloning:
+		return "CloneInProgress"
+	case StateRestoring:
+		return "RestoreInProgress"
+	case StateDegraded:
+		if ss.NeedSwitch {
+			return ""
+		}
+		return "WaitingForReplication"
+	case StateIncomplete:
+		return "WaitingForReplication"
+	case StateFailed:
+		if ss.PrimaryRestartWait > 0 {
+			return "WaitingForPrimaryRestart"
+		}
+	case StateLost:
+		return "WaitingForRecovery"
+	}
+	return ""
+}
+
+// waitingSince returns the time when MOCO started waiting for the cluster to converge.
+// `last` is kept while MOCO keeps waiting even if the reason changes.
+func waitingSince(last *metav1.Time, reason string, now time.Time) *metav1.Time {
+	if reason == "" {
+		return nil
+	}
+	if last != nil {
+		return last
+	}
+	t := metav1.NewTime(now)
+	return &t
+}
+
+func waitTimeoutCondition(cluster *mocov1beta2.MySQLCluster, now time.Time) metav1.Condition {
+	since := cluster.Status.WaitingSince
+	if since == nil {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionWaitTimeout,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NotWaiting",
+			Message: "MOCO is not waiting for the cluster",
+		}
+	}
+	maxWait := time.Duration(cluster.Spec.MaxWaitSeconds) * time.Second
+	if maxWait == 0 || now.Sub(since.Time) < maxWait {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionWaitTimeout,
+			Status:  metav1.ConditionFalse,
+			Reason:  "Waiting",
+			Message: "MOCO is waiting for the cluster to converge: " + cluster.Status.RequeueReason,
+		}
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionWaitTimeout,
+		Status:  metav1.ConditionTrue,
+		Reason:  "MaxWaitExceeded",
+		Message: fmt.Sprintf("the cluster has not converged for more than %d seconds: %s", cluster.Spec.MaxWaitSeconds, cluster.Status.RequeueReason),
+	}
+}
+
+// lastReconcileTime returns `status.lastReconcileTime` for the last successful check at `success`.
+// `last` is kept until it becomes older than lastReconcileResolution so that
+// the status is not updated on every check only for this field.
+func lastReconcileTime(last *metav1.Time, success time.Time) *metav1.Time {
+	if success.IsZero() {
+		return last
+	}
+	if last != nil && success.Sub(last.Time) < lastReconcileResolution {
+		return last
+	}
+	t := metav1.NewTime(success)
+	return &t
+}
+
+// setFailureCondition sets the condition of `condType` to true to report `failure`.
+// The reason of the condition is taken from the clusterError in `failure`.
+func (p *managerProcess) setFailureCondition(ctx context.Context, condType string, failure error) error {
+	return p.setCondition(ctx, metav1.Condition{
+		Type:    condType,
+		Status:  metav1.ConditionTrue,
+		Reason:  errorReason(failure, "Failed"),
+		Message: failure.Error(),
+	})
+}
+
+// setCondition adds or updates `cond` in `status.conditions` of MySQLCluster.
+func (p *managerProcess) setCondition(ctx context.Context, cond metav1.Condition) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := p.reader.Get(ctx, p.name, cluster); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		orig := cluster.DeepCopy()
+
+		meta.SetStatusCondition(&cluster.Status.Conditions, cond)
+		if equality.Semantic.DeepEqual(orig, cluster) {
+			return nil
+		}
+		return p.client.Status().Update(ctx, cluster)
+	})
+}
+
+// deferSwitchover returns true if the switchover requested for the primary should be
+// deferred because `now` is in one of `spec.switchoverBlackoutWindows`.
+// A switchover for a terminating Pod is never deferred as the Pod is going away anyway.
+func deferSwitchover(ss *StatusSet, now time.Time) bool {
+	if ss.Pods[ss.Primary].DeletionTimestamp != nil {
+		return false
+	}
+	return inBlackoutWindow(ss.Cluster.Spec.SwitchoverBlackoutWindows, now)
+}
+
+// inBlackoutWindow returns true if `now` is in any of `windows`.
+// Windows with an invalid schedule are ignored.
+func inBlackoutWindow(windows []mocov1beta2.BlackoutWindow, now time.Time) bool {
+	for _, w := range windows {
+		sched, err := cron.ParseStandard(w.Schedule)
+		if err != nil {
+			continue
+		}
+		duration := time.Duration(w.DurationSeconds) * time.Second
+		// the window is open if it has started within `duration`.
+		if !sched.Next(now.Add(-duration)).After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// semiSyncStatus returns the number of semi-synchronous replicas connected to
+// the primary and the number of acknowledgements the primary waits for.
+// Both are zero if the status of the primary is not available or the primary
+// does not enable semi-synchronous replication.
+func semiSyncStatus(ss *StatusSet) (clients, waitForCount int) {
+	pst := ss.MySQLStatus[ss.Primary]
+	if pst == nil || !pst.GlobalVariables.SemiSyncMasterEnabled {
+		return 0, 0
+	}
+	return pst.SemiSyncMasterClients, pst.GlobalVariables.WaitForSlaveCount
+}
+
+// semiSyncAcknowledgers returns the indices of replicas that acknowledge transactions
+// of the primary.  A replica is an acknowledger if it is listed in `SHOW SLAVE HOSTS`
+// of the primary, replicates from the primary, and its semi-sync replica is active.
+func semiSyncAcknowledgers(ss *StatusSet) []int {
+	pst := ss.MySQLStatus[ss.Primary]
+	if pst == nil || !pst.GlobalVariables.SemiSyncMasterEnabled {
+		return nil
+	}
+
+	connected := make(map[int32]bool)
+	for _, h := range pst.ReplicaHosts {
+		connected[h.ServerID] = true
+	}
+
+	primaryHostname := ss.Cluster.PodHostname(ss.Primary)
+	var acks []int
+	for i, ist := range ss.MySQLStatus {
+		if i == ss.Primary || ist == nil {
+			continue
+		}
+		if !connected[ss.Cluster.Spec.ServerIDBase+int32(i)] {
+			continue
+		}
+		if ist.ReplicaStatus == nil || ist.ReplicaStatus.MasterHost != primaryHostname {
+			continue
+		}
+		if !ist.SemiSyncSlaveActive {
+			continue
+		}
+		acks = append(acks, i)
+	}
+	return acks
+}
+
+// initializingCondition returns Initializing condition from the current conditions of the cluster.
+// A new cluster is initializing until it becomes available, i.e. its primary accepts writes,
+// for the first time.  Once the condition becomes False, it never goes back to True.
+//
+// Clusters that have Available condition but not Initializing condition were brought up
+// by an older MOCO, so they are considered initialized.
+func initializingCondition(conds []metav1.Condition, available metav1.ConditionStatus) metav1.Condition {
+	done := metav1.Condition{
+		Type:    mocov1beta2.ConditionInitializing,
+		Status:  metav1.ConditionFalse,
+		Reason:  "BroughtUp",
+		Message: "the cluster has become available",
+	}
+
+	cond := meta.FindStatusCondition(conds, mocov1beta2.ConditionInitializing)
+	switch {
+	case cond == nil && meta.FindStatusCondition(conds, mocov1beta2.ConditionAvailable) != nil:
+		return done
+	case cond != nil && cond.Status == metav1.ConditionFalse:
+		return done
+	case available == metav1.ConditionTrue:
+		return done
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionInitializing,
+		Status:  metav1.ConditionTrue,
+		Reason:  "BringingUp",
+		Message: "the cluster is being brought up",
+	}
+}
+
+// startupStateCondition is the same as stateCondition except that the Failed and Lost
+// states are reported as "Initializing".  They are expected transients while a new
+// cluster is being brought up and should not alarm the operators.
+func startupStateCondition(typ string, val metav1.ConditionStatus, state ClusterState) metav1.Condition {
+	cond := stateCondition(typ, val, state)
+	if state == StateFailed || state == StateLost {
+		cond.Reason = "Initializing"
+		cond.Message = "the cluster is being brought up; the current state is " + state.String()
+	}
+	return cond
+}
+
+// primaryGTIDPurged returns `@@gtid_purged` of the primary instance.
+// If the primary is unreachable, this returns `last`, the previously observed value.
+func primaryGTIDPurged(ss *StatusSet, last string) string {
+	pst := ss.MySQLStatus[ss.Primary]
+	if pst == nil {
+		return last
+	}
+	return pst.GlobalVariables.PurgedGTID
+}
+
+// stateCondition returns a condition derived from the cluster state.
+// The reason is the name of the state, e.g. "Healthy", "Degraded", or "Lost",
+// so that clients can tell why the condition has the status.
+func stateCondition(typ string, val metav1.ConditionStatus, state ClusterState) metav1.Condition {
+	ststr := state.String()
+	return metav1.Condition{
+		Type:    typ,
+		Status:  val,
+		Reason:  ststr,
+		Message: "the current state is " + ststr,
+	}
+}
+
+// readyStatus returns the status of the Ready condition according to `policy`.
+func readyStatus(policy mocov1beta2.ReadinessPolicy, available, healthy metav1.ConditionStatus) metav1.ConditionStatus {
+	if policy == mocov1beta2.ReadinessPolicyFullyHealthy {
+		return healthy
+	}
+	return available
+}
+
+// binlogCondition returns the condition that reports whether the binary logging
+// is disabled on the primary instance.
+func binlogCondition(ss *StatusSet) metav1.Condition {
+	cond := metav1.Condition{
+		Type:    mocov1beta2.ConditionPrimaryBinlogDisabled,
+		Status:  metav1.ConditionFalse,
+		Reason:  "BinlogEnabled",
+		Message: "the binary logging is enabled on the primary",
+	}
+	pst := ss.MySQLStatus[ss.Primary]
+	switch {
+	case pst == nil:
+		cond.Status = metav1.ConditionUnknown
+		cond.Reason = "PrimaryUnavailable"
+		cond.Message = "the primary status is not available"
+	case !pst.GlobalVariables.LogBin:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "BinlogDisabled"
+		cond.Message = "the binary logging is disabled on the primary"
+	}
+	return cond
+}
+
+// constraintsSatisfiedCondition returns ConstraintsViolated condition to clear the violation
+// reported when making the primary writable.
+func constraintsSatisfiedCondition() metav1.Condition {
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionConstraintsViolated,
+		Status:  metav1.ConditionFalse,
+		Reason:  "ConstraintsSatisfied",
+		Message: "no instance other than the primary is writable",
+	}
+}
+
+// checkInterval returns the interval of the cluster maintenance.
+// If `seconds` is positive, it overrides `defaultInterval` within
+// the range of [minCheckInterval, maxCheckInterval].
+func checkInterval(defaultInterval time.Duration, seconds int32) time.Duration {
+	if seconds <= 0 {
+		return defaultInterval
+	}
+	d := time.Duration(seconds) * time.Second
+	switch {
+	case d < minCheckInterval:
+		return minCheckInterval
+	case d > maxCheckInterval:
+		return maxCheckInterval
+	}
+	return d
+}
+
+// setWaiting records the time when the cluster started waiting for a clone or a restore.
+// The record is cleared when the cluster stops waiting, e.g. becomes healthy.
+func (p *managerProcess) setWaiting(waiting bool) {
+	switch {
+	case !waiting:
+		p.waitingSince = time.Time{}
+	case p.waitingSince.IsZero():
+		p.waitingSince = time.Now()
+	}
+}
+
+// waitInterval returns the check interval backed off for a cluster waiting
+// since `since`.  Starting from `interval`, the interval is doubled each time
+// the waiting time exceeds it, up to maxWaitInterval.  If `since` is zero or
+// `interval` is already longer than maxWaitInterval, `interval` is returned as is.
+func waitInterval(interval time.Duration, since, now time.Time) time.Duration {
+	if since.IsZero() || interval >= maxWaitInterval {
+		return interval
+	}
+	elapsed := now.Sub(since)
+	d := interval
+	for d < maxWaitInterval && elapsed >= d {
+		d *= 2
+	}
+	if d > maxWaitInterval {
+		return maxWaitInterval
+	}
+	return d
+}
+
+// recordCommands adds the number of SQL commands issued during a check to the metrics.
+func (p *managerProcess) recordCommands(ctx context.Context, ss *StatusSet) {
+	counts := countCommands(ss.DBOps)
+	p.metrics.queryCommands.Add(float64(counts.Query))
+	p.metrics.execCommands.Add(float64(counts.Exec))
+	if counts.Total() > commandWarningThreshold {
+		logFromContext(ctx).Info("too many SQL commands in a check", "query", counts.Query, "exec", counts.Exec, "threshold", commandWarningThreshold)
+	}
+}
+
+// countCommands sums the number of SQL commands issued by `ops`.
+func countCommands(ops []dbop.Operator) dbop.CommandCounts {
+	var counts dbop.CommandCounts
+	for _, op := range ops {
+		if op == nil {
+			continue
+		}
+		c := op.CommandCounts()
+		counts.Query += c.Query
+		counts.Exec += c.Exec
+	}
+	return counts
+}
+
+// updateOutOfSyncCounts updates `counts` of consecutive checks for which replicas
+// have been out of sync, i.e. their Pods are not ready.  It returns the indices of
+// replicas that have just reached outOfSyncWarningThreshold.
+func updateOutOfSyncCounts(counts map[int]int, ss *StatusSet) []int {
+	var reached []int
+	for i, pod := range ss.Pods {
+		if i == ss.Primary || isPodReady(pod) {
+			delete(counts, i)
+			continue
+		}
+		counts[i]++
+		if counts[i] == outOfSyncWarningThreshold {
+			reached = append(reached, i)
+		}
+	}
+	for i := range counts {
+		if i >= len(ss.Pods) {
+			delete(counts, i)
+		}
+	}
+	return reached
+}
+
+// countSyncedReplicas returns the number of synced instances including the primary.
+// An instance is synced if its Pod is ready and it is not an errant replica.
+// The readiness probe of a replica fails if the replication is not running or delayed,
+// so a ready replica is in sync with the primary.
+func countSyncedReplicas(ss *StatusSet) int {
+	var synced int
+	for i, pod := range ss.Pods {
+		if !isPodReady(pod) {
+			continue
+		}
+		if i != ss.Primary && isErrantReplica(ss, i) {
+			continue
+		}
+		synced++
+	}
+	return synced
+}
+
+// defaultMaxDelaySeconds is the delay threshold of the readiness probe
+// when `spec.maxDelaySeconds` is not set.
+const defaultMaxDelaySeconds = 60
+
+// outOfSyncReplicas returns the instances that are not counted by countSyncedReplicas
+// together with the criterion that classified each instance as out of sync.
+func outOfSyncReplicas(ss *StatusSet) []mocov1beta2.OutOfSyncReplica {
+	var replicas []mocov1beta2.OutOfSyncReplica
+	for i, pod := range ss.Pods {
+		if i == ss.Primary {
+			if !isPodReady(pod) {
+				replicas = append(replicas, mocov1beta2.OutOfSyncReplica{Index: i, Reason: "not_ready"})
+			}
+			continue
+		}
+		if !isPodReady(pod) || isErrantReplica(ss, i) {
+			replicas = append(replicas, mocov1beta2.OutOfSyncReplica{Index: i, Reason: outOfSyncReason(ss, i)})
+		}
+	}
+	return replicas
+}
+
+// maxReplicationDelay returns the largest `Seconds_Behind_Master` among the replicas.
+// Replicas whose status is unavailable or whose SQL thread is not running
+// (`Seconds_Behind_Master` is NULL) are ignored.
+func maxReplicationDelay(ss *StatusSet) int64 {
+	var delay int64
+	for i, ist := range ss.MySQLStatus {
+		if i == ss.Primary || ist == nil || ist.ReplicaStatus == nil {
+			continue
+		}
+		sbm := ist.ReplicaStatus.SecondsBehindMaster
+		if sbm.Valid && sbm.Int64 > delay {
+			delay = sbm.Int64
+		}
+	}
+	return delay
+}
+
+// outOfSyncReason returns the reason why the replica instance `index` is out of sync.
+// The readiness probe of a replica fails if the replication threads are stopped or
+// the replica is delayed, so the reason is estimated from the replication status.
+func outOfSyncReason(ss *StatusSet, index int) string {
+	if isErrantReplica(ss, index) {
+		return "errant"
+	}
+	ist := ss.MySQLStatus[index]
+	if ist == nil {
+		return "unavailable"
+	}
+	rs := ist.ReplicaStatus
+	switch {
+	case rs == nil || rs.SlaveIORunning != "Yes" || rs.LastIoErrno != 0:
+		return "io_error"
+	case rs.SlaveSQLRunning != "Yes" || rs.LastSQLErrno != 0:
+		return "sql_error"
+	}
+
+	maxDelay := defaultMaxDelaySeconds
+	if ss.Cluster.Spec.MaxDelaySeconds != nil {
+		maxDelay = *ss.Cluster.Spec.MaxDelaySeconds
+	}
+	switch {
+	case maxDelay > 0 && rs.SecondsBehindMaster.Valid && rs.SecondsBehindMaster.Int64 > int64(maxDelay):
+		return "high_lag"
+	case rs.SecondsBehindMaster.Valid && rs.SecondsBehindMaster.Int64 > 0:
+		return "applying_backlog"
+	case ss.ExecutedGTID != "" && !dbop.EqualGTIDSets(ist.GlobalVariables.ExecutedGTID, ss.ExecutedGTID):
+		return "gtid_behind"
+	}
+	return "not_ready"
+}
+
+// multiSourceCondition returns the condition that reports whether any instance
+// has more than one replication channel.  MOCO assumes single-source replication.
+func multiSourceCondition(ss *StatusSet) metav1.Condition {
+	var instances []string
+	for i, ist := range ss.MySQLStatus {
+		if ist == nil {
+			continue
+		}
+		if len(ist.ReplicationChannels) > 1 {
+			instances = append(instances, strconv.Itoa(i))
+		}
+	}
+	if len(instances) == 0 {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionMultiSourceReplicationDetected,
+			Status:  metav1.ConditionFalse,
+			Reason:  "SingleSource",
+			Message: "no instance has multiple replication channels",
+		}
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionMultiSourceReplicationDetected,
+		Status:  metav1.ConditionTrue,
+		Reason:  "MultiSource",
+		Message: "instances with multiple replication channels: " + strings.Join(instances, ","),
+	}
+}
+
+// instanceStatuses returns the status of each instance to be recorded in `status.instances`.
+// This is separated from the in-memory MySQLInstanceStatus gathered from `mysqld`.
+//
+// The replication config hash of a replica is updated when the replica is observed
+// replicating from the primary, and cleared when it is observed doing otherwise.
+// The hash of an unavailable instance is kept from `prev`.
+//
+// As the operations of a check refer to the MySQLCluster gathered before this update,
+// a replica whose `Master_Host` differs from the primary is tolerated in a single check.
+// See toleratesSourceMismatch.
+func instanceStatuses(ss *StatusSet, prev []mocov1beta2.InstanceStatus) []mocov1beta2.InstanceStatus {
+	statuses := make([]mocov1beta2.InstanceStatus, len(ss.MySQLStatus))
+	for _, st := range prev {
+		if st.Index >= 0 && st.Index < len(statuses) {
+			statuses[st.Index].ReplicationConfigHash = st.ReplicationConfigHash
+		}
+	}
+	source := replicationSource(ss)
+	for i, ist := range ss.MySQLStatus {
+		statuses[i].Index = i
+		statuses[i].Available = ist != nil
+		statuses[i].Primary = i == ss.Primary
+		statuses[i].Recovering = isRecovering(ist)
+		if i != ss.Primary && ist != nil && ist.ReplicaStatus != nil {
+			statuses[i].SQLDelaySeconds = int32(ist.ReplicaStatus.SQLDelay)
+			if rd := ist.ReplicaStatus.SQLRemainingDelay; rd.Valid {
+				statuses[i].SQLRemainingDelaySeconds = pointer.Int32(int32(rd.Int64))
+			}
+		}
+		switch {
+		case ist == nil:
+		case i != ss.Primary && replicatesFrom(ist, source):
+			statuses[i].ReplicationConfigHash = replicationConfigHash(source)
+		default:
+			statuses[i].ReplicationConfigHash = ""
+		}
+	}
+	return statuses
+}
+
+// mergeCloneStatuses updates the last completed clone operations of instances
+// recorded in `clones` with the gathered status.  The records of instances whose
+// status is not available are kept as they are.
+func mergeCloneStatuses(clones []mocov1beta2.InstanceCloneStatus, ss *StatusSet) []mocov1beta2.InstanceCloneStatus {
+	byIndex := make(map[int]mocov1beta2.InstanceCloneStatus)
+	for _, c := range clones {
+		byIndex[c.Index] = c
+	}
+	for i, ist := range ss.MySQLStatus {
+		if ist == nil {
+			continue
+		}
+		cs := ist.CloneStatus
+		if cs == nil || cs.State.String != "Completed" || !cs.EndTime.Valid {
+			continue
+		}
+		// metav1.Time is serialized in seconds
+		endTime := cs.EndTime.Time.UTC().Truncate(time.Second)
+		byIndex[i] = mocov1beta2.InstanceCloneStatus{
+			Index:   i,
+			Source:  cs.Source.String,
+			EndTime: metav1.NewTime(endTime),
+		}
+	}
+
+	var merged []mocov1beta2.InstanceCloneStatus
+	for i := 0; i < len(ss.MySQLStatus); i++ {
+		if c, ok := byIndex[i]; ok {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
+// pruneReplicaRecoveries removes the entries of `status.replicaRecoveries` for instances
+// that no longer have a replication SQL error.  The entries for unreachable instances are kept.
+func pruneReplicaRecoveries(recoveries []mocov1beta2.ReplicaRecoveryStatus, ss *StatusSet) []mocov1beta2.ReplicaRecoveryStatus {
+	var pruned []mocov1beta2.ReplicaRecoveryStatus
+	for _, r := range recoveries {
+		if r.Index >= len(ss.MySQLStatus) || r.Index == ss.Primary {
+			continue
+		}
+		ist := ss.MySQLStatus[r.Index]
+		if ist != nil && (ist.ReplicaStatus == nil || ist.ReplicaStatus.LastSQLErrno == 0) {
+			continue
+		}
+		pruned = append(pruned, r)
+	}
+	return pruned
+}
+
+// charsetCondition returns the condition that reports whether any replica uses
+// a different `character_set_server` or `collation_server` from the primary.
+func charsetCondition(ss *StatusSet) metav1.Condition {
+	pst := ss.MySQLStatus[ss.Primary]
+	if pst == nil {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionCharsetMismatch,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "PrimaryUnavailable",
+			Message: "the primary status is not available",
+		}
+	}
+
+	var instances []string
+	for i, ist := range ss.MySQLStatus {
+		if i == ss.Primary || ist == nil {
+			continue
+		}
+		if ist.GlobalVariables.CharacterSetServer != pst.GlobalVariables.CharacterSetServer ||
+			ist.GlobalVariables.CollationServer != pst.GlobalVariables.CollationServer {
+			instances = append(instances, strconv.Itoa(i))
+		}
+	}
+	if len(instances) == 0 {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionCharsetMismatch,
+			Status:  metav1.ConditionFalse,
+			Reason:  "CharsetMatched",
+			Message: "all replicas use the same character set and collation as the primary",
+		}
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionCharsetMismatch,
+		Status:  metav1.ConditionTrue,
+		Reason:  "CharsetMismatched",
+		Message: "replicas with a different character set or collation: " + strings.Join(instances, ","),
+	}
+}
+
+// autoPositionCondition returns the condition that reports whether any replica
+// replicates data without GTID auto-positioning.
+func autoPositionCondition(ss *StatusSet) metav1.Condition {
+	var instances []string
+	for i, ist := range ss.MySQLStatus {
+		if ist == nil || ist.ReplicaStatus == nil {
+			continue
+		}
+		if ist.ReplicaStatus.AutoPosition == "0" {
+			instances = append(instances, strconv.Itoa(i))
+		}
+	}
+	if len(instances) == 0 {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionGTIDAutoPositionDisabled,
+			Status:  metav1.ConditionFalse,
+			Reason:  "AutoPositionEnabled",
+			Message: "all replicas use GTID auto-positioning",
+		}
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionGTIDAutoPositionDisabled,
+		Status:  metav1.ConditionTrue,
+		Reason:  "AutoPositionDisabled",
+		Message: "replicas without GTID auto-positioning: " + strings.Join(instances, ","),
+	}
+}
+
+// cloneFailedCondition returns the condition that reports the instances whose last
+// clone operation failed.  Such an instance never becomes a healthy replica by itself.
+func cloneFailedCondition(ss *StatusSet) metav1.Condition {
+	var failures []string
+	for i, ist := range ss.MySQLStatus {
+		if ist == nil || ist.CloneStatus == nil || ist.CloneStatus.State.String != "Failed" {
+			continue
+		}
+		cs := ist.CloneStatus
+		failures = append(failures, fmt.Sprintf("%d (error %d: %s)", i, cs.ErrorNo.Int64, cs.ErrorMessage.String))
+	}
+	if len(failures) == 0 {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionCloneFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoCloneFailures",
+			Message: "no instance failed to clone",
+		}
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionCloneFailed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "CloneFailed",
+		Message: "instances failed to clone: " + strings.Join(failures, ", "),
+	}
+}
+
+// errantCondition returns the condition that reports the replicas having errant transactions,
+// i.e. transactions that the primary does not have.
+func errantCondition(ss *StatusSet) metav1.Condition {
+	if len(ss.Errants) == 0 {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionErrantReplicasDetected,
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoErrantReplicas",
+			Message: "no replicas have errant transactions",
+		}
+	}
+
+	errants := slices.Clone(ss.Errants)
+	slices.Sort(errants)
+	instances := make([]string, len(errants))
+	for i, index := range errants {
+		instances[i] = strconv.Itoa(index)
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionErrantReplicasDetected,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ErrantReplicasFound",
+		Message: "replicas with errant transactions: " + strings.Join(instances, ","),
+	}
+}
+
+// outOfSyncCondition returns the condition that reports the replicas delayed
+// over `spec.maxReplicationLagSeconds`.
+func outOfSyncCondition(ss *StatusSet) metav1.Condition {
+	if ss.Cluster.Spec.MaxReplicationLagSeconds <= 0 {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionOutOfSync,
+			Status:  metav1.ConditionFalse,
+			Reason:  "LagCheckDisabled",
+			Message: "spec.maxReplicationLagSeconds is not set",
+		}
+	}
+
+	var instances []string
+	for i := range ss.MySQLStatus {
+		if i != ss.Primary && isLagging(ss, i) {
+			instances = append(instances, strconv.Itoa(i))
+		}
+	}
+	if len(instances) == 0 {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionOutOfSync,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ReplicasInSync",
+			Message: "no replicas are delayed over the threshold",
+		}
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionOutOfSync,
+		Status:  metav1.ConditionTrue,
+		Reason:  "ReplicationLagExceeded",
+		Message: "replicas delayed over the threshold: " + strings.Join(instances, ","),
+	}
+}
+
+// allReplicasDownCondition returns the condition that reports whether the primary
+// is running without any available replica.
+func allReplicasDownCondition(ss *StatusSet) metav1.Condition {
+	if !allReplicasDown(ss) {
+		return metav1.Condition{
+			Type:    mocov1beta2.ConditionAllReplicasDown,
+			Status:  metav1.ConditionFalse,
+			Reason:  "ReplicasAvailable",
+			Message: "at least one replica is reachable or the primary is not writable",
+		}
+	}
+	msg := "all replicas are down; writes wait for semi-synchronous replicas"
+	if ss.Cluster.Spec.AllReplicasDownPolicy == mocov1beta2.AllReplicasDownPolicyContinue {
+		msg = "all replicas are down; the primary keeps accepting writes without semi-synchronous replication"
+	}
+	return metav1.Condition{
+		Type:    mocov1beta2.ConditionAllReplicasDown,
+		Status:  metav1.ConditionTrue,
+		Reason:  "AllReplicasDown",
+		Message: msg,
+	}
 }
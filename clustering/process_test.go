@@ -0,0 +1,2210 @@
+package clustering
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/constants"
+	"github.com/cybozu-go/moco/pkg/dbop"
+	"github.com/cybozu-go/moco/pkg/metrics"
+	"github.com/cybozu-go/moco/pkg/password"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRequeueReason(t *testing.T) {
+	testCases := []struct {
+		name        string
+		state       ClusterState
+		needSwitch  bool
+		restartWait time.Duration
+		expected    string
+	}{
+		{name: "healthy", state: StateHealthy, expected: ""},
+		{name: "healthy-switchover", state: StateHealthy, needSwitch: true, expected: ""},
+		{name: "cloning", state: StateCloning, expected: "CloneInProgress"},
+		{name: "restoring", state: StateRestoring, expected: "RestoreInProgress"},
+		{name: "degraded", state: StateDegraded, expected: "WaitingForReplication"},
+		{name: "degraded-switchover", state: StateDegraded, needSwitch: true, expected: ""},
+		{name: "incomplete", state: StateIncomplete, expected: "WaitingForReplication"},
+		{name: "failed", state: StateFailed, expected: ""},
+		{name: "failed-primary-restarting", state: StateFailed, restartWait: time.Second, expected: "WaitingForPrimaryRestart"},
+		{name: "lost", state: StateLost, expected: "WaitingForRecovery"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := &StatusSet{State: tc.state, NeedSwitch: tc.needSwitch, PrimaryRestartWait: tc.restartWait}
+			actual := requeueReason(ss)
+			if actual != tc.expected {
+				t.Errorf("unexpected reason: expected=%q, actual=%q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStateCondition(t *testing.T) {
+	testCases := []struct {
+		state  ClusterState
+		reason string
+	}{
+		{state: StateHealthy, reason: "Healthy"},
+		{state: StateDegraded, reason: "Degraded"},
+		{state: StateFailed, reason: "Failed"},
+		{state: StateLost, reason: "Lost"},
+		{state: StateIncomplete, reason: "Incomplete"},
+		{state: StateCloning, reason: "Cloning"},
+		{state: StateRestoring, reason: "Restoring"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.reason, func(t *testing.T) {
+			cond := stateCondition(mocov1beta2.ConditionAvailable, metav1.ConditionTrue, tc.state)
+			if cond.Type != mocov1beta2.ConditionAvailable || cond.Status != metav1.ConditionTrue {
+				t.Errorf("unexpected condition: %+v", cond)
+			}
+			if cond.Reason != tc.reason {
+				t.Errorf("unexpected condition reason: expected=%s, actual=%s", tc.reason, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestBinlogCondition(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   *dbop.MySQLInstanceStatus
+		expected metav1.ConditionStatus
+		reason   string
+	}{
+		{
+			name:     "unavailable",
+			status:   nil,
+			expected: metav1.ConditionUnknown,
+			reason:   "PrimaryUnavailable",
+		},
+		{
+			name:     "enabled",
+			status:   &dbop.MySQLInstanceStatus{GlobalVariables: dbop.GlobalVariables{LogBin: true}},
+			expected: metav1.ConditionFalse,
+			reason:   "BinlogEnabled",
+		},
+		{
+			name:     "disabled",
+			status:   &dbop.MySQLInstanceStatus{GlobalVariables: dbop.GlobalVariables{LogBin: false}},
+			expected: metav1.ConditionTrue,
+			reason:   "BinlogDisabled",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := &StatusSet{
+				Primary:     0,
+				MySQLStatus: []*dbop.MySQLInstanceStatus{tc.status},
+			}
+			cond := binlogCondition(ss)
+			if cond.Status != tc.expected {
+				t.Errorf("unexpected condition status: expected=%s, actual=%s", tc.expected, cond.Status)
+			}
+			if cond.Reason != tc.reason {
+				t.Errorf("unexpected condition reason: expected=%s, actual=%s", tc.reason, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestCheckInterval(t *testing.T) {
+	testCases := []struct {
+		name     string
+		seconds  int32
+		expected time.Duration
+	}{
+		{name: "default", seconds: 0, expected: time.Minute},
+		{name: "negative", seconds: -1, expected: time.Minute},
+		{name: "override", seconds: 10, expected: 10 * time.Second},
+		{name: "too-short", seconds: 1, expected: minCheckInterval},
+		{name: "too-long", seconds: 86400, expected: maxCheckInterval},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual := checkInterval(time.Minute, tc.seconds)
+			if actual != tc.expected {
+				t.Errorf("unexpected interval: expected=%v, actual=%v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWaitInterval(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name     string
+		interval time.Duration
+		elapsed  time.Duration
+		waiting  bool
+		expected time.Duration
+	}{
+		{name: "not-waiting", interval: 10 * time.Second, elapsed: time.Hour, expected: 10 * time.Second},
+		{name: "just-started", interval: 10 * time.Second, waiting: true, expected: 10 * time.Second},
+		{name: "doubled", interval: 10 * time.Second, elapsed: 10 * time.Second, waiting: true, expected: 20 * time.Second},
+		{name: "doubled-twice", interval: 10 * time.Second, elapsed: 25 * time.Second, waiting: true, expected: 40 * time.Second},
+		{name: "capped", interval: 10 * time.Second, elapsed: time.Hour, waiting: true, expected: maxWaitInterval},
+		{name: "long-interval", interval: 5 * time.Minute, elapsed: time.Hour, waiting: true, expected: 5 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var since time.Time
+			if tc.waiting {
+				since = now.Add(-tc.elapsed)
+			}
+			actual := waitInterval(tc.interval, since, now)
+			if actual != tc.expected {
+				t.Errorf("unexpected interval: expected=%v, actual=%v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSetWaiting(t *testing.T) {
+	p := &managerProcess{}
+	p.setWaiting(true)
+	since := p.waitingSince
+	if since.IsZero() {
+		t.Fatal("the waiting time is not recorded")
+	}
+	p.setWaiting(true)
+	if !p.waitingSince.Equal(since) {
+		t.Error("the waiting time should not be updated while waiting")
+	}
+	p.setWaiting(false)
+	if !p.waitingSince.IsZero() {
+		t.Error("the waiting time is not reset")
+	}
+}
+
+func TestUpdateOutOfSyncCounts(t *testing.T) {
+	newStatusSet := func(replica2Ready bool) *StatusSet {
+		return newSS(3, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(replica2Ready, false, false).
+			withMySQL(newMySQL("1234", false, false, false).build()).
+			withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+			withMySQL(newMySQL("12", true, false, false).withPrimary(testPrimaryHostname).build()).
+			build()
+	}
+
+	counts := make(map[int]int)
+	var fired int
+	for i := 0; i < outOfSyncWarningThreshold*2; i++ {
+		reached := updateOutOfSyncCounts(counts, newStatusSet(false))
+		for _, index := range reached {
+			if index != 2 {
+				t.Fatalf("unexpected instance reached the threshold: %d", index)
+			}
+			if i != outOfSyncWarningThreshold-1 {
+				t.Errorf("the threshold is reached at an unexpected check: %d", i)
+			}
+			fired++
+		}
+	}
+	if fired != 1 {
+		t.Errorf("the warning should fire exactly once: fired=%d", fired)
+	}
+	if counts[2] != outOfSyncWarningThreshold*2 {
+		t.Errorf("unexpected count: %d", counts[2])
+	}
+	if _, ok := counts[1]; ok {
+		t.Error("in-sync replica should not be counted")
+	}
+
+	// the count is reset once the replica catches up.
+	updateOutOfSyncCounts(counts, newStatusSet(true))
+	if len(counts) != 0 {
+		t.Errorf("counts should be reset: %v", counts)
+	}
+	for i := 0; i < outOfSyncWarningThreshold; i++ {
+		fired += len(updateOutOfSyncCounts(counts, newStatusSet(false)))
+	}
+	if fired != 2 {
+		t.Errorf("the warning should fire again after recovery: fired=%d", fired)
+	}
+}
+
+func TestCountSyncedReplicas(t *testing.T) {
+	testCases := []struct {
+		name      string
+		statusSet *StatusSet
+		expected  int
+	}{
+		{
+			name: "single",
+			statusSet: newSS(1, 0, false, false, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("123", false, false, false).build()).
+				build(),
+			expected: 1,
+		},
+		{
+			name: "all-synced",
+			statusSet: newSS(3, 0, false, false, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("1234", false, false, false).build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				build(),
+			expected: 3,
+		},
+		{
+			name: "primary-not-ready",
+			statusSet: newSS(3, 0, false, false, false, false).
+				withPod(false, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("1234", false, false, false).build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				build(),
+			expected: 2,
+		},
+		{
+			name: "replica-not-ready",
+			statusSet: newSS(3, 0, false, false, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withPod(false, false, false).
+				withMySQL(newMySQL("1234", false, false, false).build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				withMySQL(nil).
+				build(),
+			expected: 2,
+		},
+		{
+			name: "errant-replica-ready",
+			statusSet: newSS(3, 0, false, false, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("1234", false, false, false).build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				withMySQL(newMySQL("123", true, true, false).withPrimary(testPrimaryHostname).build()).
+				build(),
+			expected: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual := countSyncedReplicas(tc.statusSet)
+			if actual != tc.expected {
+				t.Errorf("unexpected synced replicas: expected=%d, actual=%d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOutOfSyncReplicas(t *testing.T) {
+	running := func(rs *dbop.ReplicaStatus) {
+		rs.SlaveIORunning = "Yes"
+		rs.SlaveSQLRunning = "Yes"
+		rs.SecondsBehindMaster = sql.NullInt64{Valid: true}
+	}
+
+	testCases := []struct {
+		name     string
+		ready    bool
+		errant   bool
+		down     bool
+		gtid     string
+		primary  string
+		modify   func(rs *dbop.ReplicaStatus)
+		expected []mocov1beta2.OutOfSyncReplica
+	}{
+		{
+			name:   "synced",
+			ready:  true,
+			gtid:   "1234",
+			modify: running,
+		},
+		{
+			name:     "unavailable",
+			down:     true,
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "unavailable"}},
+		},
+		{
+			name:     "errant",
+			ready:    true,
+			errant:   true,
+			gtid:     "1234",
+			modify:   running,
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "errant"}},
+		},
+		{
+			name: "io-error",
+			gtid: "123",
+			modify: func(rs *dbop.ReplicaStatus) {
+				running(rs)
+				rs.SlaveIORunning = "Connecting"
+				rs.LastIoErrno = 2003
+			},
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "io_error"}},
+		},
+		{
+			name: "sql-error",
+			gtid: "123",
+			modify: func(rs *dbop.ReplicaStatus) {
+				running(rs)
+				rs.SlaveSQLRunning = "No"
+				rs.LastSQLErrno = 1062
+			},
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "sql_error"}},
+		},
+		{
+			name: "high-lag",
+			gtid: "123",
+			modify: func(rs *dbop.ReplicaStatus) {
+				running(rs)
+				rs.SecondsBehindMaster.Int64 = 61
+			},
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "high_lag"}},
+		},
+		{
+			name: "applying-backlog",
+			gtid: "123",
+			modify: func(rs *dbop.ReplicaStatus) {
+				running(rs)
+				rs.SecondsBehindMaster.Int64 = 3
+			},
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "applying_backlog"}},
+		},
+		{
+			name:     "gtid-behind",
+			gtid:     "123",
+			modify:   running,
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "gtid_behind"}},
+		},
+		{
+			name:     "gtid-behind-uuid",
+			gtid:     "8e349184-bc14-11e3-8d4c-0800272864ba:1-29",
+			primary:  "8e349184-bc14-11e3-8d4c-0800272864ba:1-30",
+			modify:   running,
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "gtid_behind"}},
+		},
+		{
+			name:     "not-ready",
+			gtid:     "1234",
+			modify:   running,
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "not_ready"}},
+		},
+		{
+			name:     "not-ready-gtid-formatted-differently",
+			gtid:     "8E349184-BC14-11E3-8D4C-0800272864BA:1-29,\n8e349184-bc14-11e3-8d4c-0800272864bb:1-3:4",
+			primary:  "8e349184-bc14-11e3-8d4c-0800272864bb:1-4,8e349184-bc14-11e3-8d4c-0800272864ba:1-29",
+			modify:   running,
+			expected: []mocov1beta2.OutOfSyncReplica{{Index: 1, Reason: "not_ready"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			primaryGTID := tc.primary
+			if primaryGTID == "" {
+				primaryGTID = "1234"
+			}
+			b := newSS(2, 0, false, false, false, false).
+				withPod(true, false, false).
+				withPod(tc.ready, false, false).
+				withMySQL(newMySQL(primaryGTID, false, false, false).build())
+			if tc.down {
+				b = b.withMySQL(nil)
+			} else {
+				b = b.withMySQL(newMySQL(tc.gtid, true, tc.errant, false).withPrimary(testPrimaryHostname).build())
+			}
+			ss := b.build()
+			if tc.modify != nil {
+				tc.modify(ss.MySQLStatus[1].ReplicaStatus)
+			}
+
+			actual := outOfSyncReplicas(ss)
+			if !cmp.Equal(actual, tc.expected) {
+				t.Errorf("unexpected out-of-sync replicas: %s", cmp.Diff(tc.expected, actual))
+			}
+		})
+	}
+}
+
+func TestMaxReplicationDelay(t *testing.T) {
+	newReplica := func(delay *int64) *dbop.MySQLInstanceStatus {
+		st := newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()
+		if delay != nil {
+			st.ReplicaStatus.SecondsBehindMaster = sql.NullInt64{Int64: *delay, Valid: true}
+		}
+		return st
+	}
+	delay := func(n int64) *int64 { return &n }
+
+	ss := newSS(4, 0, false, false, false, false).
+		withPod(true, false, false).
+		withPod(true, false, false).
+		withPod(false, false, false).
+		withPod(true, false, false).
+		withMySQL(newMySQL("1234", false, false, false).build()).
+		withMySQL(newReplica(delay(3))).
+		withMySQL(nil).
+		withMySQL(newReplica(nil)).
+		build()
+	if actual := maxReplicationDelay(ss); actual != 3 {
+		t.Errorf("unexpected delay: expected=3, actual=%d", actual)
+	}
+
+	ss.MySQLStatus[3] = newReplica(delay(120))
+	if actual := maxReplicationDelay(ss); actual != 120 {
+		t.Errorf("unexpected delay: expected=120, actual=%d", actual)
+	}
+
+	// the primary is never counted even if it used to be a replica.
+	ss.MySQLStatus[0].ReplicaStatus = &dbop.ReplicaStatus{SecondsBehindMaster: sql.NullInt64{Int64: 1000, Valid: true}}
+	if actual := maxReplicationDelay(ss); actual != 120 {
+		t.Errorf("unexpected delay: expected=120, actual=%d", actual)
+	}
+}
+
+func TestMultiSourceCondition(t *testing.T) {
+	testCases := []struct {
+		name     string
+		statuses []*dbop.MySQLInstanceStatus
+		expected metav1.ConditionStatus
+		reason   string
+	}{
+		{
+			name: "no-replication",
+			statuses: []*dbop.MySQLInstanceStatus{
+				{},
+				nil,
+			},
+			expected: metav1.ConditionFalse,
+			reason:   "SingleSource",
+		},
+		{
+			name: "single-source",
+			statuses: []*dbop.MySQLInstanceStatus{
+				{},
+				{ReplicationChannels: []string{""}},
+			},
+			expected: metav1.ConditionFalse,
+			reason:   "SingleSource",
+		},
+		{
+			name: "multi-source",
+			statuses: []*dbop.MySQLInstanceStatus{
+				{},
+				{ReplicationChannels: []string{"", "extra"}},
+			},
+			expected: metav1.ConditionTrue,
+			reason:   "MultiSource",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cond := multiSourceCondition(&StatusSet{MySQLStatus: tc.statuses})
+			if cond.Status != tc.expected {
+				t.Errorf("unexpected condition status: expected=%s, actual=%s", tc.expected, cond.Status)
+			}
+			if cond.Reason != tc.reason {
+				t.Errorf("unexpected condition reason: expected=%s, actual=%s", tc.reason, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestInstanceStatuses(t *testing.T) {
+	ss := newSS(3, 1, false, false, false, false).
+		withPod(true, false, false).
+		withPod(true, false, false).
+		withPod(false, false, false).
+		withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+		withMySQL(newMySQL("1234", false, false, false).build()).
+		withMySQL(nil).
+		build()
+	ss.MySQLStatus[0].RecoveredTransactions = 2
+	// instance 0 is a replica delayed by MASTER_DELAY.
+	ss.MySQLStatus[0].ReplicaStatus.SQLDelay = 3600
+	ss.MySQLStatus[0].ReplicaStatus.SQLRemainingDelay = sql.NullInt64{Valid: true, Int64: 120}
+
+	prev := []mocov1beta2.InstanceStatus{
+		{Index: 0, Available: false, ReplicationConfigHash: "abc"},
+		{Index: 2, Available: true, ReplicationConfigHash: "unavailable"},
+		{Index: 5, ReplicationConfigHash: "removed"},
+	}
+	expected := []mocov1beta2.InstanceStatus{
+		{Index: 0, Available: true, Recovering: true, SQLDelaySeconds: 3600, SQLRemainingDelaySeconds: pointer.Int32(120)},
+		{Index: 1, Available: true, Primary: true},
+		{Index: 2, Available: false, ReplicationConfigHash: "unavailable"},
+	}
+	if actual := instanceStatuses(ss, prev); !cmp.Equal(actual, expected) {
+		t.Errorf("unexpected instance statuses: %s", cmp.Diff(expected, actual))
+	}
+}
+
+func TestMergeCloneStatuses(t *testing.T) {
+	endTime := time.Date(2021, 4, 1, 12, 34, 56, 789000000, time.UTC)
+	completed := &dbop.MySQLInstanceStatus{
+		CloneStatus: &dbop.CloneStatus{
+			State:   sql.NullString{Valid: true, String: "Completed"},
+			Source:  sql.NullString{Valid: true, String: "moco-test-0.moco-test.ns.svc:3306"},
+			EndTime: sql.NullTime{Valid: true, Time: endTime},
+		},
+	}
+	inProgress := &dbop.MySQLInstanceStatus{
+		CloneStatus: &dbop.CloneStatus{
+			State:  sql.NullString{Valid: true, String: "In Progress"},
+			Source: sql.NullString{Valid: true, String: "moco-test-0.moco-test.ns.svc:3306"},
+		},
+	}
+	old := []mocov1beta2.InstanceCloneStatus{
+		{Index: 1, Source: "old-source:3306", EndTime: metav1.NewTime(endTime.Add(-time.Hour).Truncate(time.Second))},
+		{Index: 2, Source: "old-source:3306", EndTime: metav1.NewTime(endTime.Add(-time.Hour).Truncate(time.Second))},
+	}
+
+	ss := &StatusSet{
+		MySQLStatus: []*dbop.MySQLInstanceStatus{{}, completed, nil},
+	}
+	merged := mergeCloneStatuses(old, ss)
+	if len(merged) != 2 {
+		t.Fatalf("unexpected number of records: %v", merged)
+	}
+	if merged[0].Index != 1 || merged[0].Source != "moco-test-0.moco-test.ns.svc:3306" {
+		t.Errorf("the record of instance 1 should be updated: %v", merged[0])
+	}
+	if !merged[0].EndTime.Time.Equal(endTime.Truncate(time.Second)) {
+		t.Errorf("unexpected end time: %v", merged[0].EndTime)
+	}
+	if merged[1] != old[1] {
+		t.Errorf("the record of unavailable instance 2 should be kept: %v", merged[1])
+	}
+
+	ss = &StatusSet{
+		MySQLStatus: []*dbop.MySQLInstanceStatus{{}, inProgress},
+	}
+	merged = mergeCloneStatuses(nil, ss)
+	if len(merged) != 0 {
+		t.Errorf("in-progress clone should not be recorded: %v", merged)
+	}
+}
+
+func TestPruneReplicaRecoveries(t *testing.T) {
+	newStatus := func(errno int) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{ReplicaStatus: &dbop.ReplicaStatus{LastSQLErrno: errno}}
+	}
+	recoveries := []mocov1beta2.ReplicaRecoveryStatus{
+		{Index: 0, Attempts: 1, LastSQLErrno: 1205},
+		{Index: 1, Attempts: 2, LastSQLErrno: 1205},
+		{Index: 2, Attempts: 3, LastSQLErrno: 1213},
+		{Index: 3, Attempts: 1, LastSQLErrno: 1213},
+		{Index: 4, Attempts: 4, LastSQLErrno: 1594},
+		{Index: 5, Attempts: 1, LastSQLErrno: 1594},
+	}
+
+	ss := &StatusSet{
+		Primary: 0,
+		// instance 0 is the primary, 1 and 3 have recovered, 2 is unavailable,
+		// 4 still has an error, and 5 has been removed by scaling in.
+		MySQLStatus: []*dbop.MySQLInstanceStatus{{}, newStatus(0), nil, newStatus(0), newStatus(1594)},
+	}
+	expected := []mocov1beta2.ReplicaRecoveryStatus{
+		{Index: 2, Attempts: 3, LastSQLErrno: 1213},
+		{Index: 4, Attempts: 4, LastSQLErrno: 1594},
+	}
+	if diff := cmp.Diff(expected, pruneReplicaRecoveries(recoveries, ss)); diff != "" {
+		t.Errorf("unexpected recoveries (-want +got):\n%s", diff)
+	}
+}
+
+func TestCharsetCondition(t *testing.T) {
+	newStatus := func(charset, collation string) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{
+			GlobalVariables: dbop.GlobalVariables{
+				CharacterSetServer: charset,
+				CollationServer:    collation,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		statuses []*dbop.MySQLInstanceStatus
+		expected metav1.ConditionStatus
+		reason   string
+	}{
+		{
+			name:     "primary-unavailable",
+			statuses: []*dbop.MySQLInstanceStatus{nil, newStatus("utf8mb4", "utf8mb4_bin")},
+			expected: metav1.ConditionUnknown,
+			reason:   "PrimaryUnavailable",
+		},
+		{
+			name: "matched",
+			statuses: []*dbop.MySQLInstanceStatus{
+				newStatus("utf8mb4", "utf8mb4_bin"),
+				newStatus("utf8mb4", "utf8mb4_bin"),
+				nil,
+			},
+			expected: metav1.ConditionFalse,
+			reason:   "CharsetMatched",
+		},
+		{
+			name: "charset-mismatched",
+			statuses: []*dbop.MySQLInstanceStatus{
+				newStatus("utf8mb4", "utf8mb4_bin"),
+				newStatus("latin1", "latin1_swedish_ci"),
+			},
+			expected: metav1.ConditionTrue,
+			reason:   "CharsetMismatched",
+		},
+		{
+			name: "collation-mismatched",
+			statuses: []*dbop.MySQLInstanceStatus{
+				newStatus("utf8mb4", "utf8mb4_bin"),
+				newStatus("utf8mb4", "utf8mb4_0900_ai_ci"),
+			},
+			expected: metav1.ConditionTrue,
+			reason:   "CharsetMismatched",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cond := charsetCondition(&StatusSet{Primary: 0, MySQLStatus: tc.statuses})
+			if cond.Status != tc.expected {
+				t.Errorf("unexpected condition status: expected=%s, actual=%s", tc.expected, cond.Status)
+			}
+			if cond.Reason != tc.reason {
+				t.Errorf("unexpected condition reason: expected=%s, actual=%s", tc.reason, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestAutoPositionCondition(t *testing.T) {
+	newStatus := func(autoPosition string) *dbop.MySQLInstanceStatus {
+		return &dbop.MySQLInstanceStatus{
+			ReplicaStatus: &dbop.ReplicaStatus{AutoPosition: autoPosition},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		statuses []*dbop.MySQLInstanceStatus
+		expected metav1.ConditionStatus
+		reason   string
+	}{
+		{
+			name:     "enabled",
+			statuses: []*dbop.MySQLInstanceStatus{{}, newStatus("1"), nil},
+			expected: metav1.ConditionFalse,
+			reason:   "AutoPositionEnabled",
+		},
+		{
+			name:     "disabled",
+			statuses: []*dbop.MySQLInstanceStatus{{}, newStatus("1"), newStatus("0")},
+			expected: metav1.ConditionTrue,
+			reason:   "AutoPositionDisabled",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cond := autoPositionCondition(&StatusSet{Primary: 0, MySQLStatus: tc.statuses})
+			if cond.Status != tc.expected {
+				t.Errorf("unexpected condition status: expected=%s, actual=%s", tc.expected, cond.Status)
+			}
+			if cond.Reason != tc.reason {
+				t.Errorf("unexpected condition reason: expected=%s, actual=%s", tc.reason, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestCloneFailedCondition(t *testing.T) {
+	newStatus := func(state string) *dbop.MySQLInstanceStatus {
+		cs := &dbop.CloneStatus{State: sql.NullString{String: state, Valid: true}}
+		if state == "Failed" {
+			cs.ErrorNo = sql.NullInt64{Int64: 3862, Valid: true}
+			cs.ErrorMessage = sql.NullString{String: "Clone Donor Error", Valid: true}
+		}
+		return &dbop.MySQLInstanceStatus{CloneStatus: cs}
+	}
+
+	testCases := []struct {
+		name     string
+		statuses []*dbop.MySQLInstanceStatus
+		expected metav1.ConditionStatus
+		reason   string
+		message  string
+	}{
+		{
+			name:     "no-failures",
+			statuses: []*dbop.MySQLInstanceStatus{{}, newStatus("Completed"), newStatus("In Progress"), nil},
+			expected: metav1.ConditionFalse,
+			reason:   "NoCloneFailures",
+			message:  "no instance failed to clone",
+		},
+		{
+			name:     "failed",
+			statuses: []*dbop.MySQLInstanceStatus{{}, newStatus("Failed"), newStatus("Completed"), newStatus("Failed")},
+			expected: metav1.ConditionTrue,
+			reason:   "CloneFailed",
+			message:  "instances failed to clone: 1 (error 3862: Clone Donor Error), 3 (error 3862: Clone Donor Error)",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cond := cloneFailedCondition(&StatusSet{Primary: 0, MySQLStatus: tc.statuses})
+			if cond.Status != tc.expected {
+				t.Errorf("unexpected condition status: expected=%s, actual=%s", tc.expected, cond.Status)
+			}
+			if cond.Reason != tc.reason {
+				t.Errorf("unexpected condition reason: expected=%s, actual=%s", tc.reason, cond.Reason)
+			}
+			if cond.Message != tc.message {
+				t.Errorf("unexpected condition message: %s", cond.Message)
+			}
+		})
+	}
+}
+
+func TestErrantCondition(t *testing.T) {
+	testCases := []struct {
+		name     string
+		errants  []int
+		expected metav1.ConditionStatus
+		reason   string
+		message  string
+	}{
+		{
+			name:     "none",
+			expected: metav1.ConditionFalse,
+			reason:   "NoErrantReplicas",
+			message:  "no replicas have errant transactions",
+		},
+		{
+			name:     "errants",
+			errants:  []int{2, 1},
+			expected: metav1.ConditionTrue,
+			reason:   "ErrantReplicasFound",
+			message:  "replicas with errant transactions: 1,2",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := &StatusSet{Errants: tc.errants}
+			cond := errantCondition(ss)
+			if cond.Status != tc.expected {
+				t.Errorf("unexpected condition status: expected=%s, actual=%s", tc.expected, cond.Status)
+			}
+			if cond.Reason != tc.reason {
+				t.Errorf("unexpected condition reason: expected=%s, actual=%s", tc.reason, cond.Reason)
+			}
+			if cond.Message != tc.message {
+				t.Errorf("unexpected condition message: %s", cond.Message)
+			}
+			if len(tc.errants) > 1 && tc.errants[0] != 2 {
+				t.Error("the errant list of the status set should not be modified")
+			}
+		})
+	}
+}
+
+type countingOperator struct {
+	dbop.NopOperator
+	counts dbop.CommandCounts
+}
+
+func (o countingOperator) CommandCounts() dbop.CommandCounts {
+	return o.counts
+}
+
+func TestRecordCommands(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ops         []dbop.Operator
+		expectQuery float64
+		expectExec  float64
+		expectWarn  bool
+	}{
+		{
+			name: "few",
+			ops: []dbop.Operator{
+				countingOperator{counts: dbop.CommandCounts{Query: 5, Exec: 2}},
+				nil,
+				countingOperator{counts: dbop.CommandCounts{Query: 5}},
+			},
+			expectQuery: 10,
+			expectExec:  2,
+		},
+		{
+			name: "too-many",
+			ops: []dbop.Operator{
+				countingOperator{counts: dbop.CommandCounts{Query: 100, Exec: 1}},
+				countingOperator{counts: dbop.CommandCounts{Query: 100}},
+			},
+			expectQuery: 200,
+			expectExec:  1,
+			expectWarn:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			p := &managerProcess{
+				metrics: metricsSet{
+					queryCommands: prometheus.NewCounter(prometheus.CounterOpts{Name: "query"}),
+					execCommands:  prometheus.NewCounter(prometheus.CounterOpts{Name: "exec"}),
+				},
+			}
+			var logs []string
+			log := funcr.New(func(prefix, args string) {
+				logs = append(logs, args)
+			}, funcr.Options{})
+			ctx := logr.NewContext(context.Background(), log)
+
+			p.recordCommands(ctx, &StatusSet{DBOps: tc.ops})
+
+			if v := testutil.ToFloat64(p.metrics.queryCommands); v != tc.expectQuery {
+				t.Errorf("unexpected query count: expected=%v, actual=%v", tc.expectQuery, v)
+			}
+			if v := testutil.ToFloat64(p.metrics.execCommands); v != tc.expectExec {
+				t.Errorf("unexpected exec count: expected=%v, actual=%v", tc.expectExec, v)
+			}
+			warned := len(logs) == 1 && strings.Contains(logs[0], "too many SQL commands")
+			if warned != tc.expectWarn {
+				t.Errorf("unexpected warning: expected=%v, logs=%v", tc.expectWarn, logs)
+			}
+		})
+	}
+}
+
+func TestDoClusterNotFound(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	p := &managerProcess{
+		client: c,
+		reader: c,
+		name:   types.NamespacedName{Namespace: "test", Name: "deleted"},
+	}
+	redo, err := p.do(context.Background())
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if redo {
+		t.Error("redo should be false")
+	}
+}
+
+func TestReadyStatus(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   mocov1beta2.ReadinessPolicy
+		state    ClusterState
+		expected metav1.ConditionStatus
+	}{
+		{name: "default-degraded", policy: "", state: StateDegraded, expected: metav1.ConditionTrue},
+		{name: "available-only-degraded", policy: mocov1beta2.ReadinessPolicyAvailableOnly, state: StateDegraded, expected: metav1.ConditionTrue},
+		{name: "available-only-failed", policy: mocov1beta2.ReadinessPolicyAvailableOnly, state: StateFailed, expected: metav1.ConditionFalse},
+		{name: "fully-healthy-degraded", policy: mocov1beta2.ReadinessPolicyFullyHealthy, state: StateDegraded, expected: metav1.ConditionFalse},
+		{name: "fully-healthy-healthy", policy: mocov1beta2.ReadinessPolicyFullyHealthy, state: StateHealthy, expected: metav1.ConditionTrue},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			available := metav1.ConditionFalse
+			healthy := metav1.ConditionFalse
+			switch tc.state {
+			case StateHealthy:
+				available = metav1.ConditionTrue
+				healthy = metav1.ConditionTrue
+			case StateDegraded:
+				available = metav1.ConditionTrue
+			}
+			actual := readyStatus(tc.policy, available, healthy)
+			if actual != tc.expected {
+				t.Errorf("unexpected status: expected=%s, actual=%s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDoMissingCredentials(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newCluster := func() *mocov1beta2.MySQLCluster {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "test"
+		cluster.Spec.Replicas = 3
+		return cluster
+	}
+	newSecret := func(deleteKey string) *corev1.Secret {
+		passwd, err := password.NewMySQLPassword()
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret := passwd.ToSecret()
+		secret.Namespace = "test"
+		secret.Name = newCluster().UserSecretName()
+		delete(secret.Data, deleteKey)
+		return secret
+	}
+
+	testCases := []struct {
+		name    string
+		objects []client.Object
+	}{
+		{
+			name:    "missing-secret",
+			objects: []client.Object{newCluster()},
+		},
+		{
+			name:    "missing-key",
+			objects: []client.Object{newCluster(), newSecret(password.AdminPasswordKey)},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(tc.objects...).
+				WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+				Build()
+			p := &managerProcess{
+				client: c,
+				reader: c,
+				name:   types.NamespacedName{Namespace: "test", Name: "test"},
+			}
+
+			_, err := p.do(context.Background())
+			if !errors.Is(err, errMissingCredentials) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			cluster := &mocov1beta2.MySQLCluster{}
+			if err := c.Get(context.Background(), p.name, cluster); err != nil {
+				t.Fatal(err)
+			}
+			cond := meta.FindStatusCondition(cluster.Status.Conditions, mocov1beta2.ConditionMissingCredentials)
+			if cond == nil {
+				t.Fatal("MissingCredentials condition is not set")
+			}
+			if cond.Status != metav1.ConditionTrue {
+				t.Errorf("unexpected condition status: %s", cond.Status)
+			}
+			if cond.Reason != "CredentialsNotFound" {
+				t.Errorf("unexpected condition reason: %s", cond.Reason)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusInitializing(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newFailedSS := func() *StatusSet {
+		return newSS(3, 0, false, false, false, false).
+			withPod(false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(nil).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			build()
+	}
+	newHealthySS := func() *StatusSet {
+		return newSS(3, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				withReplica(12, "replica2").
+				build()).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			build()
+	}
+
+	ss := newFailedSS()
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(ss.Cluster.DeepCopy()).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+	metrics.Register(prometheus.NewRegistry())
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), nil, nil, nil, types.NamespacedName{Namespace: "ns", Name: "test"}, func() {})
+
+	check := func(ss *StatusSet, expectInitializing metav1.ConditionStatus, expectReason string) {
+		t.Helper()
+		ss.DecideState()
+		if err := p.updateStatus(context.Background(), ss); err != nil {
+			t.Fatal(err)
+		}
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := c.Get(context.Background(), p.name, cluster); err != nil {
+			t.Fatal(err)
+		}
+		cond := meta.FindStatusCondition(cluster.Status.Conditions, mocov1beta2.ConditionInitializing)
+		if cond == nil {
+			t.Fatal("Initializing condition is not set")
+		}
+		if cond.Status != expectInitializing {
+			t.Errorf("unexpected Initializing condition: expected=%s, actual=%s", expectInitializing, cond.Status)
+		}
+		for _, typ := range []string{mocov1beta2.ConditionAvailable, mocov1beta2.ConditionHealthy, mocov1beta2.ConditionReady} {
+			cond := meta.FindStatusCondition(cluster.Status.Conditions, typ)
+			if cond == nil {
+				t.Fatalf("%s condition is not set", typ)
+			}
+			if cond.Reason != expectReason {
+				t.Errorf("unexpected reason of %s condition: expected=%s, actual=%s", typ, expectReason, cond.Reason)
+			}
+		}
+	}
+
+	// a fresh cluster whose primary is not up yet
+	check(newFailedSS(), metav1.ConditionTrue, "Initializing")
+	// the cluster has become available
+	check(newHealthySS(), metav1.ConditionFalse, "Healthy")
+	// failures after the bring-up are reported as they are
+	check(newFailedSS(), metav1.ConditionFalse, "Failed")
+}
+
+func TestUpdateStatusConstraintsRecovered(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newSSWithReplica := func(replica *dbop.MySQLInstanceStatus) *StatusSet {
+		return newSS(2, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(newMySQL("1234", false, false, false).withReplica(11, "replica1").build()).
+			withMySQL(replica).
+			build()
+	}
+	writable := newMySQL("1234", false, false, false).withPrimary(testPrimaryHostname).build()
+	readOnly := newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()
+
+	ss := newSSWithReplica(writable)
+	cluster := ss.Cluster.DeepCopy()
+	meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+		Type:   mocov1beta2.ConditionConstraintsViolated,
+		Status: metav1.ConditionTrue,
+		Reason: reasonWritableReplicas,
+	})
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+	metrics.Register(prometheus.NewRegistry())
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), nil, nil, nil, types.NamespacedName{Namespace: "ns", Name: "test"}, func() {})
+
+	check := func(ss *StatusSet, expect metav1.ConditionStatus) {
+		t.Helper()
+		ss.DecideState()
+		if err := p.updateStatus(context.Background(), ss); err != nil {
+			t.Fatal(err)
+		}
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := c.Get(context.Background(), p.name, cluster); err != nil {
+			t.Fatal(err)
+		}
+		cond := meta.FindStatusCondition(cluster.Status.Conditions, mocov1beta2.ConditionConstraintsViolated)
+		if cond == nil {
+			t.Fatal("ConstraintsViolated condition is removed")
+		}
+		if cond.Status != expect {
+			t.Errorf("unexpected ConstraintsViolated condition: expected=%s, actual=%s", expect, cond.Status)
+		}
+	}
+
+	// the violation is kept while the replica is writable
+	check(ss, metav1.ConditionTrue)
+	// and cleared once the replica becomes read-only
+	check(newSSWithReplica(readOnly), metav1.ConditionFalse)
+}
+
+func TestUpdateStatusWaitTimeout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newDegradedSS := func() *StatusSet {
+		return newSS(3, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(false, false, false).
+			withMySQL(newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				build()).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			withMySQL(nil).
+			build()
+	}
+	newHealthySS := func() *StatusSet {
+		return newSS(3, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				withReplica(12, "replica2").
+				build()).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+			build()
+	}
+
+	cluster := newDegradedSS().Cluster.DeepCopy()
+	cluster.Spec.MaxWaitSeconds = 60
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+	metrics.Register(prometheus.NewRegistry())
+	recorder := record.NewFakeRecorder(10)
+	p := newManagerProcess(c, c, recorder, nil, nil, nil, types.NamespacedName{Namespace: "ns", Name: "test"}, func() {})
+
+	update := func(ss *StatusSet) *mocov1beta2.MySQLCluster {
+		t.Helper()
+		ss.DecideState()
+		if err := p.updateStatus(context.Background(), ss); err != nil {
+			t.Fatal(err)
+		}
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := c.Get(context.Background(), p.name, cluster); err != nil {
+			t.Fatal(err)
+		}
+		return cluster
+	}
+	checkCondition := func(cluster *mocov1beta2.MySQLCluster, status metav1.ConditionStatus, reason string) {
+		t.Helper()
+		cond := meta.FindStatusCondition(cluster.Status.Conditions, mocov1beta2.ConditionWaitTimeout)
+		if cond == nil {
+			t.Fatal("WaitTimeout condition is not set")
+		}
+		if cond.Status != status || cond.Reason != reason {
+			t.Errorf("unexpected WaitTimeout condition: %+v", cond)
+		}
+	}
+	checkEvents := func(expected ...string) {
+		t.Helper()
+		var actual []string
+		for len(recorder.Events) > 0 {
+			actual = append(actual, <-recorder.Events)
+		}
+		if !cmp.Equal(actual, expected) {
+			t.Errorf("unexpected events: %v", actual)
+		}
+	}
+
+	// MOCO starts waiting for the degraded cluster.
+	updated := update(newDegradedSS())
+	if updated.Status.WaitingSince == nil {
+		t.Fatal("waitingSince is not set")
+	}
+	since := updated.Status.WaitingSince.Time
+	checkCondition(updated, metav1.ConditionFalse, "Waiting")
+	checkEvents()
+
+	// the entry time is kept while MOCO keeps waiting.
+	updated = update(newDegradedSS())
+	if !updated.Status.WaitingSince.Time.Equal(since) {
+		t.Errorf("waitingSince was changed: %s -> %s", since, updated.Status.WaitingSince.Time)
+	}
+
+	// MOCO escalates after the max wait.
+	updated.Status.WaitingSince = &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}
+	if err := c.Status().Update(context.Background(), updated); err != nil {
+		t.Fatal(err)
+	}
+	updated = update(newDegradedSS())
+	checkCondition(updated, metav1.ConditionTrue, "MaxWaitExceeded")
+	checkEvents("Warning WaitTimeout The cluster has not converged for more than 60 seconds: WaitingForReplication")
+
+	// the event is recorded only once.
+	updated = update(newDegradedSS())
+	checkCondition(updated, metav1.ConditionTrue, "MaxWaitExceeded")
+	checkEvents()
+
+	// the cluster has converged.
+	updated = update(newHealthySS())
+	if updated.Status.WaitingSince != nil {
+		t.Errorf("waitingSince is not cleared: %s", updated.Status.WaitingSince)
+	}
+	checkCondition(updated, metav1.ConditionFalse, "NotWaiting")
+	checkEvents()
+}
+
+func TestWaitTimeoutCondition(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		maxWait  int32
+		since    *time.Time
+		expected metav1.ConditionStatus
+	}{
+		{name: "not-waiting", maxWait: 60, expected: metav1.ConditionFalse},
+		{name: "no-limit", maxWait: 0, since: ptrTime(now.Add(-time.Hour)), expected: metav1.ConditionFalse},
+		{name: "waiting", maxWait: 60, since: ptrTime(now.Add(-59 * time.Second)), expected: metav1.ConditionFalse},
+		{name: "exceeded", maxWait: 60, since: ptrTime(now.Add(-60 * time.Second)), expected: metav1.ConditionTrue},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &mocov1beta2.MySQLCluster{}
+			cluster.Spec.MaxWaitSeconds = tc.maxWait
+			cluster.Status.RequeueReason = "WaitingForReplication"
+			if tc.since != nil {
+				cluster.Status.WaitingSince = &metav1.Time{Time: *tc.since}
+			}
+			cond := waitTimeoutCondition(cluster, now)
+			if cond.Status != tc.expected {
+				t.Errorf("unexpected status: expected=%s, actual=%s", tc.expected, cond.Status)
+			}
+		})
+	}
+}
+
+func TestUpdateStatusPrimaryIndexMetrics(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ss := newSS(3, 1, false, false, false, false).
+		withPod(true, false, false).
+		withPod(true, false, false).
+		withPod(true, false, false).
+		withMySQL(newMySQL("1234", true, false, false).withPrimary("moco-test-1.moco-test.ns.svc").build()).
+		withMySQL(newMySQL("1234", false, false, false).
+			withReplica(10, "replica0").
+			withReplica(12, "replica2").
+			build()).
+		withMySQL(newMySQL("1234", true, false, false).withPrimary("moco-test-1.moco-test.ns.svc").build()).
+		build()
+	ss.DecideState()
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(ss.Cluster.DeepCopy()).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+	metrics.Register(prometheus.NewRegistry())
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), nil, nil, nil, types.NamespacedName{Namespace: "ns", Name: "test"}, func() {})
+
+	if err := p.updateStatus(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	if v := testutil.ToFloat64(p.metrics.primaryIndex); v != 1 {
+		t.Errorf("unexpected primary index: %v", v)
+	}
+
+	p.deleteMetrics()
+	if n := testutil.CollectAndCount(metrics.PrimaryIndexVec); n != 0 {
+		t.Errorf("the metric for the deleted cluster remains: %d", n)
+	}
+}
+
+func TestInitializingCondition(t *testing.T) {
+	testCases := []struct {
+		name      string
+		conds     []metav1.Condition
+		available metav1.ConditionStatus
+		expected  metav1.ConditionStatus
+	}{
+		{
+			name:      "fresh",
+			available: metav1.ConditionFalse,
+			expected:  metav1.ConditionTrue,
+		},
+		{
+			name:      "fresh-available",
+			available: metav1.ConditionTrue,
+			expected:  metav1.ConditionFalse,
+		},
+		{
+			name:      "bringing-up",
+			conds:     []metav1.Condition{{Type: mocov1beta2.ConditionInitializing, Status: metav1.ConditionTrue}, {Type: mocov1beta2.ConditionAvailable, Status: metav1.ConditionFalse}},
+			available: metav1.ConditionFalse,
+			expected:  metav1.ConditionTrue,
+		},
+		{
+			name:      "brought-up",
+			conds:     []metav1.Condition{{Type: mocov1beta2.ConditionInitializing, Status: metav1.ConditionFalse}},
+			available: metav1.ConditionFalse,
+			expected:  metav1.ConditionFalse,
+		},
+		{
+			name:      "existing-cluster",
+			conds:     []metav1.Condition{{Type: mocov1beta2.ConditionAvailable, Status: metav1.ConditionFalse}},
+			available: metav1.ConditionFalse,
+			expected:  metav1.ConditionFalse,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cond := initializingCondition(tc.conds, tc.available)
+			if cond.Type != mocov1beta2.ConditionInitializing {
+				t.Errorf("unexpected condition type: %s", cond.Type)
+			}
+			if cond.Status != tc.expected {
+				t.Errorf("unexpected condition status: expected=%s, actual=%s", tc.expected, cond.Status)
+			}
+		})
+	}
+}
+
+func TestDeferSwitchover(t *testing.T) {
+	// 09:00-17:00 on weekdays
+	windows := []mocov1beta2.BlackoutWindow{{Schedule: "0 9 * * 1-5", DurationSeconds: 8 * 3600}}
+	inside := time.Date(2023, 4, 3, 10, 0, 0, 0, time.Local)  // Monday 10:00
+	outside := time.Date(2023, 4, 3, 18, 0, 0, 0, time.Local) // Monday 18:00
+	weekend := time.Date(2023, 4, 2, 10, 0, 0, 0, time.Local) // Sunday 10:00
+
+	testCases := []struct {
+		name     string
+		deleting bool
+		windows  []mocov1beta2.BlackoutWindow
+		now      time.Time
+		expected bool
+	}{
+		{name: "no-window", now: inside, expected: false},
+		{name: "demote-inside-window", windows: windows, now: inside, expected: true},
+		{name: "demote-outside-window", windows: windows, now: outside, expected: false},
+		{name: "demote-on-weekend", windows: windows, now: weekend, expected: false},
+		{name: "terminating-inside-window", deleting: true, windows: windows, now: inside, expected: false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := newSS(3, 0, false, false, false, false).
+				withPod(true, tc.deleting, !tc.deleting).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("1234", false, false, false).
+					withReplica(11, "replica1").
+					withReplica(12, "replica2").
+					build()).
+				withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+				withMySQL(newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()).
+				build()
+			ss.Cluster.Spec.SwitchoverBlackoutWindows = tc.windows
+			ss.DecideState()
+			if !ss.NeedSwitch {
+				t.Fatal("switchover should be needed")
+			}
+
+			if actual := deferSwitchover(ss, tc.now); actual != tc.expected {
+				t.Errorf("unexpected result: expected=%v, actual=%v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRecordTransitions(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	p := &managerProcess{recorder: recorder}
+
+	steps := []struct {
+		primary  int
+		state    ClusterState
+		expected []string
+	}{
+		{primary: 0, state: StateHealthy},
+		{primary: 0, state: StateFailed},
+		{primary: 1, state: StateDegraded, expected: []string{"Normal PrimaryChanged The primary was changed from instance 0 to 1"}},
+		{primary: 1, state: StateLost, expected: []string{"Warning ClusterLost No instance is available to become the primary"}},
+		{primary: 1, state: StateLost},
+		{primary: 2, state: StateHealthy, expected: []string{"Normal PrimaryChanged The primary was changed from instance 1 to 2"}},
+	}
+	for i, step := range steps {
+		p.recordTransitions(&StatusSet{Cluster: &mocov1beta2.MySQLCluster{}, Primary: step.primary, State: step.state})
+
+		var actual []string
+		for len(recorder.Events) > 0 {
+			actual = append(actual, <-recorder.Events)
+		}
+		if !cmp.Equal(actual, step.expected) {
+			t.Errorf("step %d: unexpected events: %v", i, actual)
+		}
+	}
+
+	// a check scheduled for the previous state is canceled.
+	p.wakeUp(time.Hour, "test")
+	timer := p.wakeUpTimer
+	p.recordTransitions(&StatusSet{Cluster: &mocov1beta2.MySQLCluster{}, Primary: 2, State: StateHealthy})
+	if p.wakeUpTimer != timer {
+		t.Error("the check should be kept while the state is the same")
+	}
+	p.recordTransitions(&StatusSet{Cluster: &mocov1beta2.MySQLCluster{}, Primary: 2, State: StateFailed})
+	if p.wakeUpTimer != nil || timer.Stop() {
+		t.Error("the check should be canceled when the state changes")
+	}
+
+	// a process without a recorder should not panic.
+	p = &managerProcess{}
+	p.recordTransitions(&StatusSet{Cluster: &mocov1beta2.MySQLCluster{}, Primary: 0, State: StateHealthy})
+	p.recordTransitions(&StatusSet{Cluster: &mocov1beta2.MySQLCluster{}, Primary: 1, State: StateLost})
+}
+
+func TestSemiSyncStatus(t *testing.T) {
+	testCases := []struct {
+		name          string
+		status        *dbop.MySQLInstanceStatus
+		expectClients int
+		expectWaitFor int
+	}{
+		{
+			name:   "unavailable",
+			status: nil,
+		},
+		{
+			name: "disabled",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables: dbop.GlobalVariables{WaitForSlaveCount: 1},
+			},
+		},
+		{
+			name: "enough-clients",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables:       dbop.GlobalVariables{SemiSyncMasterEnabled: true, WaitForSlaveCount: 1},
+				SemiSyncMasterClients: 2,
+			},
+			expectClients: 2,
+			expectWaitFor: 1,
+		},
+		{
+			name: "insufficient-clients",
+			status: &dbop.MySQLInstanceStatus{
+				GlobalVariables:       dbop.GlobalVariables{SemiSyncMasterEnabled: true, WaitForSlaveCount: 2},
+				SemiSyncMasterClients: 1,
+			},
+			expectClients: 1,
+			expectWaitFor: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := &StatusSet{Primary: 0, MySQLStatus: []*dbop.MySQLInstanceStatus{tc.status, nil, nil}}
+			clients, waitFor := semiSyncStatus(ss)
+			if clients != tc.expectClients {
+				t.Errorf("unexpected clients: expected=%d, actual=%d", tc.expectClients, clients)
+			}
+			if waitFor != tc.expectWaitFor {
+				t.Errorf("unexpected wait for count: expected=%d, actual=%d", tc.expectWaitFor, waitFor)
+			}
+		})
+	}
+}
+
+func TestSemiSyncAcknowledgers(t *testing.T) {
+	newReplica := func(active bool) *dbop.MySQLInstanceStatus {
+		st := newMySQL("1234", true, false, false).withPrimary(testPrimaryHostname).build()
+		st.SemiSyncSlaveActive = active
+		return st
+	}
+	newTestSS := func() *StatusSet {
+		ss := newSS(5, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(false, false, false).
+			withMySQL(newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				withReplica(12, "replica2").
+				build()).
+			// connected and active
+			withMySQL(newReplica(true)).
+			// connected but the semi-sync replica is not active
+			withMySQL(newReplica(false)).
+			// active but not connected to the primary
+			withMySQL(newReplica(true)).
+			// unavailable
+			withMySQL(nil).
+			build()
+		ss.MySQLStatus[0].GlobalVariables.SemiSyncMasterEnabled = true
+		return ss
+	}
+
+	ss := newTestSS()
+	if actual := semiSyncAcknowledgers(ss); !cmp.Equal(actual, []int{1}) {
+		t.Errorf("unexpected acknowledgers: %v", actual)
+	}
+
+	ss = newTestSS()
+	ss.MySQLStatus[2].SemiSyncSlaveActive = true
+	if actual := semiSyncAcknowledgers(ss); !cmp.Equal(actual, []int{1, 2}) {
+		t.Errorf("unexpected acknowledgers: %v", actual)
+	}
+
+	// a replica connected to another source does not acknowledge the primary.
+	ss = newTestSS()
+	ss.MySQLStatus[1].ReplicaStatus.MasterHost = "moco-test-3.moco-test.ns.svc"
+	if actual := semiSyncAcknowledgers(ss); len(actual) != 0 {
+		t.Errorf("unexpected acknowledgers: %v", actual)
+	}
+
+	ss = newTestSS()
+	ss.MySQLStatus[0].GlobalVariables.SemiSyncMasterEnabled = false
+	if actual := semiSyncAcknowledgers(ss); actual != nil {
+		t.Errorf("no replica should acknowledge a primary without semi-sync: %v", actual)
+	}
+
+	ss = newTestSS()
+	ss.MySQLStatus[0] = nil
+	if actual := semiSyncAcknowledgers(ss); actual != nil {
+		t.Errorf("acknowledgers of an unavailable primary should be unknown: %v", actual)
+	}
+}
+
+func TestDoObservesDurations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "test"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 1
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := passwd.ToSecret()
+	secret.Namespace = "test"
+	secret.Name = cluster.UserSecretName()
+
+	pod := &corev1.Pod{}
+	pod.Namespace = "test"
+	pod.Name = cluster.PodName(0)
+	pod.Labels = map[string]string{
+		constants.LabelAppName:     constants.AppNameMySQL,
+		constants.LabelAppInstance: cluster.Name,
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster, secret, pod).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+
+	// a writable single instance is healthy, so no operation is needed.
+	of := newMockOpFactory()
+	m := &mockMySQL{}
+	m.status.GlobalVariables.UUID = "p0"
+	m.status.GlobalVariables.LogBin = true
+	of.mysqls[cluster.PodHostname(0)] = m
+
+	registry := prometheus.NewRegistry()
+	metrics.Register(registry)
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), of, nil, nil, types.NamespacedName{Namespace: "test", Name: "test"}, func() {})
+
+	redo, err := p.do(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if redo {
+		t.Error("redo should be false")
+	}
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	observed := make(map[string]uint64)
+	for _, mf := range mfs {
+		for _, m := range mf.Metric {
+			observed[mf.GetName()] += m.GetHistogram().GetSampleCount()
+		}
+	}
+	for _, name := range []string{"moco_cluster_status_gather_duration_seconds", "moco_cluster_decision_duration_seconds"} {
+		if observed[name] != 1 {
+			t.Errorf("%s is observed %d times", name, observed[name])
+		}
+	}
+}
+
+func TestDoReportsPrimaryGTIDPurged(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "test"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 1
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := passwd.ToSecret()
+	secret.Namespace = "test"
+	secret.Name = cluster.UserSecretName()
+
+	pod := &corev1.Pod{}
+	pod.Namespace = "test"
+	pod.Name = cluster.PodName(0)
+	pod.Labels = map[string]string{
+		constants.LabelAppName:     constants.AppNameMySQL,
+		constants.LabelAppInstance: cluster.Name,
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster, secret, pod).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+
+	of := newMockOpFactory()
+	m := &mockMySQL{}
+	m.status.GlobalVariables.UUID = "p0"
+	m.status.GlobalVariables.LogBin = true
+	m.status.GlobalVariables.PurgedGTID = "p0:1-5"
+	of.mysqls[cluster.PodHostname(0)] = m
+
+	metrics.Register(prometheus.NewRegistry())
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), of, nil, nil, types.NamespacedName{Namespace: "test", Name: "test"}, func() {})
+	if _, err := p.do(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated := &mocov1beta2.MySQLCluster{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(cluster), updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.PrimaryGTIDPurged != "p0:1-5" {
+		t.Errorf("unexpected primaryGTIDPurged: %q", updated.Status.PrimaryGTIDPurged)
+	}
+}
+
+func TestPrimaryGTIDPurged(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   *dbop.MySQLInstanceStatus
+		last     string
+		expected string
+	}{
+		{
+			name:     "observed",
+			status:   &dbop.MySQLInstanceStatus{GlobalVariables: dbop.GlobalVariables{PurgedGTID: "p0:1-10"}},
+			last:     "p0:1-5",
+			expected: "p0:1-10",
+		},
+		{
+			name:   "nothing-purged",
+			status: &dbop.MySQLInstanceStatus{},
+			last:   "p0:1-5",
+		},
+		{
+			name:     "unavailable",
+			last:     "p0:1-5",
+			expected: "p0:1-5",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := &StatusSet{Primary: 0, MySQLStatus: []*dbop.MySQLInstanceStatus{tc.status, nil, nil}}
+			actual := primaryGTIDPurged(ss, tc.last)
+			if actual != tc.expected {
+				t.Errorf("unexpected result: expected=%q, actual=%q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDoTerminatingPrimary(t *testing.T) {
+	origInterval := statusCheckRetryInterval
+	statusCheckRetryInterval = 0
+	defer func() { statusCheckRetryInterval = origInterval }()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "restart"
+	cluster.Name = "restart"
+	cluster.Spec.Replicas = 3
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := passwd.ToSecret()
+	secret.Namespace = cluster.Namespace
+	secret.Name = cluster.UserSecretName()
+
+	objs := []client.Object{cluster, secret}
+	for i := 0; i < 3; i++ {
+		pod := &corev1.Pod{}
+		pod.Namespace = cluster.Namespace
+		pod.Name = cluster.PodName(i)
+		pod.Labels = map[string]string{
+			constants.LabelAppName:     constants.AppNameMySQL,
+			constants.LabelAppInstance: cluster.Name,
+		}
+		if i == 0 {
+			// the primary Pod is being deleted gracefully.
+			pod.Finalizers = []string{"test"}
+			pod.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(30 * time.Second)}
+		} else {
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		}
+		objs = append(objs, pod)
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+
+	resetGTIDMap()
+	of := newMockOpFactory()
+	of.failing[cluster.PodHostname(0)] = true
+	for i := 1; i < 3; i++ {
+		m := &mockMySQL{}
+		m.status.GlobalVariables.UUID = fmt.Sprintf("p%d", i)
+		m.status.GlobalVariables.ReadOnly = true
+		m.status.GlobalVariables.SuperReadOnly = true
+		m.status.GlobalVariables.LogBin = true
+		m.status.ReplicaStatus = &dbop.ReplicaStatus{
+			MasterHost:      cluster.PodHostname(0),
+			SlaveIORunning:  "Yes",
+			SlaveSQLRunning: "Yes",
+		}
+		of.mysqls[cluster.PodHostname(i)] = m
+		testSetGTID(cluster.PodHostname(i), "p0:1-10")
+	}
+
+	registry := prometheus.NewRegistry()
+	metrics.Register(registry)
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), of, nil, nil, types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}, func() {})
+
+	redo, err := p.do(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if redo {
+		t.Error("redo should be false")
+	}
+
+	for i := 1; i < 3; i++ {
+		if rs := of.mysqls[cluster.PodHostname(i)].getStatus().ReplicaStatus; rs.SlaveIORunning != "Yes" {
+			t.Errorf("replication of instance %d was stopped for failover", i)
+		}
+	}
+
+	updated := &mocov1beta2.MySQLCluster{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(cluster), updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.CurrentPrimaryIndex != 0 {
+		t.Errorf("primary was changed to %d", updated.Status.CurrentPrimaryIndex)
+	}
+	if updated.Status.RequeueReason != "WaitingForPrimaryRestart" {
+		t.Errorf("unexpected requeue reason: %q", updated.Status.RequeueReason)
+	}
+}
+
+func TestDoCorrectsWaitForCountDrift(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "drift"
+	cluster.Name = "drift"
+	cluster.Spec.Replicas = 3
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := passwd.ToSecret()
+	secret.Namespace = cluster.Namespace
+	secret.Name = cluster.UserSecretName()
+
+	objs := []client.Object{cluster, secret}
+	for i := 0; i < 3; i++ {
+		pod := &corev1.Pod{}
+		pod.Namespace = cluster.Namespace
+		pod.Name = cluster.PodName(i)
+		pod.Labels = map[string]string{
+			constants.LabelAppName:     constants.AppNameMySQL,
+			constants.LabelAppInstance: cluster.Name,
+		}
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		objs = append(objs, pod)
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+
+	resetGTIDMap()
+	of := newMockOpFactory()
+	primary := &mockMySQL{}
+	primary.status.GlobalVariables.UUID = "p0"
+	primary.status.GlobalVariables.LogBin = true
+	primary.status.GlobalVariables.SemiSyncMasterEnabled = true
+	// the expected value is 1 for a 3-instance cluster
+	primary.status.GlobalVariables.WaitForSlaveCount = 2
+	primary.status.ReplicaHosts = []dbop.ReplicaHost{{ServerID: 1}, {ServerID: 2}}
+	of.mysqls[cluster.PodHostname(0)] = primary
+	testSetGTID(cluster.PodHostname(0), "p0:1-10")
+	for i := 1; i < 3; i++ {
+		m := &mockMySQL{}
+		m.status.GlobalVariables.UUID = fmt.Sprintf("p%d", i)
+		m.status.GlobalVariables.ReadOnly = true
+		m.status.GlobalVariables.SuperReadOnly = true
+		m.status.GlobalVariables.LogBin = true
+		m.status.GlobalVariables.SemiSyncSlaveEnabled = true
+		m.status.ReplicaStatus = &dbop.ReplicaStatus{
+			MasterHost:      cluster.PodHostname(0),
+			SlaveIORunning:  "Yes",
+			SlaveSQLRunning: "Yes",
+		}
+		of.mysqls[cluster.PodHostname(i)] = m
+		testSetGTID(cluster.PodHostname(i), "p0:1-10")
+	}
+
+	metrics.Register(prometheus.NewRegistry())
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), of, nil, nil, types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}, func() {})
+
+	redo, err := p.do(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !redo {
+		t.Error("redo should be true after the correction")
+	}
+	if n := primary.getStatus().GlobalVariables.WaitForSlaveCount; n != 1 {
+		t.Errorf("wait for count was not corrected: %d", n)
+	}
+
+	updated := &mocov1beta2.MySQLCluster{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(cluster), updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.CurrentPrimaryIndex != 0 {
+		t.Errorf("primary was changed to %d", updated.Status.CurrentPrimaryIndex)
+	}
+	if !meta.IsStatusConditionTrue(updated.Status.Conditions, mocov1beta2.ConditionHealthy) {
+		t.Error("the cluster should be healthy")
+	}
+
+	// once corrected, nothing needs to be done.
+	redo, err = p.do(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if redo {
+		t.Error("redo should be false")
+	}
+}
+
+func TestCountOperationErrors(t *testing.T) {
+	metrics.Register(prometheus.NewRegistry())
+	name := types.NamespacedName{Namespace: "operr", Name: "operr"}
+	p := newManagerProcess(nil, nil, record.NewFakeRecorder(10), nil, nil, nil, name, func() {})
+
+	errFailed := errors.New("failed")
+	if err := countError(p.metrics.configureErrors, errFailed); err != errFailed {
+		t.Errorf("the error should be returned as is: %v", err)
+	}
+	if err := countError(p.metrics.configureErrors, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	countError(p.metrics.failoverErrors, errFailed)
+
+	expected := map[string]float64{"clone": 0, "switchover": 0, "failover": 1, "configure": 1}
+	for op, v := range expected {
+		if actual := testutil.ToFloat64(metrics.OperationErrorsVec.WithLabelValues(name.Name, name.Namespace, op)); actual != v {
+			t.Errorf("unexpected %s errors: expected=%v, actual=%v", op, v, actual)
+		}
+	}
+
+	p.deleteMetrics()
+	if n := testutil.CollectAndCount(metrics.OperationErrorsVec); n != 0 {
+		t.Errorf("metrics of the deleted cluster remain: %d", n)
+	}
+}
+
+func TestPrimaryRestartWait(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name      string
+		deletion  *time.Time
+		reachable bool
+		expected  time.Duration
+	}{
+		{name: "not-deleted", expected: 0},
+		{name: "terminating", deletion: ptrTime(now.Add(30 * time.Second)), expected: 30 * time.Second},
+		{name: "grace-period-expired", deletion: ptrTime(now.Add(-time.Second)), expected: 0},
+		{name: "reachable", deletion: ptrTime(now.Add(30 * time.Second)), reachable: true, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &corev1.Pod{}
+			if tc.deletion != nil {
+				pod.DeletionTimestamp = &metav1.Time{Time: *tc.deletion}
+			}
+			ss := &StatusSet{Primary: 0, Pods: []*corev1.Pod{pod}, MySQLStatus: []*dbop.MySQLInstanceStatus{nil}}
+			if tc.reachable {
+				ss.MySQLStatus[0] = &dbop.MySQLInstanceStatus{}
+			}
+			if actual := primaryRestartWait(ss, now); actual != tc.expected {
+				t.Errorf("unexpected wait: expected=%v, actual=%v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func TestLastReconcileTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	last := metav1.NewTime(now)
+
+	if got := lastReconcileTime(nil, time.Time{}); got != nil {
+		t.Errorf("unexpected time before any success: %v", got)
+	}
+	if got := lastReconcileTime(&last, time.Time{}); got != &last {
+		t.Errorf("the last time should be kept before any success: %v", got)
+	}
+	if got := lastReconcileTime(nil, now); got == nil || !got.Time.Equal(now) {
+		t.Errorf("unexpected time for the first success: %v", got)
+	}
+	if got := lastReconcileTime(&last, now.Add(time.Minute)); got != &last {
+		t.Errorf("the last time should be kept within the resolution: %v", got)
+	}
+	later := now.Add(lastReconcileResolution)
+	if got := lastReconcileTime(&last, later); got == nil || !got.Time.Equal(later) {
+		t.Errorf("the time should be updated after the resolution: %v", got)
+	}
+}
+
+func TestDoRejectsForceFailover(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "test"
+	cluster.Name = "test"
+	cluster.Annotations = map[string]string{constants.AnnForceFailover: "true"}
+	cluster.Spec.Replicas = 1
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := passwd.ToSecret()
+	secret.Namespace = "test"
+	secret.Name = cluster.UserSecretName()
+
+	pod := &corev1.Pod{}
+	pod.Namespace = "test"
+	pod.Name = cluster.PodName(0)
+	pod.Labels = map[string]string{
+		constants.LabelAppName:     constants.AppNameMySQL,
+		constants.LabelAppInstance: cluster.Name,
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster, secret, pod).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+
+	// the primary is healthy, so the request should be rejected.
+	of := newMockOpFactory()
+	m := &mockMySQL{}
+	m.status.GlobalVariables.UUID = "p0"
+	m.status.GlobalVariables.LogBin = true
+	of.mysqls[cluster.PodHostname(0)] = m
+
+	metrics.Register(prometheus.NewRegistry())
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), of, nil, nil, types.NamespacedName{Namespace: "test", Name: "test"}, func() {})
+	if _, err := p.do(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updated := &mocov1beta2.MySQLCluster{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(cluster), updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := updated.Annotations[constants.AnnForceFailover]; ok {
+		t.Error("the rejected annotation was not removed")
+	}
+}
+
+func TestUpdateDeferredOperations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+	p := &managerProcess{client: c, reader: c, name: types.NamespacedName{Namespace: "ns", Name: "test"}}
+
+	get := func() *mocov1beta2.MySQLCluster {
+		t.Helper()
+		cluster := &mocov1beta2.MySQLCluster{}
+		if err := c.Get(context.Background(), p.name, cluster); err != nil {
+			t.Fatal(err)
+		}
+		return cluster
+	}
+
+	// operations deferred by the re-clone interval are reported.
+	ss := &StatusSet{Cluster: get()}
+	ss.deferOperation("ReClone/1")
+	if err := p.updateDeferredOperations(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	updated := get()
+	if !slices.Equal(updated.Status.DeferredOperations, []string{"ReClone/1"}) {
+		t.Errorf("unexpected deferred operations: %v", updated.Status.DeferredOperations)
+	}
+
+	// the status is not updated while the list is unchanged.
+	ss = &StatusSet{Cluster: updated}
+	ss.deferOperation("ReClone/1")
+	if err := p.updateDeferredOperations(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	if rv := get().ResourceVersion; rv != updated.ResourceVersion {
+		t.Errorf("the status should not be updated: %s -> %s", updated.ResourceVersion, rv)
+	}
+
+	// the list is cleared when nothing is deferred.
+	ss = &StatusSet{Cluster: get()}
+	if err := p.updateDeferredOperations(context.Background(), ss); err != nil {
+		t.Fatal(err)
+	}
+	if ops := get().Status.DeferredOperations; len(ops) != 0 {
+		t.Errorf("the deferred operations should be cleared: %v", ops)
+	}
+}
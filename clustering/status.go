@@ -2,6 +2,7 @@ package clustering
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"slices"
@@ -16,6 +17,7 @@ import (
 	"github.com/cybozu-go/moco/pkg/dbop"
 	"github.com/cybozu-go/moco/pkg/password"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -92,6 +94,35 @@ type StatusSet struct {
 	NeedSwitch bool
 	Candidate  int
 	State      ClusterState
+
+	// Excluded is the list of instances that must not become the primary.
+	// See primaryExclusions.
+	Excluded []int
+
+	// PlannedSwitchover is true if the switchover is requested by
+	// `moco.cybozu.com/switchover` annotation of the MySQLCluster.
+	PlannedSwitchover bool
+
+	// PrimaryRestartWait is the remaining grace period of the terminating primary Pod
+	// whose `mysqld` cannot be reached.  See primaryRestartWait.
+	PrimaryRestartWait time.Duration
+
+	// Deferred is the list of operations deferred in this check.
+	// See `status.deferredOperations` of MySQLCluster.
+	Deferred []string
+}
+
+// Operations reported in `status.deferredOperations` of MySQLCluster.
+const (
+	operationSwitchover  = "Switchover"
+	operationFailover    = "Failover"
+	operationSetWritable = "SetWritable"
+	operationReClone     = "ReClone"
+)
+
+// deferOperation records that `op` is deferred in this check.
+func (ss *StatusSet) deferOperation(op string) {
+	ss.Deferred = append(ss.Deferred, op)
 }
 
 // Close closes `ss.DBOps`.
@@ -122,16 +153,68 @@ func (ss *StatusSet) DecideState() {
 	default:
 		ss.State = StateIncomplete
 	}
+	// an invalid annotation is ignored here, and reported by the manager process.
+	if excluded, err := primaryExclusions(ss.Cluster); err == nil {
+		ss.Excluded = excluded
+	}
+	// an instance without binary logging cannot be a primary, and
+	// an instance in the crash recovery should not be switched to.
+	ss.Candidates = slices.DeleteFunc(ss.Candidates, func(i int) bool {
+		return !ss.MySQLStatus[i].GlobalVariables.LogBin || slices.Contains(ss.Excluded, i) || isRecovering(ss.MySQLStatus[i])
+	})
 	if len(ss.Candidates) > 0 {
-		ss.NeedSwitch = needSwitch(ss.Pods[ss.Primary])
-		// Choose the lowest ordinal for a switchover target.
+		ss.NeedSwitch = needSwitch(ss.Pods[ss.Primary]) || slices.Contains(ss.Excluded, ss.Primary)
+		// Choose the lowest ordinal for a switchover target,
+		// preferring replicas that can take over the connections of the primary.
 		sort.Ints(ss.Candidates)
-		ss.Candidate = ss.Candidates[0]
+		ss.Candidate = preferConnectionHeadroom(ss, ss.Candidates)[0]
+		if target, ok := switchoverTarget(ss); ok {
+			ss.NeedSwitch = true
+			ss.Candidate = target
+			ss.PlannedSwitchover = true
+		}
+	}
+}
+
+// minConnectionHeadroomPercent is the percentage of `max_connections` that should
+// be kept free on a new primary after it takes over the connections of the primary.
+const minConnectionHeadroomPercent = 10
+
+// hasConnectionHeadroom returns true if instance `index` can accept the connections
+// of the current primary while keeping minConnectionHeadroomPercent of its
+// `max_connections` free.  The connections of an unreachable primary are not counted.
+// If the status of the instance is not available, this returns true.
+func hasConnectionHeadroom(ss *StatusSet, index int) bool {
+	ist := ss.MySQLStatus[index]
+	if ist == nil || ist.GlobalVariables.MaxConnections == 0 {
+		return true
+	}
+
+	var load int
+	if pst := ss.MySQLStatus[ss.Primary]; pst != nil && index != ss.Primary {
+		load = pst.ThreadsConnected
 	}
+	maxConns := ist.GlobalVariables.MaxConnections
+	return maxConns-ist.ThreadsConnected-load >= maxConns*minConnectionHeadroomPercent/100
+}
+
+// preferConnectionHeadroom returns the instances in `indices` that have connection headroom.
+// If none of them has, this returns `indices` as is.
+func preferConnectionHeadroom(ss *StatusSet, indices []int) []int {
+	var preferred []int
+	for _, i := range indices {
+		if hasConnectionHeadroom(ss, i) {
+			preferred = append(preferred, i)
+		}
+	}
+	if len(preferred) == 0 {
+		return indices
+	}
+	return preferred
 }
 
 // GatherStatus collects information and Kubernetes resources and construct
-// StatusSet.  It calls `StatusSet.DecideState` before returning.
+// StatusSet.  The caller should call `StatusSet.DecideState` to decide the state.
 func (p *managerProcess) GatherStatus(ctx context.Context) (*StatusSet, error) {
 	ss := &StatusSet{}
 
@@ -143,12 +226,17 @@ func (p *managerProcess) GatherStatus(ctx context.Context) (*StatusSet, error) {
 	ss.Primary = cluster.Status.CurrentPrimaryIndex
 
 	passwdSecret := &corev1.Secret{}
-	if err := p.client.Get(ctx, client.ObjectKey{Namespace: p.name.Namespace, Name: cluster.UserSecretName()}, passwdSecret); err != nil {
+	err := p.client.Get(ctx, client.ObjectKey{Namespace: p.name.Namespace, Name: cluster.UserSecretName()}, passwdSecret)
+	if apierrors.IsNotFound(err) {
+		return nil, newClusterError(errMissingCredentials, reasonCredentialsNotFound,
+			fmt.Errorf("secret %s/%s is not found", p.name.Namespace, cluster.UserSecretName()))
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to get password secret: %w", err)
 	}
 	passwd, err := password.NewMySQLPasswordFromSecret(passwdSecret)
 	if err != nil {
-		return nil, err
+		return nil, newClusterError(errMissingCredentials, reasonCredentialsNotFound, err)
 	}
 	ss.Password = passwd
 
@@ -177,9 +265,13 @@ func (p *managerProcess) GatherStatus(ctx context.Context) (*StatusSet, error) {
 		ss.Pods[index] = &pods.Items[i]
 	}
 
+	// drop the connection pools to the instances removed by scaling in.
+	p.dbf.RemoveCluster(cluster.Namespace, cluster.Name, int(cluster.Spec.Replicas))
+
 	ss.DBOps = make([]dbop.Operator, cluster.Spec.Replicas)
+	var succeeded bool
 	defer func() {
-		if ss.State == StateUndecided {
+		if !succeeded {
 			ss.Close()
 		}
 	}()
@@ -200,10 +292,13 @@ func (p *managerProcess) GatherStatus(ctx context.Context) (*StatusSet, error) {
 
 			for j := 0; j <= statusCheckRetryMax; j++ {
 				ist, err := ss.DBOps[index].GetStatus(ctx)
-				if err == dbop.ErrNop {
+				if errors.Is(err, dbop.ErrNop) {
 					return
 				}
 				if err == nil {
+					if index != ss.Primary {
+						checkReplicaHealth(ctx, ss.DBOps[index], ist, index, cluster.Spec.ReplicaHealthCheckSQL)
+					}
 					ss.MySQLStatus[index] = ist
 					return
 				}
@@ -261,10 +356,26 @@ func (p *managerProcess) GatherStatus(ctx context.Context) (*StatusSet, error) {
 		}
 	}
 
-	ss.DecideState()
+	succeeded = true
 	return ss, nil
 }
 
+// checkReplicaHealth runs `spec.replicaHealthCheckSQL` given as `query` on replica `index`
+// and marks the status `ist` if the check fails.  This is called from the goroutine gathering
+// the status of the instance so that slow checks of replicas do not add up.
+func checkReplicaHealth(ctx context.Context, op dbop.Operator, ist *dbop.MySQLInstanceStatus, index int, query string) {
+	if query == "" {
+		return
+	}
+	ok, err := op.CheckHealth(ctx, query)
+	if err != nil {
+		logFromContext(ctx).Error(err, "failed to run the custom health check", "instance", index)
+	}
+	if !ok {
+		ist.CustomHealthCheckFailed = true
+	}
+}
+
 // containErrantTransactions check whether a GTID set contains errant transactions.
 // When the primary load is high, in the rare case, gtid_executed of replicas precedes the primary.
 // Assuming such a situation, this function ignores primary's event.
@@ -366,7 +477,7 @@ func isHealthy(ss *StatusSet) bool {
 		if ist.IsErrant {
 			return false
 		}
-		if !ist.GlobalVariables.SuperReadOnly {
+		if !isReadOnly(ist) {
 			return false
 		}
 		if ist.ReplicaStatus == nil {
@@ -375,6 +486,9 @@ func isHealthy(ss *StatusSet) bool {
 		if ist.ReplicaStatus.MasterHost != primaryHostname {
 			return false
 		}
+		if ist.CustomHealthCheckFailed {
+			return false
+		}
 		ss.Candidates = append(ss.Candidates, i)
 	}
 
@@ -390,7 +504,7 @@ func isHealthy(ss *StatusSet) bool {
 			return false
 		}
 	} else {
-		if pst.GlobalVariables.ReadOnly {
+		if !isWritable(pst) {
 			return false
 		}
 	}
@@ -422,6 +536,11 @@ func isCloning(ss *StatusSet) bool {
 	return false
 }
 
+// isCloneInProgress returns true if the instance is receiving data by a clone operation.
+func isCloneInProgress(st *dbop.MySQLInstanceStatus) bool {
+	return st.CloneStatus != nil && st.CloneStatus.State.String == "In Progress"
+}
+
 func isRestoring(ss *StatusSet) bool {
 	if ss.Cluster.Spec.Restore == nil {
 		return false
@@ -450,7 +569,7 @@ func isDegraded(ss *StatusSet) bool {
 			return false
 		}
 	} else {
-		if pst.GlobalVariables.ReadOnly {
+		if !isWritable(pst) {
 			return false
 		}
 	}
@@ -470,7 +589,7 @@ func isDegraded(ss *StatusSet) bool {
 		if !isPodReady(ss.Pods[i]) {
 			continue
 		}
-		if !ist.GlobalVariables.SuperReadOnly {
+		if !isReadOnly(ist) {
 			continue
 		}
 		if ist.ReplicaStatus == nil {
@@ -482,6 +601,9 @@ func isDegraded(ss *StatusSet) bool {
 		if ist.IsErrant {
 			continue
 		}
+		if ist.CustomHealthCheckFailed {
+			continue
+		}
 		okReplicas++
 		ss.Candidates = append(ss.Candidates, i)
 	}
@@ -547,6 +669,79 @@ func isLost(ss *StatusSet) bool {
 	return okReplicas <= (int(ss.Cluster.Spec.Replicas) / 2)
 }
 
+// primaryRestartWait returns the remaining grace period of the primary Pod if
+// the primary cannot be reached because the Pod is being deleted deliberately,
+// e.g. during a rolling update.  MOCO should wait for the Pod to restart rather
+// than failing over during this period.
+//
+// After the grace period, the Pod may be stuck on an unreachable Node,
+// so zero is returned to allow failover.
+func primaryRestartWait(ss *StatusSet, now time.Time) time.Duration {
+	if ss.MySQLStatus[ss.Primary] != nil {
+		return 0
+	}
+	pod := ss.Pods[ss.Primary]
+	if pod == nil || pod.DeletionTimestamp == nil {
+		return 0
+	}
+	wait := pod.DeletionTimestamp.Sub(now)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// switchoverTarget returns the index of the instance requested as the next primary
+// by `moco.cybozu.com/switchover` annotation of the MySQLCluster.
+// The second return value is false unless the target is one of the switchover candidates,
+// i.e. a healthy replica.
+func switchoverTarget(ss *StatusSet) (int, bool) {
+	val, ok := ss.Cluster.Annotations[constants.AnnSwitchover]
+	if !ok {
+		return 0, false
+	}
+	target, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, false
+	}
+	if !slices.Contains(ss.Candidates, target) {
+		return 0, false
+	}
+	return target, true
+}
+
+// forceFailoverRequested returns true if `moco.cybozu.com/force-failover` annotation
+// of the MySQLCluster is "true".
+func forceFailoverRequested(cluster *mocov1beta2.MySQLCluster) bool {
+	return cluster.Annotations[constants.AnnForceFailover] == "true"
+}
+
+// primaryExclusions returns the instances listed in `moco.cybozu.com/exclude-from-primary`
+// annotation of the MySQLCluster.  The value is a comma-separated list of instance indices.
+// An error is returned if the value contains an invalid index or excludes every instance.
+func primaryExclusions(cluster *mocov1beta2.MySQLCluster) ([]int, error) {
+	val := strings.TrimSpace(cluster.Annotations[constants.AnnExcludeFromPrimary])
+	if val == "" {
+		return nil, nil
+	}
+
+	var excluded []int
+	for _, s := range strings.Split(val, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || index < 0 || index >= int(cluster.Spec.Replicas) {
+			return nil, fmt.Errorf("invalid instance index %q in %s annotation", s, constants.AnnExcludeFromPrimary)
+		}
+		if !slices.Contains(excluded, index) {
+			excluded = append(excluded, index)
+		}
+	}
+	if len(excluded) >= int(cluster.Spec.Replicas) {
+		return nil, newClusterError(errAllInstancesExcluded, reasonAllInstancesExcluded, nil, excluded...)
+	}
+	sort.Ints(excluded)
+	return excluded, nil
+}
+
 func needSwitch(pod *corev1.Pod) bool {
 	if pod.DeletionTimestamp != nil {
 		return true
@@ -556,3 +751,23 @@ func needSwitch(pod *corev1.Pod) bool {
 	}
 	return false
 }
+
+// isReadOnly returns true if the instance is read-only for every user.
+// Both `read_only` and `super_read_only` need to be ON; an instance with
+// only `read_only` still accepts writes from users with SUPER privilege.
+func isReadOnly(st *dbop.MySQLInstanceStatus) bool {
+	return st.GlobalVariables.ReadOnly && st.GlobalVariables.SuperReadOnly
+}
+
+// isWritable returns true if the instance accepts writes from every user.
+// Both `read_only` and `super_read_only` need to be OFF; an instance with
+// only `super_read_only` is still read-only.
+func isWritable(st *dbop.MySQLInstanceStatus) bool {
+	return !st.GlobalVariables.ReadOnly && !st.GlobalVariables.SuperReadOnly
+}
+
+// isRecovering returns true if InnoDB of the instance is rolling back transactions
+// recovered by the crash recovery.  Such an instance is not operated until it completes.
+func isRecovering(st *dbop.MySQLInstanceStatus) bool {
+	return st != nil && st.RecoveredTransactions > 0
+}
@@ -1,12 +1,16 @@
 package clustering
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
 	"testing"
 	"time"
 
 	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/constants"
 	"github.com/cybozu-go/moco/pkg/dbop"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -72,6 +76,7 @@ func (b *ssBuilder) build() *StatusSet {
 		gtid = pst.GlobalVariables.ExecutedGTID
 	}
 	return &StatusSet{
+		Primary:      b.primaryIndex,
 		Cluster:      cluster,
 		Pods:         b.pods,
 		MySQLStatus:  b.mysqlStatus,
@@ -142,13 +147,20 @@ type mysqlBuilder struct {
 	readonly     bool
 	errant       bool
 	cloning      bool
+	noBinlog     bool
+	unhealthy    bool
 	sourceHost   string
 	replicaHosts []dbop.ReplicaHost
+	threads      int
+	maxConns     int
+	recovered    int
 }
 
 func (b *mysqlBuilder) build() *dbop.MySQLInstanceStatus {
 	st := &dbop.MySQLInstanceStatus{}
 	st.GlobalVariables.ExecutedGTID = b.gtid
+	st.GlobalVariables.LogBin = !b.noBinlog
+	st.CustomHealthCheckFailed = b.unhealthy
 	if b.readonly {
 		st.GlobalVariables.ReadOnly = true
 		st.GlobalVariables.SuperReadOnly = true
@@ -165,6 +177,9 @@ func (b *mysqlBuilder) build() *dbop.MySQLInstanceStatus {
 		}
 	}
 	st.ReplicaHosts = b.replicaHosts
+	st.ThreadsConnected = b.threads
+	st.GlobalVariables.MaxConnections = b.maxConns
+	st.RecoveredTransactions = b.recovered
 	return st
 }
 
@@ -177,6 +192,27 @@ func newMySQL(gtid string, readonly, errant, cloning bool) *mysqlBuilder {
 	}
 }
 
+func (b *mysqlBuilder) withBinlogDisabled() *mysqlBuilder {
+	b.noBinlog = true
+	return b
+}
+
+func (b *mysqlBuilder) withHealthCheckFailed() *mysqlBuilder {
+	b.unhealthy = true
+	return b
+}
+
+func (b *mysqlBuilder) withRecoveredTransactions(n int) *mysqlBuilder {
+	b.recovered = n
+	return b
+}
+
+func (b *mysqlBuilder) withConnections(threads, maxConns int) *mysqlBuilder {
+	b.threads = threads
+	b.maxConns = maxConns
+	return b
+}
+
 func (b *mysqlBuilder) withPrimary(hostname string) *mysqlBuilder {
 	b.sourceHost = hostname
 	return b
@@ -206,6 +242,14 @@ func TestStatusSet(t *testing.T) {
 				build(),
 			expectedState: StateHealthy,
 		},
+		{
+			name: "incomplete1-read-only",
+			statusSet: newSS(1, 0, false, false, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("", true, false, false).build()).
+				build(),
+			expectedState: StateIncomplete,
+		},
 		{
 			name: "lost1",
 			statusSet: newSS(1, 0, false, false, false, false).
@@ -481,6 +525,36 @@ func TestStatusSet(t *testing.T) {
 				build(),
 			expectedState: StateDegraded,
 		},
+		{
+			name: "degraded3-replica-health-check-failed",
+			statusSet: newSS(3, 0, false, false, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("1234", false, false, false).
+					withReplica(11, "replica1").
+					withReplica(12, "replica2").
+					build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).withHealthCheckFailed().build()).
+				build(),
+			expectedState: StateDegraded,
+		},
+		{
+			name: "incomplete3-all-replicas-health-check-failed",
+			statusSet: newSS(3, 0, false, false, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("1234", false, false, false).
+					withReplica(11, "replica1").
+					withReplica(12, "replica2").
+					build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).withHealthCheckFailed().build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).withHealthCheckFailed().build()).
+				build(),
+			expectedState: StateIncomplete,
+		},
 		{
 			name: "degraded3-replica-lost-data",
 			statusSet: newSS(3, 0, false, false, false, false).
@@ -598,3 +672,518 @@ func TestStatusSet(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusSetBootstrap(t *testing.T) {
+	// the first-ever check of a brand-new cluster: every instance is empty and read-only.
+	ss := newSS(3, 0, false, false, false, false).
+		withPod(true, false, false).
+		withPod(true, false, false).
+		withPod(true, false, false).
+		withMySQL(newMySQL("", true, false, false).build()).
+		withMySQL(newMySQL("", true, false, false).build()).
+		withMySQL(newMySQL("", true, false, false).build()).
+		build()
+	ss.DecideState()
+
+	if ss.State != StateIncomplete {
+		t.Errorf("unexpected state %s: expected=%s", ss.State.String(), StateIncomplete.String())
+	}
+	if ss.Primary != 0 {
+		t.Errorf("the initial primary must be instance 0: primary=%d", ss.Primary)
+	}
+	if ss.NeedSwitch {
+		t.Error("switchover should not be needed")
+	}
+	if len(ss.Errants) != 0 {
+		t.Errorf("empty instances must not be errant: %v", ss.Errants)
+	}
+	for i := 1; i < 3; i++ {
+		if !needReplicaConfiguration(ss.MySQLStatus[i], testPrimaryHostname, true, true) {
+			t.Errorf("instance %d should be configured as a replica", i)
+		}
+	}
+}
+
+func TestStatusSetReadOnlyReplica(t *testing.T) {
+	testCases := []struct {
+		name          string
+		readOnly      bool
+		superReadOnly bool
+		expectedState ClusterState
+	}{
+		{name: "read-only", readOnly: true, superReadOnly: true, expectedState: StateHealthy},
+		{name: "read-only-without-super", readOnly: true, superReadOnly: false, expectedState: StateDegraded},
+		{name: "super-read-only-without-read-only", readOnly: false, superReadOnly: true, expectedState: StateDegraded},
+		{name: "writable", readOnly: false, superReadOnly: false, expectedState: StateDegraded},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			replica := newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()
+			replica.GlobalVariables.ReadOnly = tc.readOnly
+			replica.GlobalVariables.SuperReadOnly = tc.superReadOnly
+
+			ss := newSS(3, 0, false, false, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withMySQL(newMySQL("1234", false, false, false).
+					withReplica(11, "replica1").
+					withReplica(12, "replica2").
+					build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				withMySQL(replica).
+				build()
+			ss.DecideState()
+			if ss.State != tc.expectedState {
+				t.Errorf("unexpected state %s: expected=%s", ss.State.String(), tc.expectedState.String())
+			}
+			if isReadOnly(replica) != (tc.readOnly && tc.superReadOnly) {
+				t.Errorf("unexpected read-only judgement: %v", isReadOnly(replica))
+			}
+		})
+	}
+}
+
+func TestStatusSetWritablePrimary(t *testing.T) {
+	testCases := []struct {
+		name          string
+		readOnly      bool
+		superReadOnly bool
+		expectedState ClusterState
+	}{
+		{name: "writable", readOnly: false, superReadOnly: false, expectedState: StateHealthy},
+		{name: "super-read-only-without-read-only", readOnly: false, superReadOnly: true, expectedState: StateIncomplete},
+		{name: "read-only", readOnly: true, superReadOnly: true, expectedState: StateIncomplete},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			primary := newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				withReplica(12, "replica2").
+				build()
+			primary.GlobalVariables.ReadOnly = tc.readOnly
+			primary.GlobalVariables.SuperReadOnly = tc.superReadOnly
+
+			ss := newSS(3, 0, false, false, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withPod(true, false, false).
+				withMySQL(primary).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+				build()
+			ss.DecideState()
+			if ss.State != tc.expectedState {
+				t.Errorf("unexpected state %s: expected=%s", ss.State.String(), tc.expectedState.String())
+			}
+			if isWritable(primary) != (!tc.readOnly && !tc.superReadOnly) {
+				t.Errorf("unexpected writable judgement: %v", isWritable(primary))
+			}
+		})
+	}
+}
+
+func TestStatusSetBinlogDisabled(t *testing.T) {
+	newDeletingPrimarySS := func(replica1, replica2 *mysqlBuilder) *StatusSet {
+		return newSS(3, 0, false, false, false, false).
+			withPod(true, true, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				withReplica(12, "replica2").
+				build()).
+			withMySQL(replica1.withPrimary(testPrimaryHostname).build()).
+			withMySQL(replica2.withPrimary(testPrimaryHostname).build()).
+			build()
+	}
+
+	t.Run("one-replica", func(t *testing.T) {
+		ss := newDeletingPrimarySS(
+			newMySQL("123", true, false, false).withBinlogDisabled(),
+			newMySQL("123", true, false, false),
+		)
+		ss.DecideState()
+		if ss.State != StateHealthy {
+			t.Errorf("unexpected state %s: expected=%s", ss.State.String(), StateHealthy.String())
+		}
+		if !ss.NeedSwitch {
+			t.Error("switchover should be needed")
+		}
+		if ss.Candidate != 2 {
+			t.Errorf("instance with binlog disabled must not be a candidate: candidate=%d", ss.Candidate)
+		}
+	})
+
+	t.Run("all-replicas", func(t *testing.T) {
+		ss := newDeletingPrimarySS(
+			newMySQL("123", true, false, false).withBinlogDisabled(),
+			newMySQL("123", true, false, false).withBinlogDisabled(),
+		)
+		ss.DecideState()
+		if ss.NeedSwitch {
+			t.Error("switchover should not be done without a candidate")
+		}
+		if len(ss.Candidates) != 0 {
+			t.Errorf("unexpected candidates: %v", ss.Candidates)
+		}
+	})
+}
+
+func TestStatusSetRecovering(t *testing.T) {
+	ss := newSS(3, 0, false, false, false, false).
+		withPod(true, true, false).
+		withPod(true, false, false).
+		withPod(true, false, false).
+		withMySQL(newMySQL("1234", false, false, false).
+			withReplica(11, "replica1").
+			withReplica(12, "replica2").
+			build()).
+		withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).withRecoveredTransactions(3).build()).
+		withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+		build()
+	ss.DecideState()
+	if ss.State != StateHealthy {
+		t.Errorf("unexpected state %s: expected=%s", ss.State.String(), StateHealthy.String())
+	}
+	if !ss.NeedSwitch {
+		t.Error("switchover should be needed")
+	}
+	if ss.Candidate != 2 {
+		t.Errorf("instance rolling back transactions must not be a candidate: candidate=%d", ss.Candidate)
+	}
+}
+
+func TestStatusSetPlannedSwitchover(t *testing.T) {
+	newHealthySS := func(replica2 *mysqlBuilder) *StatusSet {
+		return newSS(3, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				withReplica(12, "replica2").
+				build()).
+			withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+			withMySQL(replica2.build()).
+			build()
+	}
+
+	testCases := []struct {
+		name            string
+		annotation      string
+		replica2        *mysqlBuilder
+		expectSwitch    bool
+		expectCandidate int
+	}{
+		{
+			name:            "no-request",
+			replica2:        newMySQL("123", true, false, false).withPrimary(testPrimaryHostname),
+			expectCandidate: 1,
+		},
+		{
+			name:            "healthy-target",
+			annotation:      "2",
+			replica2:        newMySQL("123", true, false, false).withPrimary(testPrimaryHostname),
+			expectSwitch:    true,
+			expectCandidate: 2,
+		},
+		{
+			name:            "current-primary",
+			annotation:      "0",
+			replica2:        newMySQL("123", true, false, false).withPrimary(testPrimaryHostname),
+			expectCandidate: 1,
+		},
+		{
+			name:            "invalid-index",
+			annotation:      "foo",
+			replica2:        newMySQL("123", true, false, false).withPrimary(testPrimaryHostname),
+			expectCandidate: 1,
+		},
+		{
+			name:            "unhealthy-target",
+			annotation:      "2",
+			replica2:        newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).withHealthCheckFailed(),
+			expectCandidate: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := newHealthySS(tc.replica2)
+			if tc.annotation != "" {
+				ss.Cluster.Annotations = map[string]string{constants.AnnSwitchover: tc.annotation}
+			}
+			ss.DecideState()
+			if ss.NeedSwitch != tc.expectSwitch {
+				t.Errorf("unexpected NeedSwitch: expected=%v, actual=%v", tc.expectSwitch, ss.NeedSwitch)
+			}
+			if ss.PlannedSwitchover != tc.expectSwitch {
+				t.Errorf("unexpected PlannedSwitchover: expected=%v, actual=%v", tc.expectSwitch, ss.PlannedSwitchover)
+			}
+			if ss.Candidate != tc.expectCandidate {
+				t.Errorf("unexpected candidate: expected=%d, actual=%d", tc.expectCandidate, ss.Candidate)
+			}
+		})
+	}
+}
+
+func TestPrimaryExclusions(t *testing.T) {
+	testCases := []struct {
+		name       string
+		annotation string
+		expected   []int
+		err        bool
+	}{
+		{name: "none"},
+		{name: "single", annotation: "1", expected: []int{1}},
+		{name: "multiple", annotation: "2, 0,2", expected: []int{0, 2}},
+		{name: "invalid", annotation: "1,foo", err: true},
+		{name: "out-of-range", annotation: "3", err: true},
+		{name: "all", annotation: "0,1,2", err: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := &mocov1beta2.MySQLCluster{}
+			cluster.Spec.Replicas = 3
+			if tc.annotation != "" {
+				cluster.Annotations = map[string]string{constants.AnnExcludeFromPrimary: tc.annotation}
+			}
+			excluded, err := primaryExclusions(cluster)
+			if tc.err {
+				if err == nil {
+					t.Error("an error should be returned")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(excluded, tc.expected) {
+				t.Errorf("unexpected exclusions: expected=%v, actual=%v", tc.expected, excluded)
+			}
+		})
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Spec.Replicas = 1
+	cluster.Annotations = map[string]string{constants.AnnExcludeFromPrimary: "0"}
+	if _, err := primaryExclusions(cluster); !errors.Is(err, errAllInstancesExcluded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestStatusSetExcludeFromPrimary(t *testing.T) {
+	newHealthySS := func(annotation string) *StatusSet {
+		ss := newSS(3, 0, false, false, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				withReplica(12, "replica2").
+				build()).
+			withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+			withMySQL(newMySQL("123", true, false, false).withPrimary(testPrimaryHostname).build()).
+			build()
+		ss.Cluster.Annotations = map[string]string{constants.AnnExcludeFromPrimary: annotation}
+		return ss
+	}
+
+	testCases := []struct {
+		name            string
+		annotation      string
+		expectSwitch    bool
+		expectCandidate int
+	}{
+		{name: "replica", annotation: "1", expectCandidate: 2},
+		{name: "primary", annotation: "0", expectSwitch: true, expectCandidate: 1},
+		{name: "primary-and-replica", annotation: "0,1", expectSwitch: true, expectCandidate: 2},
+		{name: "all", annotation: "0,1,2", expectCandidate: 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := newHealthySS(tc.annotation)
+			ss.DecideState()
+			if ss.State != StateHealthy {
+				t.Fatalf("unexpected state: %s", ss.State)
+			}
+			if ss.NeedSwitch != tc.expectSwitch {
+				t.Errorf("unexpected NeedSwitch: expected=%v, actual=%v", tc.expectSwitch, ss.NeedSwitch)
+			}
+			if ss.Candidate != tc.expectCandidate {
+				t.Errorf("unexpected candidate: expected=%d, actual=%d", tc.expectCandidate, ss.Candidate)
+			}
+		})
+	}
+
+	t.Run("switchover-target", func(t *testing.T) {
+		ss := newHealthySS("2")
+		ss.Cluster.Annotations[constants.AnnSwitchover] = "2"
+		ss.DecideState()
+		if ss.PlannedSwitchover {
+			t.Error("an excluded instance should not be a switchover target")
+		}
+	})
+}
+
+func TestStatusSetConnectionHeadroom(t *testing.T) {
+	newDeletingPrimarySS := func(primaryThreads int, replica1, replica2 *mysqlBuilder) *StatusSet {
+		return newSS(3, 0, false, false, false, false).
+			withPod(true, true, false).
+			withPod(true, false, false).
+			withPod(true, false, false).
+			withMySQL(newMySQL("1234", false, false, false).
+				withReplica(11, "replica1").
+				withReplica(12, "replica2").
+				withConnections(primaryThreads, 1000).
+				build()).
+			withMySQL(replica1.withPrimary(testPrimaryHostname).build()).
+			withMySQL(replica2.withPrimary(testPrimaryHostname).build()).
+			build()
+	}
+
+	testCases := []struct {
+		name            string
+		primaryThreads  int
+		replica1        *mysqlBuilder
+		replica2        *mysqlBuilder
+		expectCandidate int
+		expectHeadroom  bool
+	}{
+		{
+			name:            "unknown",
+			primaryThreads:  100,
+			replica1:        newMySQL("123", true, false, false),
+			replica2:        newMySQL("123", true, false, false),
+			expectCandidate: 1,
+			expectHeadroom:  true,
+		},
+		{
+			name:            "enough",
+			primaryThreads:  100,
+			replica1:        newMySQL("123", true, false, false).withConnections(10, 1000),
+			replica2:        newMySQL("123", true, false, false).withConnections(10, 1000),
+			expectCandidate: 1,
+			expectHeadroom:  true,
+		},
+		{
+			name:            "near-capacity",
+			primaryThreads:  100,
+			replica1:        newMySQL("123", true, false, false).withConnections(850, 1000),
+			replica2:        newMySQL("123", true, false, false).withConnections(10, 1000),
+			expectCandidate: 2,
+			expectHeadroom:  true,
+		},
+		{
+			name:            "low-max-connections",
+			primaryThreads:  100,
+			replica1:        newMySQL("123", true, false, false).withConnections(10, 100),
+			replica2:        newMySQL("123", true, false, false).withConnections(10, 1000),
+			expectCandidate: 2,
+			expectHeadroom:  true,
+		},
+		{
+			name:            "none",
+			primaryThreads:  500,
+			replica1:        newMySQL("123", true, false, false).withConnections(10, 500),
+			replica2:        newMySQL("123", true, false, false).withConnections(10, 500),
+			expectCandidate: 1,
+			expectHeadroom:  false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ss := newDeletingPrimarySS(tc.primaryThreads, tc.replica1, tc.replica2)
+			ss.DecideState()
+			if !ss.NeedSwitch {
+				t.Fatal("switchover should be needed")
+			}
+			if ss.Candidate != tc.expectCandidate {
+				t.Errorf("unexpected candidate: expected=%d, actual=%d", tc.expectCandidate, ss.Candidate)
+			}
+			if actual := hasConnectionHeadroom(ss, ss.Candidate); actual != tc.expectHeadroom {
+				t.Errorf("unexpected headroom: expected=%v, actual=%v", tc.expectHeadroom, actual)
+			}
+		})
+	}
+}
+
+type healthOperator struct {
+	dbop.NopOperator
+	ok      bool
+	err     error
+	queries []string
+}
+
+func (o *healthOperator) CheckHealth(ctx context.Context, query string) (bool, error) {
+	o.queries = append(o.queries, query)
+	return o.ok, o.err
+}
+
+func TestCheckReplicaHealth(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     string
+		op        *healthOperator
+		queried   bool
+		unhealthy bool
+	}{
+		{name: "no query", op: &healthOperator{}},
+		{name: "healthy", query: "SELECT 1", op: &healthOperator{ok: true}, queried: true},
+		{name: "unhealthy", query: "SELECT 0", op: &healthOperator{}, queried: true, unhealthy: true},
+		{name: "error", query: "SELECT 1", op: &healthOperator{err: errors.New("timeout")}, queried: true, unhealthy: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ist := &dbop.MySQLInstanceStatus{}
+			checkReplicaHealth(context.Background(), tc.op, ist, 1, tc.query)
+			if queried := len(tc.op.queries) > 0; queried != tc.queried {
+				t.Errorf("unexpected queries: %v", tc.op.queries)
+			}
+			if ist.CustomHealthCheckFailed != tc.unhealthy {
+				t.Errorf("unexpected CustomHealthCheckFailed: %v", ist.CustomHealthCheckFailed)
+			}
+		})
+	}
+}
+
+func TestContainErrantTransactions(t *testing.T) {
+	const primaryUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+	testCases := []struct {
+		name     string
+		gtidSet  string
+		expected bool
+	}{
+		{name: "empty", gtidSet: "", expected: false},
+		{name: "primary-only", gtidSet: primaryUUID + ":1-10", expected: false},
+		{name: "primary-multi-interval", gtidSet: primaryUUID + ":1-3:5-10", expected: false},
+		{name: "old-primary", gtidSet: "4f0c0d3a-71ca-11e1-9e33-c80aa9429562:1-5", expected: true},
+		{name: "mixed", gtidSet: primaryUUID + ":1-10,4f0c0d3a-71ca-11e1-9e33-c80aa9429562:1", expected: true},
+		// a data directory taken from another cluster has only foreign GTIDs.
+		{name: "foreign-cluster", gtidSet: "8a94f357-aab4-11df-86ab-c80aa9429562:1-100", expected: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual := containErrantTransactions(primaryUUID, tc.gtidSet)
+			if actual != tc.expected {
+				t.Errorf("unexpected result: expected=%v, actual=%v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package clustering
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Types of StreamEvent.
+const (
+	StreamEventStateChanged     = "StateChanged"
+	StreamEventPrimaryChanged   = "PrimaryChanged"
+	StreamEventReplicaOutOfSync = "ReplicaOutOfSync"
+	StreamEventConditionChanged = "ConditionChanged"
+)
+
+// streamBufferSize is the number of events buffered for each subscriber.
+// Events for a subscriber that cannot keep up are dropped.
+const streamBufferSize = 16
+
+// StreamEvent is a change of a MySQLCluster sent to the subscribers of EventStream.
+type StreamEvent struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+}
+
+// EventStream distributes the decisions and the condition changes of MySQLClusters
+// to HTTP clients as Server-Sent Events.
+//
+// Clients subscribe to a cluster with `namespace` and `name` query parameters.
+type EventStream struct {
+	mu          sync.Mutex
+	subscribers map[types.NamespacedName]map[chan StreamEvent]struct{}
+}
+
+// NewEventStream creates an EventStream.
+func NewEventStream() *EventStream {
+	return &EventStream{
+		subscribers: make(map[types.NamespacedName]map[chan StreamEvent]struct{}),
+	}
+}
+
+var defaultStream *EventStream
+
+// SetEventStream sets the EventStream to which the manager processes publish events.
+// If this is not called, no events are published.
+func SetEventStream(s *EventStream) {
+	defaultStream = s
+}
+
+// Publish sends `ev` to the subscribers of the cluster.  This never blocks.
+func (s *EventStream) Publish(ev StreamEvent) {
+	if s == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers[types.NamespacedName{Namespace: ev.Namespace, Name: ev.Name}] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (s *EventStream) subscribe(name types.NamespacedName) chan StreamEvent {
+	ch := make(chan StreamEvent, streamBufferSize)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribers[name] == nil {
+		s.subscribers[name] = make(map[chan StreamEvent]struct{})
+	}
+	s.subscribers[name][ch] = struct{}{}
+	return ch
+}
+
+func (s *EventStream) unsubscribe(name types.NamespacedName, ch chan StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscribers[name], ch)
+	if len(s.subscribers[name]) == 0 {
+		delete(s.subscribers, name)
+	}
+}
+
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	name := types.NamespacedName{
+		Namespace: r.URL.Query().Get("namespace"),
+		Name:      r.URL.Query().Get("name"),
+	}
+	if name.Namespace == "" || name.Name == "" {
+		http.Error(w, "namespace and name must be specified", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := s.subscribe(name)
+	defer s.unsubscribe(name, ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				logFromContext(r.Context()).Error(err, "failed to marshal an event")
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package clustering
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/constants"
+	"github.com/cybozu-go/moco/pkg/metrics"
+	"github.com/cybozu-go/moco/pkg/password"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEventStreamBadRequest(t *testing.T) {
+	s := NewEventStream()
+
+	testCases := []struct {
+		name   string
+		method string
+		url    string
+		code   int
+	}{
+		{name: "post", method: http.MethodPost, url: "/events?namespace=foo&name=test", code: http.StatusMethodNotAllowed},
+		{name: "no-namespace", method: http.MethodGet, url: "/events?name=test", code: http.StatusBadRequest},
+		{name: "no-name", method: http.MethodGet, url: "/events?namespace=foo", code: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.url, nil))
+			if rec.Code != tc.code {
+				t.Errorf("unexpected status code: expected=%d, actual=%d", tc.code, rec.Code)
+			}
+		})
+	}
+}
+
+// readStreamEvents parses Server-Sent Events from `body` and sends them to the returned channel.
+func readStreamEvents(t *testing.T, body *bufio.Reader) <-chan StreamEvent {
+	ch := make(chan StreamEvent, 100)
+	go func() {
+		defer close(ch)
+		var typ string
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				typ = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				var ev StreamEvent
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil {
+					t.Errorf("failed to unmarshal event data %q: %v", line, err)
+					return
+				}
+				if ev.Type != typ {
+					t.Errorf("event type mismatch: %s, %s", typ, ev.Type)
+				}
+				ch <- ev
+			}
+		}
+	}()
+	return ch
+}
+
+func TestEventStream(t *testing.T) {
+	s := NewEventStream()
+	SetEventStream(s)
+	defer SetEventStream(nil)
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events?namespace=test&name=test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type: %s", ct)
+	}
+	events := readStreamEvents(t, bufio.NewReader(resp.Body))
+
+	// events of other clusters should not be delivered.
+	s.Publish(StreamEvent{Namespace: "test", Name: "other", Type: StreamEventStateChanged, Message: "other"})
+
+	// simulate a reconciliation of a new single instance cluster.
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "test"
+	cluster.Name = "test"
+	cluster.Spec.Replicas = 1
+
+	passwd, err := password.NewMySQLPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := passwd.ToSecret()
+	secret.Namespace = "test"
+	secret.Name = cluster.UserSecretName()
+
+	pod := &corev1.Pod{}
+	pod.Namespace = "test"
+	pod.Name = cluster.PodName(0)
+	pod.Labels = map[string]string{
+		constants.LabelAppName:     constants.AppNameMySQL,
+		constants.LabelAppInstance: cluster.Name,
+	}
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(cluster, secret, pod).
+		WithStatusSubresource(&mocov1beta2.MySQLCluster{}).
+		Build()
+
+	of := newMockOpFactory()
+	m := &mockMySQL{}
+	m.status.GlobalVariables.UUID = "p0"
+	m.status.GlobalVariables.LogBin = true
+	of.mysqls[cluster.PodHostname(0)] = m
+
+	metrics.Register(prometheus.NewRegistry())
+	p := newManagerProcess(c, c, record.NewFakeRecorder(10), of, nil, nil, types.NamespacedName{Namespace: "test", Name: "test"}, func() {})
+	if _, err := p.do(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	received := make(map[string]StreamEvent)
+	timeout := time.After(10 * time.Second)
+	for received[StreamEventStateChanged].Type == "" || received[StreamEventConditionChanged].Type == "" {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("the stream is closed")
+			}
+			if ev.Namespace != "test" || ev.Name != "test" {
+				t.Fatalf("received an event of another cluster: %+v", ev)
+			}
+			received[ev.Type] = ev
+		case <-timeout:
+			t.Fatalf("timed out; received events: %+v", received)
+		}
+	}
+	if msg := received[StreamEventStateChanged].Message; msg != "the state changed from Undecided to Healthy" {
+		t.Errorf("unexpected message: %s", msg)
+	}
+	if received[StreamEventStateChanged].Time.IsZero() {
+		t.Error("the time of the event is not set")
+	}
+}
@@ -0,0 +1,73 @@
+package clustering
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterSummary is the summary of a MySQLCluster served by the summary handler.
+type ClusterSummary struct {
+	Name                string `json:"name"`
+	Namespace           string `json:"namespace"`
+	CurrentPrimaryIndex int    `json:"currentPrimaryIndex"`
+	Ready               bool   `json:"ready"`
+	SyncedReplicas      int    `json:"syncedReplicas"`
+}
+
+// NewSummaryHandler returns an http.Handler that serves the summaries of
+// all MySQLClusters as a JSON array sorted by their namespaces and names.
+//
+// `reader` should be the cached client of the manager so that requests
+// to the handler do not hit the API server.
+func NewSummaryHandler(reader client.Reader) http.Handler {
+	return summaryHandler{reader: reader}
+}
+
+type summaryHandler struct {
+	reader client.Reader
+}
+
+func (h summaryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	clusters := &mocov1beta2.MySQLClusterList{}
+	if err := h.reader.List(r.Context(), clusters); err != nil {
+		http.Error(w, "failed to list MySQLClusters: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summaries := summarizeClusters(clusters.Items)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summaries); err != nil {
+		logFromContext(r.Context()).Error(err, "failed to write cluster summaries")
+	}
+}
+
+func summarizeClusters(clusters []mocov1beta2.MySQLCluster) []ClusterSummary {
+	summaries := make([]ClusterSummary, 0, len(clusters))
+	for _, cluster := range clusters {
+		summaries = append(summaries, ClusterSummary{
+			Name:                cluster.Name,
+			Namespace:           cluster.Namespace,
+			CurrentPrimaryIndex: cluster.Status.CurrentPrimaryIndex,
+			Ready:               meta.IsStatusConditionTrue(cluster.Status.Conditions, mocov1beta2.ConditionReady),
+			SyncedReplicas:      cluster.Status.SyncedReplicas,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Namespace != summaries[j].Namespace {
+			return summaries[i].Namespace < summaries[j].Namespace
+		}
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries
+}
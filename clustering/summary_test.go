@@ -0,0 +1,74 @@
+package clustering
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSummaryHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	newCluster := func(namespace, name string, primary, synced int, ready metav1.ConditionStatus) *mocov1beta2.MySQLCluster {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = namespace
+		cluster.Name = name
+		cluster.Status.CurrentPrimaryIndex = primary
+		cluster.Status.SyncedReplicas = synced
+		cluster.Status.Conditions = []metav1.Condition{
+			{Type: mocov1beta2.ConditionReady, Status: ready, Reason: "Healthy"},
+		}
+		return cluster
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newCluster("foo", "db", 2, 3, metav1.ConditionTrue),
+		newCluster("bar", "db2", 0, 1, metav1.ConditionFalse),
+		newCluster("bar", "db1", 1, 3, metav1.ConditionTrue),
+	).Build()
+	h := NewSummaryHandler(c)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/clusters", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+
+	var actual []ClusterSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &actual); err != nil {
+		t.Fatal(err)
+	}
+	expected := []ClusterSummary{
+		{Name: "db1", Namespace: "bar", CurrentPrimaryIndex: 1, Ready: true, SyncedReplicas: 3},
+		{Name: "db2", Namespace: "bar", CurrentPrimaryIndex: 0, Ready: false, SyncedReplicas: 1},
+		{Name: "db", Namespace: "foo", CurrentPrimaryIndex: 2, Ready: true, SyncedReplicas: 3},
+	}
+	if !cmp.Equal(actual, expected) {
+		t.Errorf("unexpected summaries: %s", cmp.Diff(expected, actual))
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/clusters", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code for POST: %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	NewSummaryHandler(fake.NewClientBuilder().WithScheme(scheme).Build()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/clusters", nil))
+	if body := rec.Body.String(); body != "[]\n" {
+		t.Errorf("unexpected body for no clusters: %q", body)
+	}
+}
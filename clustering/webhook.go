@@ -0,0 +1,146 @@
+package clustering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Reasons of PrimaryChangeNotification.
+const (
+	PrimaryChangeReasonSwitchOver = "SwitchOver"
+	PrimaryChangeReasonFailOver   = "FailOver"
+)
+
+// Parameters to send notifications to `spec.failoverWebhookURL`.
+// These are variables for testing.
+var (
+	webhookTimeout       = 10 * time.Second
+	webhookMaxAttempts   = 3
+	webhookRetryInterval = 5 * time.Second
+)
+
+// PrimaryChangeNotification is the payload POSTed to `spec.failoverWebhookURL`.
+type PrimaryChangeNotification struct {
+	Namespace       string    `json:"namespace"`
+	Name            string    `json:"name"`
+	OldPrimaryIndex int       `json:"oldPrimaryIndex"`
+	NewPrimaryIndex int       `json:"newPrimaryIndex"`
+	Reason          string    `json:"reason"`
+	Time            time.Time `json:"time"`
+}
+
+// notifyPrimaryChange notifies the change of the primary instance to `spec.failoverWebhookURL`
+// if it is set.  The notification is sent in the background so that a slow webhook
+// does not block the reconciliation.  The returned channel is closed when
+// the notification is done.
+//
+// The URL is given by users of MySQLCluster, so the notification is sent only if the host
+// of the URL is allowed by `--failover-webhook-hosts` flag of moco-controller.
+// The notification is cancelled when `ctx` is, i.e. when the process stops.
+func (p *managerProcess) notifyPrimaryChange(ctx context.Context, ss *StatusSet, reason string) <-chan struct{} {
+	done := make(chan struct{})
+	url := ss.Cluster.Spec.FailoverWebhookURL
+	if url == "" {
+		close(done)
+		return done
+	}
+
+	log := logFromContext(ctx)
+	if err := p.checkWebhookURL(url); err != nil {
+		log.Error(err, "the primary change is not notified", "url", url)
+		close(done)
+		return done
+	}
+
+	n := PrimaryChangeNotification{
+		Namespace:       ss.Cluster.Namespace,
+		Name:            ss.Cluster.Name,
+		OldPrimaryIndex: ss.Primary,
+		NewPrimaryIndex: ss.Candidate,
+		Reason:          reason,
+		Time:            time.Now().UTC(),
+	}
+	go func() {
+		defer close(done)
+		if err := postNotification(ctx, log, url, n); err != nil {
+			log.Error(err, "failed to notify the primary change", "url", url)
+		}
+	}()
+	return done
+}
+
+// checkWebhookURL returns an error if the host of `rawURL` is not allowed.
+func (p *managerProcess) checkWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme of the webhook URL: %s", u.Scheme)
+	}
+	for _, h := range p.webhookHosts {
+		if strings.EqualFold(u.Hostname(), h) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the host of the webhook URL is not allowed: %s", u.Hostname())
+}
+
+func postNotification(ctx context.Context, log logr.Logger, url string, n PrimaryChangeNotification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the notification: %w", err)
+	}
+
+	client := &http.Client{
+		Timeout: webhookTimeout,
+		// do not follow redirects to hosts that are not allowed.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for i := 1; ; i++ {
+		err = postOnce(ctx, client, url, data)
+		if err == nil {
+			return nil
+		}
+		if i >= webhookMaxAttempts {
+			return fmt.Errorf("gave up after %d attempts: %w", i, err)
+		}
+		log.Info("retrying the notification of the primary change", "url", url, "attempt", i, "error", err.Error())
+
+		timer := time.NewTimer(webhookRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func postOnce(ctx context.Context, client *http.Client, url string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
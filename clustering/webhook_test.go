@@ -0,0 +1,188 @@
+package clustering
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+)
+
+func newWebhookSS(url string) *StatusSet {
+	cluster := &mocov1beta2.MySQLCluster{}
+	cluster.Namespace = "ns"
+	cluster.Name = "test"
+	cluster.Spec.FailoverWebhookURL = url
+	return &StatusSet{Cluster: cluster, Primary: 0, Candidate: 2}
+}
+
+func setWebhookParams(t *testing.T, timeout, interval time.Duration) {
+	origTimeout, origAttempts, origInterval := webhookTimeout, webhookMaxAttempts, webhookRetryInterval
+	t.Cleanup(func() {
+		webhookTimeout, webhookMaxAttempts, webhookRetryInterval = origTimeout, origAttempts, origInterval
+	})
+	webhookTimeout = timeout
+	webhookMaxAttempts = 3
+	webhookRetryInterval = interval
+}
+
+func waitNotification(t *testing.T, done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the notification did not finish")
+	}
+}
+
+func TestNotifyPrimaryChange(t *testing.T) {
+	setWebhookParams(t, time.Second, 10*time.Millisecond)
+
+	received := make(chan PrimaryChangeNotification, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method: %s", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type: %s", ct)
+		}
+		var n PrimaryChangeNotification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Error(err)
+		}
+		received <- n
+	}))
+	defer srv.Close()
+
+	p := &managerProcess{webhookHosts: []string{"127.0.0.1"}}
+	before := time.Now()
+	waitNotification(t, p.notifyPrimaryChange(context.Background(), newWebhookSS(srv.URL), PrimaryChangeReasonFailOver))
+
+	select {
+	case n := <-received:
+		if n.Namespace != "ns" || n.Name != "test" {
+			t.Errorf("unexpected cluster: %s/%s", n.Namespace, n.Name)
+		}
+		if n.OldPrimaryIndex != 0 || n.NewPrimaryIndex != 2 {
+			t.Errorf("unexpected indices: old=%d, new=%d", n.OldPrimaryIndex, n.NewPrimaryIndex)
+		}
+		if n.Reason != PrimaryChangeReasonFailOver {
+			t.Errorf("unexpected reason: %s", n.Reason)
+		}
+		if n.Time.Before(before.Add(-time.Second)) || n.Time.After(time.Now()) {
+			t.Errorf("unexpected time: %s", n.Time)
+		}
+	default:
+		t.Fatal("no notification was received")
+	}
+}
+
+func TestNotifyPrimaryChangeNoURL(t *testing.T) {
+	p := &managerProcess{webhookHosts: []string{"127.0.0.1"}}
+	select {
+	case <-p.notifyPrimaryChange(context.Background(), newWebhookSS(""), PrimaryChangeReasonSwitchOver):
+	default:
+		t.Error("nothing should be done without the URL")
+	}
+}
+
+func TestNotifyPrimaryChangeRetry(t *testing.T) {
+	setWebhookParams(t, time.Second, 10*time.Millisecond)
+
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	p := &managerProcess{webhookHosts: []string{"127.0.0.1"}}
+	waitNotification(t, p.notifyPrimaryChange(context.Background(), newWebhookSS(srv.URL), PrimaryChangeReasonSwitchOver))
+	if n := atomic.LoadInt32(&count); n != 3 {
+		t.Errorf("unexpected number of attempts: %d", n)
+	}
+}
+
+func TestNotifyPrimaryChangeNotBlocking(t *testing.T) {
+	setWebhookParams(t, 100*time.Millisecond, 10*time.Millisecond)
+
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-stop:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	p := &managerProcess{webhookHosts: []string{"127.0.0.1"}}
+	start := time.Now()
+	done := p.notifyPrimaryChange(context.Background(), newWebhookSS(srv.URL), PrimaryChangeReasonFailOver)
+	if elapsed := time.Since(start); elapsed >= webhookTimeout {
+		t.Errorf("the reconciliation was blocked by a slow webhook for %s", elapsed)
+	}
+
+	// the notification gives up after the attempts time out.
+	waitNotification(t, done)
+}
+
+func TestNotifyPrimaryChangeNotAllowed(t *testing.T) {
+	var count int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+	}))
+	defer srv.Close()
+
+	for _, hosts := range [][]string{nil, {"example.com"}} {
+		p := &managerProcess{webhookHosts: hosts}
+		select {
+		case <-p.notifyPrimaryChange(context.Background(), newWebhookSS(srv.URL), PrimaryChangeReasonFailOver):
+		default:
+			t.Errorf("nothing should be done for hosts %v", hosts)
+		}
+	}
+	if n := atomic.LoadInt32(&count); n != 0 {
+		t.Errorf("the webhook was called %d times", n)
+	}
+}
+
+func TestNotifyPrimaryChangeNoRedirect(t *testing.T) {
+	setWebhookParams(t, time.Second, 10*time.Millisecond)
+
+	var count int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	p := &managerProcess{webhookHosts: []string{"127.0.0.1"}}
+	waitNotification(t, p.notifyPrimaryChange(context.Background(), newWebhookSS(srv.URL), PrimaryChangeReasonFailOver))
+	if n := atomic.LoadInt32(&count); n != 0 {
+		t.Errorf("the redirect was followed %d times", n)
+	}
+}
+
+func TestNotifyPrimaryChangeCancel(t *testing.T) {
+	setWebhookParams(t, time.Second, time.Hour)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &managerProcess{webhookHosts: []string{"127.0.0.1"}}
+	done := p.notifyPrimaryChange(ctx, newWebhookSS(srv.URL), PrimaryChangeReasonSwitchOver)
+	cancel()
+
+	// the notification stops without waiting for the retry interval.
+	waitNotification(t, done)
+}
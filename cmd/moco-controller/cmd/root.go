@@ -12,6 +12,7 @@ import (
 
 	"github.com/cybozu-go/moco"
 	"github.com/cybozu-go/moco/pkg/constants"
+	"github.com/cybozu-go/moco/pkg/dbop"
 	"github.com/spf13/cobra"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -37,6 +38,15 @@ var config struct {
 	interval                time.Duration
 	maxConcurrentReconciles int
 	qps                     int
+	mysqlMaxIdleConns       int
+	mysqlMaxOpenConns       int
+	mysqlSeparateStatusPool bool
+	mysqlTLSCertDir         string
+	mysqlTLSServerName      string
+	mysqlTLSSkipVerify      bool
+	clusterSummary          bool
+	failoverWebhookHosts    []string
+	eventStream             bool
 	zapOpts                 zap.Options
 }
 
@@ -73,6 +83,18 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("invalid webhook address: %s, %v", config.webhookAddr, err)
 		}
+		if config.interval <= 0 {
+			return fmt.Errorf("invalid check-interval: %s", config.interval)
+		}
+		if config.maxConcurrentReconciles <= 0 {
+			return fmt.Errorf("invalid max-concurrent-reconciles: %d", config.maxConcurrentReconciles)
+		}
+		if config.mysqlMaxIdleConns < 0 {
+			return fmt.Errorf("invalid mysql-max-idle-conns: %d", config.mysqlMaxIdleConns)
+		}
+		if config.mysqlMaxOpenConns < 0 {
+			return fmt.Errorf("invalid mysql-max-open-conns: %d", config.mysqlMaxOpenConns)
+		}
 		ns := os.Getenv(constants.PodNamespaceEnvKey)
 		if ns == "" {
 			return fmt.Errorf("no environment variable %s", constants.PodNamespaceEnvKey)
@@ -105,6 +127,15 @@ func init() {
 	fs.StringVar(&config.exporterImage, "mysqld-exporter-image", moco.ExporterImage, "The image of mysqld_exporter sidecar container")
 	fs.DurationVar(&config.interval, "check-interval", 1*time.Minute, "Interval of cluster maintenance")
 	fs.IntVar(&config.maxConcurrentReconciles, "max-concurrent-reconciles", 8, "The maximum number of concurrent reconciles which can be run")
+	fs.IntVar(&config.mysqlMaxIdleConns, "mysql-max-idle-conns", dbop.DefaultFactoryConfig.MaxIdleConns, "The maximum number of idle connections to each MySQL instance")
+	fs.IntVar(&config.mysqlMaxOpenConns, "mysql-max-open-conns", dbop.DefaultFactoryConfig.MaxOpenConns, "The maximum number of open connections to each MySQL instance. 0 means unlimited")
+	fs.BoolVar(&config.mysqlSeparateStatusPool, "mysql-separate-status-pool", false, "Gather the status of MySQL instances through connection pools separated from ones for the other operations")
+	fs.StringVar(&config.mysqlTLSCertDir, "mysql-tls-cert-dir", "", "Directory of ca.crt and optional tls.crt/tls.key to connect to MySQL instances over TLS. TLS is disabled by default")
+	fs.StringVar(&config.mysqlTLSServerName, "mysql-tls-server-name", "", "Server name to verify the certificates of MySQL instances. The IP addresses are verified by default")
+	fs.BoolVar(&config.mysqlTLSSkipVerify, "mysql-tls-skip-verify", false, "Connect to MySQL instances over TLS without verifying the certificates. Use only for testing")
+	fs.StringSliceVar(&config.failoverWebhookHosts, "failover-webhook-hosts", nil, "Comma-separated list of hosts to which spec.failoverWebhookURL of MySQLClusters is allowed to point. Notifications are disabled by default")
+	fs.BoolVar(&config.clusterSummary, "cluster-summary", false, "Serve a JSON summary of all MySQLClusters at /clusters on the metrics endpoint")
+	fs.BoolVar(&config.eventStream, "event-stream", false, "Stream changes of MySQLClusters as Server-Sent Events at /events on the metrics endpoint")
 	// The default QPS is 20.
 	// https://github.com/kubernetes-sigs/controller-runtime/blob/a26de2d610c3cf4b2a02688534aaf5a65749c743/pkg/client/config/config.go#L84-L85
 	fs.IntVar(&config.qps, "apiserver-qps-throttle", 20, "The maximum QPS to the API server.")
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaxConcurrentReconcilesFlag(t *testing.T) {
+	orig := config.maxConcurrentReconciles
+	defer func() { config.maxConcurrentReconciles = orig }()
+
+	if orig != 8 {
+		t.Errorf("the default of max-concurrent-reconciles = %d, want 8", orig)
+	}
+
+	if err := rootCmd.ParseFlags([]string{"--max-concurrent-reconciles=16"}); err != nil {
+		t.Fatal(err)
+	}
+	if config.maxConcurrentReconciles != 16 {
+		t.Errorf("maxConcurrentReconciles = %d, want 16", config.maxConcurrentReconciles)
+	}
+
+	for _, v := range []string{"0", "-1"} {
+		if err := rootCmd.ParseFlags([]string{"--max-concurrent-reconciles=" + v}); err != nil {
+			t.Fatal(err)
+		}
+		err := rootCmd.RunE(rootCmd, nil)
+		if err == nil || !strings.Contains(err.Error(), "invalid max-concurrent-reconciles") {
+			t.Errorf("max-concurrent-reconciles=%s is not rejected: %v", v, err)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync/atomic"
 	"time"
 
 	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
@@ -16,9 +19,11 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	k8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	// +kubebuilder:scaffold:imports
 )
@@ -52,6 +57,33 @@ func (r resolver) Resolve(ctx context.Context, cluster *mocov1beta2.MySQLCluster
 	return pod.Status.PodIP, nil
 }
 
+// cacheSyncCheck is a readiness check that fails until the cache of the manager is synced.
+// This runs regardless of the leader election as the cache is shared by the webhooks.
+type cacheSyncCheck struct {
+	cache  cache.Cache
+	synced atomic.Bool
+}
+
+var _ manager.LeaderElectionRunnable = &cacheSyncCheck{}
+
+func (c *cacheSyncCheck) Start(ctx context.Context) error {
+	if c.cache.WaitForCacheSync(ctx) {
+		c.synced.Store(true)
+	}
+	return nil
+}
+
+func (c *cacheSyncCheck) NeedLeaderElection() bool {
+	return false
+}
+
+func (c *cacheSyncCheck) Check(_ *http.Request) error {
+	if !c.synced.Load() {
+		return errors.New("the cache is not synced yet")
+	}
+	return nil
+}
+
 func subMain(ns, addr string, port int) error {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&config.zapOpts)))
 	setupLog := ctrl.Log.WithName("setup")
@@ -83,8 +115,24 @@ func subMain(ns, addr string, port int) error {
 		return err
 	}
 
+	factoryCfg := dbop.FactoryConfig{
+		MaxIdleConns: config.mysqlMaxIdleConns,
+		MaxOpenConns: config.mysqlMaxOpenConns,
+
+		SeparateStatusPool: config.mysqlSeparateStatusPool,
+	}
+	if config.mysqlTLSCertDir != "" || config.mysqlTLSSkipVerify {
+		tlsCfg, err := dbop.NewTLSConfig(config.mysqlTLSCertDir, config.mysqlTLSServerName, config.mysqlTLSSkipVerify)
+		if err != nil {
+			setupLog.Error(err, "failed to load MySQL TLS certificates")
+			return err
+		}
+		factoryCfg.TLSConfig = tlsCfg
+	}
+
 	r := resolver{reader: mgr.GetClient()}
-	opf := dbop.NewFactory(r)
+	opf := dbop.NewFactory(r, factoryCfg)
+	// the connection pools are closed after the cluster managers are stopped by the deferred StopAll below.
 	defer opf.Cleanup()
 	reloader, err := cert.NewReloader(config.grpcCertDir, ctrl.Log.WithName("agent-client"))
 	if err != nil {
@@ -92,7 +140,7 @@ func subMain(ns, addr string, port int) error {
 		return err
 	}
 	af := clustering.NewAgentFactory(r, reloader)
-	clusterMgr := clustering.NewClusterManager(config.interval, mgr, opf, af, clusterLog)
+	clusterMgr := clustering.NewClusterManager(config.interval, mgr, opf, af, config.failoverWebhookHosts, clusterLog)
 	defer clusterMgr.StopAll()
 
 	if err = (&controllers.MySQLClusterReconciler{
@@ -138,6 +186,31 @@ func subMain(ns, addr string, port int) error {
 		setupLog.Error(err, "unable to set up ready check")
 		return err
 	}
+	cacheCheck := &cacheSyncCheck{cache: mgr.GetCache()}
+	if err := mgr.Add(cacheCheck); err != nil {
+		setupLog.Error(err, "unable to set up cache sync check")
+		return err
+	}
+	if err := mgr.AddReadyzCheck("cache-sync", cacheCheck.Check); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		return err
+	}
+
+	if config.clusterSummary {
+		if err := mgr.AddMetricsExtraHandler("/clusters", clustering.NewSummaryHandler(mgr.GetClient())); err != nil {
+			setupLog.Error(err, "unable to set up cluster summary endpoint")
+			return err
+		}
+	}
+
+	if config.eventStream {
+		stream := clustering.NewEventStream()
+		clustering.SetEventStream(stream)
+		if err := mgr.AddMetricsExtraHandler("/events", stream); err != nil {
+			setupLog.Error(err, "unable to set up event stream endpoint")
+			return err
+		}
+	}
 
 	metrics.Register(k8smetrics.Registry)
 
@@ -39,7 +39,7 @@ func (r *MySQLClusterReconciler) makeV1MySQLDContainer(cluster *mocov1beta2.MySQ
 		).WithPorts(
 		corev1ac.ContainerPort().
 			WithName(constants.MySQLPortName).
-			WithContainerPort(constants.MySQLPort).
+			WithContainerPort(cluster.MySQLPort()).
 			WithProtocol(corev1.ProtocolTCP),
 		corev1ac.ContainerPort().
 			WithName(constants.MySQLXPortName).WithContainerPort(constants.MySQLXPort).WithProtocol(corev1.ProtocolTCP),
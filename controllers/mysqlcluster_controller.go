@@ -310,6 +310,10 @@ func (r *MySQLClusterReconciler) reconcileV1Secret(ctx context.Context, req ctrl
 			return err
 		}
 
+		if _, err := passwd.AddReplicator(cluster.ReplicationUser()); err != nil {
+			return err
+		}
+
 		secret = passwd.ToSecret()
 		secret.Namespace = r.SystemNamespace
 		secret.Name = name
@@ -323,6 +327,10 @@ func (r *MySQLClusterReconciler) reconcileV1Secret(ctx context.Context, req ctrl
 		return err
 	}
 
+	if err := r.addReplicatorPassword(ctx, cluster, secret); err != nil {
+		return err
+	}
+
 	if err := r.reconcileUserSecret(ctx, req, cluster, secret); err != nil {
 		return err
 	}
@@ -334,6 +342,33 @@ func (r *MySQLClusterReconciler) reconcileV1Secret(ctx context.Context, req ctrl
 	return nil
 }
 
+// addReplicatorPassword adds the password for `spec.replicationUser` to the controller Secret
+// if it is missing, e.g. the Secret was created before `spec.replicationUser` was introduced.
+func (r *MySQLClusterReconciler) addReplicatorPassword(ctx context.Context, cluster *mocov1beta2.MySQLCluster, controllerSecret *corev1.Secret) error {
+	log := crlog.FromContext(ctx)
+
+	passwd, err := password.NewMySQLPasswordFromSecret(controllerSecret)
+	if err != nil {
+		return fmt.Errorf("failed to create password from secret %s/%s: %w", controllerSecret.Namespace, controllerSecret.Name, err)
+	}
+	user := cluster.ReplicationUser()
+	added, err := passwd.AddReplicator(user)
+	if err != nil {
+		return err
+	}
+	if !added {
+		return nil
+	}
+
+	controllerSecret.Data = passwd.ToSecret().Data
+	if err := r.Client.Update(ctx, controllerSecret); err != nil {
+		return fmt.Errorf("failed to add the password of %s to secret %s/%s: %w", user, controllerSecret.Namespace, controllerSecret.Name, err)
+	}
+
+	log.Info("added the password of the replication user", "secretName", controllerSecret.Name, "user", user)
+	return nil
+}
+
 func (r *MySQLClusterReconciler) reconcileUserSecret(ctx context.Context, req ctrl.Request, cluster *mocov1beta2.MySQLCluster, controllerSecret *corev1.Secret) error {
 	log := crlog.FromContext(ctx)
 
@@ -443,7 +478,7 @@ func (r *MySQLClusterReconciler) reconcileV1MyCnf(ctx context.Context, req ctrl.
 		userConf = cm.Data
 	}
 
-	conf := mycnf.Generate(userConf, totalMem)
+	conf := mycnf.Generate(userConf, totalMem, cluster.MySQLPort())
 
 	fnv32a := fnv.New32a()
 	fnv32a.Write([]byte(conf))
@@ -619,7 +654,7 @@ func (r *MySQLClusterReconciler) reconcileV1Service1(ctx context.Context, cluste
 		corev1ac.ServicePort().
 			WithName(constants.MySQLPortName).
 			WithProtocol(corev1.ProtocolTCP).
-			WithPort(constants.MySQLPort).
+			WithPort(cluster.MySQLPort()).
 			WithTargetPort(intstr.FromString(constants.MySQLPortName)),
 		corev1ac.ServicePort().
 			WithName(constants.MySQLXPortName).
@@ -1676,6 +1711,11 @@ func (r *MySQLClusterReconciler) updateStatus(ctx context.Context, cluster *moco
 
 	if !equality.Semantic.DeepEqual(orig, cluster) {
 		if err := r.Status().Update(ctx, cluster); err != nil {
+			if apierrors.IsNotFound(err) {
+				// the cluster has been deleted during the reconciliation.
+				log.Info("MySQLCluster is not found")
+				return nil
+			}
 			return err
 		}
 		log.Info("update status successfully")
@@ -1932,8 +1972,12 @@ func (r *MySQLClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(certificateObj, certHandler).
 		Watches(&corev1.ConfigMap{}, configMapHandler).
 		Watches(&mocov1beta2.BackupPolicy{}, backupPolicyHandler).
-		WithOptions(
-			controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles},
-		).
+		WithOptions(r.controllerOptions()).
 		Complete(r)
 }
+
+// controllerOptions returns the options of the controller.
+// MaxConcurrentReconciles is given by `--max-concurrent-reconciles` flag of moco-controller.
+func (r *MySQLClusterReconciler) controllerOptions() controller.Options {
+	return controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}
+}
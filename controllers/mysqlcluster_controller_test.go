@@ -209,6 +209,34 @@ var _ = Describe("MySQLCluster reconciler", func() {
 		}).Should(Succeed())
 	})
 
+	It("should generate the password of the replication user", func() {
+		cluster := testNewMySQLCluster("test")
+		cluster.Spec.ReplicationUser = "tenant_repl"
+		err := k8sClient.Create(ctx, cluster)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() error {
+			secret := &corev1.Secret{}
+			key := client.ObjectKey{Namespace: testMocoSystemNamespace, Name: "mysql-test.test"}
+			if err := k8sClient.Get(ctx, key, secret); err != nil {
+				return err
+			}
+			if len(secret.Data["REPLICATION_PASSWORD_tenant_repl"]) == 0 {
+				return fmt.Errorf("the controller secret does not have the password of tenant_repl")
+			}
+
+			userSecret := &corev1.Secret{}
+			key = client.ObjectKey{Namespace: "test", Name: "moco-test"}
+			if err := k8sClient.Get(ctx, key, userSecret); err != nil {
+				return err
+			}
+			if string(userSecret.Data["REPLICATION_PASSWORD_tenant_repl"]) != string(secret.Data["REPLICATION_PASSWORD_tenant_repl"]) {
+				return fmt.Errorf("the user secret does not have the password of tenant_repl")
+			}
+			return nil
+		}).Should(Succeed())
+	})
+
 	It("should update user secret", func() {
 		cluster := testNewMySQLCluster("test")
 		err := k8sClient.Create(ctx, cluster)
@@ -0,0 +1,15 @@
+package controllers
+
+import "testing"
+
+func TestControllerOptions(t *testing.T) {
+	r := &MySQLClusterReconciler{MaxConcurrentReconciles: 3}
+	if got := r.controllerOptions().MaxConcurrentReconciles; got != 3 {
+		t.Errorf("MaxConcurrentReconciles of MySQLClusterReconciler = %d, want 3", got)
+	}
+
+	w := &PodWatcher{MaxConcurrentReconciles: 5}
+	if got := w.controllerOptions().MaxConcurrentReconciles; got != 5 {
+		t.Errorf("MaxConcurrentReconciles of PodWatcher = %d, want 5", got)
+	}
+}
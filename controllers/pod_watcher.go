@@ -85,8 +85,12 @@ func (r *PodWatcher) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 func (r *PodWatcher) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Pod{}).
-		WithOptions(
-			controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles},
-		).
+		WithOptions(r.controllerOptions()).
 		Complete(r)
 }
+
+// controllerOptions returns the options of the controller.
+// MaxConcurrentReconciles is given by `--max-concurrent-reconciles` flag of moco-controller.
+func (r *PodWatcher) controllerOptions() controller.Options {
+	return controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUpdateStatusNotFound(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add scheme: %v", err)
+	}
+	if err := mocov1beta2.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to add scheme: %v", err)
+	}
+
+	// the cluster is not registered to the client as if it has been deleted.
+	cluster := &mocov1beta2.MySQLCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "mysql-cluster",
+			Namespace: "default",
+		},
+	}
+	r := &MySQLClusterReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+
+	if err := r.updateStatus(context.Background(), cluster, nil); err != nil {
+		t.Errorf("updateStatus() error = %v", err)
+	}
+	if err := r.updateStatus(context.Background(), cluster, errors.New("reconcile failed")); err != nil {
+		t.Errorf("updateStatus() error = %v", err)
+	}
+}
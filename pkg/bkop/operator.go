@@ -12,12 +12,13 @@ import (
 // Operator is the interface to define backup and restore operations.
 type Operator interface {
 	// Ping checks the connectivity to the database server.
-	Ping() error
+	Ping(context.Context) error
 
 	// Close ust be called when the operator is no longer in use.
 	Close()
 
 	// GetServerStatus fills ServerStatus struct.
+	// If the binary logging is disabled, this returns an error wrapping ErrBinlogDisabled.
 	GetServerStatus(context.Context, *ServerStatus) error
 
 	// DumpFull takes a full dump of the database instance.
@@ -76,8 +77,8 @@ func NewOperator(host string, port int, user, password string, threads int) (Ope
 	return operator{db, host, port, user, password, threads}, nil
 }
 
-func (o operator) Ping() error {
-	return o.db.Ping()
+func (o operator) Ping(ctx context.Context) error {
+	return o.db.PingContext(ctx)
 }
 
 func (o operator) Close() {
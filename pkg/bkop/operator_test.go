@@ -67,9 +67,9 @@ var _ = Describe("Operator", func() {
 		opRe, err = NewOperator("localhost", 2290, constants.AdminUser, restorePwd.Admin(), 1)
 		Expect(err).NotTo(HaveOccurred())
 
-		err = opBk.Ping()
+		err = opBk.Ping(ctx)
 		Expect(err).NotTo(HaveOccurred())
-		err = opRe.Ping()
+		err = opRe.Ping(ctx)
 		Expect(err).NotTo(HaveOccurred())
 
 		opRe.(operator).db.MustExec(`SET GLOBAL read_only=0`)
@@ -2,12 +2,23 @@ package bkop
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 )
 
+// ErrBinlogDisabled is returned by GetServerStatus when the binary logging
+// is disabled on the instance.  Unlike connection failures, this is a
+// configuration problem and retrying does not help.
+var ErrBinlogDisabled = errors.New("binary logging is disabled")
+
 func (o operator) GetServerStatus(ctx context.Context, st *ServerStatus) error {
 	ms := &showMasterStatus{}
 	if err := o.db.GetContext(ctx, ms, `SHOW MASTER STATUS`); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// SHOW MASTER STATUS returns no rows if log_bin is OFF.
+			return fmt.Errorf("%w: SHOW MASTER STATUS returned no rows", ErrBinlogDisabled)
+		}
 		return fmt.Errorf("failed to show master status: %w", err)
 	}
 
@@ -17,8 +17,13 @@ const (
 
 // annotation keys and values
 const (
-	AnnDemote        = "moco.cybozu.com/demote"
-	AnnSecretVersion = "moco.cybozu.com/secret-version"
+	AnnDemote           = "moco.cybozu.com/demote"
+	AnnSecretVersion    = "moco.cybozu.com/secret-version"
+	AnnSwitchover       = "moco.cybozu.com/switchover"
+	AnnFailoverPriority = "moco.cybozu.com/failover-priority"
+
+	AnnExcludeFromPrimary = "moco.cybozu.com/exclude-from-primary"
+	AnnForceFailover      = "moco.cybozu.com/force-failover"
 )
 
 // MySQLClusterFinalizer is the finalizer specifier for MySQLCluster.
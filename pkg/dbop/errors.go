@@ -7,4 +7,14 @@ var (
 	ErrErrantTransactions = errors.New("detected errant transactions")
 	ErrNoTopRunner        = errors.New("unable to determine the top runner")
 	ErrTimeout            = errors.New("timeout")
+
+	// The following errors are returned from `GetStatus` together with
+	// the underlying error to tell which query failed.
+	ErrBeginTx         = errors.New("failed to begin a transaction")
+	ErrGlobalVariables = errors.New("failed to get global variables")
+	ErrReplicaHosts    = errors.New("failed to get slave hosts")
+	ErrReplicaStatus   = errors.New("failed to get replica status")
+	ErrCloneStatus     = errors.New("failed to get clone status")
+	ErrGlobalStatus    = errors.New("failed to get global status")
+	ErrInnoDBStatus    = errors.New("failed to get InnoDB transactions")
 )
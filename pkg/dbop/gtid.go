@@ -8,8 +8,19 @@ import (
 // FindTopRunner returns the index of the slice whose `GlobalVariables.ExecutedGtidSet`
 // is most advanced.  This may return ErrErrantTransactions for errant transactions
 // or ErrNoTopRunner if there is no such instance.
+// If multiple instances are equally advanced, the lowest index is returned.
 func FindTopRunner(ctx context.Context, o Operator, status []*MySQLInstanceStatus) (int, error) {
-	latest := -1
+	top, err := FindTopRunners(ctx, o, status)
+	if err != nil {
+		return -1, err
+	}
+	return top[0], nil
+}
+
+// FindTopRunners is the same as FindTopRunner except that this returns the indices
+// of all instances that are equally most advanced in ascending order.
+func FindTopRunners(ctx context.Context, o Operator, status []*MySQLInstanceStatus) ([]int, error) {
+	var latest []int
 	var latestGTIDs string
 
 	for i := 0; i < len(status); i++ {
@@ -37,34 +48,33 @@ func FindTopRunner(ctx context.Context, o Operator, status []*MySQLInstanceStatu
 		}
 
 		if len(latestGTIDs) == 0 {
-			latest = i
+			latest = []int{i}
 			latestGTIDs = gtids
 			continue
 		}
 
 		isSubset, err := o.IsSubsetGTID(ctx, gtids, latestGTIDs)
 		if err != nil {
-			return -1, err
-		}
-		if isSubset {
-			continue
+			return nil, err
 		}
-
-		isSubset, err = o.IsSubsetGTID(ctx, latestGTIDs, gtids)
+		isSuperset, err := o.IsSubsetGTID(ctx, latestGTIDs, gtids)
 		if err != nil {
-			return -1, err
+			return nil, err
 		}
-		if isSubset {
-			latest = i
+		switch {
+		case isSubset && isSuperset:
+			latest = append(latest, i)
+		case isSubset:
+		case isSuperset:
+			latest = []int{i}
 			latestGTIDs = gtids
-			continue
+		default:
+			return nil, fmt.Errorf("%w: set1=%s, set2=%s", ErrErrantTransactions, gtids, latestGTIDs)
 		}
-
-		return -1, fmt.Errorf("%w: set1=%s, set2=%s", ErrErrantTransactions, gtids, latestGTIDs)
 	}
 
-	if latest == -1 {
-		return -1, ErrNoTopRunner
+	if len(latest) == 0 {
+		return nil, ErrNoTopRunner
 	}
 
 	return latest, nil
@@ -72,7 +82,7 @@ func FindTopRunner(ctx context.Context, o Operator, status []*MySQLInstanceStatu
 
 func (o *operator) IsSubsetGTID(ctx context.Context, set1, set2 string) (bool, error) {
 	var ret bool
-	if err := o.db.GetContext(ctx, &ret, `SELECT GTID_SUBSET(?,?)`, set1, set2); err != nil {
+	if err := o.getContext(ctx, o.db, &ret, `SELECT GTID_SUBSET(?,?)`, set1, set2); err != nil {
 		return false, fmt.Errorf("failed to get gtid_subset(%s, %s): %w", set1, set2, err)
 	}
 	return ret, nil
@@ -80,7 +90,7 @@ func (o *operator) IsSubsetGTID(ctx context.Context, set1, set2 string) (bool, e
 
 func (o *operator) SubtractGTID(ctx context.Context, set1, set2 string) (string, error) {
 	var ret string
-	if err := o.db.GetContext(ctx, &ret, `SELECT GTID_SUBTRACT(?,?)`, set1, set2); err != nil {
+	if err := o.getContext(ctx, o.db, &ret, `SELECT GTID_SUBTRACT(?,?)`, set1, set2); err != nil {
 		return "", fmt.Errorf("failed to get gtid_subtract(%s, %s): %w", set1, set2, err)
 	}
 	return ret, nil
@@ -51,6 +51,17 @@ var _ = Describe("FindTopRunner", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(top).To(Equal(2))
 
+		// equally advanced instances
+		statuses[0] = &MySQLInstanceStatus{ReplicaStatus: &ReplicaStatus{RetrievedGtidSet: set2}}
+		statuses[1] = &MySQLInstanceStatus{ReplicaStatus: &ReplicaStatus{RetrievedGtidSet: set1}}
+		statuses[2] = &MySQLInstanceStatus{ReplicaStatus: &ReplicaStatus{RetrievedGtidSet: set2}}
+		top, err = FindTopRunner(context.Background(), op, statuses)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(top).To(Equal(0))
+		tops, err := FindTopRunners(context.Background(), op, statuses)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tops).To(Equal([]int{0, 2}))
+
 		// errant transactions
 		set0 = `8e349184-bc14-11e3-8d4c-0800272864ba:1-30,
 8e3648e4-bc14-11e3-8d4c-0800272864ba:1-7`
@@ -0,0 +1,185 @@
+package dbop
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gtidInterval is a closed interval of transaction numbers.
+type gtidInterval struct {
+	start, end uint64
+}
+
+// GTIDSet is a parsed GTID set.
+// The keys are source UUIDs in lower case, followed by `:<tag>` for tagged GTIDs.
+// The intervals of each key are sorted and merged so that two sets with the same
+// transactions are equal regardless of how they are formatted.
+//
+// Use ParseGTIDSet to create one.  The zero value is an empty set.
+type GTIDSet map[string][]gtidInterval
+
+// ParseGTIDSet parses a GTID set such as `@@gtid_executed`.
+// Whitespace including newlines, the order of UUIDs and intervals, and overlapping
+// or adjacent intervals are allowed.
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	set := make(GTIDSet)
+	for _, elem := range strings.Split(s, ",") {
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			continue
+		}
+		parts := strings.Split(elem, ":")
+		uuid := strings.ToLower(strings.TrimSpace(parts[0]))
+		if !isUUID(uuid) {
+			return nil, fmt.Errorf("invalid UUID in GTID set %q: %s", s, parts[0])
+		}
+		if len(parts) == 1 {
+			return nil, fmt.Errorf("no transactions for %s in GTID set %q", uuid, s)
+		}
+
+		key := uuid
+		for _, part := range parts[1:] {
+			part = strings.TrimSpace(part)
+			if part == "" || part[0] < '0' || part[0] > '9' {
+				// MySQL 8.3 or later can tag GTIDs.  The tag applies to the following intervals.
+				if part == "" {
+					return nil, fmt.Errorf("empty interval for %s in GTID set %q", uuid, s)
+				}
+				key = uuid + ":" + strings.ToLower(part)
+				continue
+			}
+			iv, err := parseGTIDInterval(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid interval for %s in GTID set %q: %w", uuid, s, err)
+			}
+			set[key] = append(set[key], iv)
+		}
+	}
+	for key, ivs := range set {
+		set[key] = mergeGTIDIntervals(ivs)
+	}
+	return set, nil
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func parseGTIDInterval(s string) (gtidInterval, error) {
+	startStr, endStr, isRange := strings.Cut(s, "-")
+	start, err := strconv.ParseUint(strings.TrimSpace(startStr), 10, 64)
+	if err != nil {
+		return gtidInterval{}, err
+	}
+	end := start
+	if isRange {
+		end, err = strconv.ParseUint(strings.TrimSpace(endStr), 10, 64)
+		if err != nil {
+			return gtidInterval{}, err
+		}
+	}
+	if start == 0 || end < start {
+		return gtidInterval{}, fmt.Errorf("bad interval %s", s)
+	}
+	return gtidInterval{start: start, end: end}, nil
+}
+
+// mergeGTIDIntervals sorts `ivs` and merges overlapping or adjacent intervals.
+func mergeGTIDIntervals(ivs []gtidInterval) []gtidInterval {
+	sort.Slice(ivs, func(i, j int) bool { return ivs[i].start < ivs[j].start })
+	merged := ivs[:1]
+	for _, iv := range ivs[1:] {
+		last := &merged[len(merged)-1]
+		if iv.start <= last.end+1 {
+			if iv.end > last.end {
+				last.end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// Contains returns true if `other` is a subset of `s`.
+func (s GTIDSet) Contains(other GTIDSet) bool {
+	for key, ivs := range other {
+		for _, iv := range ivs {
+			if !containsGTIDInterval(s[key], iv) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// containsGTIDInterval returns true if `iv` is covered by the merged intervals `ivs`.
+// As `ivs` are merged, `iv` must be covered by a single interval.
+func containsGTIDInterval(ivs []gtidInterval, iv gtidInterval) bool {
+	i := sort.Search(len(ivs), func(i int) bool { return ivs[i].end >= iv.end })
+	return i < len(ivs) && ivs[i].start <= iv.start
+}
+
+// Equal returns true if `s` and `other` have the same transactions.
+func (s GTIDSet) Equal(other GTIDSet) bool {
+	return s.Contains(other) && other.Contains(s)
+}
+
+// String returns the set in a normalized format, i.e. sorted by UUID without whitespace.
+// Tagged GTIDs are formatted as separate elements such as `<uuid>:<tag>:1-5`.
+func (s GTIDSet) String() string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	elems := make([]string, 0, len(keys))
+	for _, key := range keys {
+		var sb strings.Builder
+		sb.WriteString(key)
+		for _, iv := range s[key] {
+			if iv.start == iv.end {
+				fmt.Fprintf(&sb, ":%d", iv.start)
+			} else {
+				fmt.Fprintf(&sb, ":%d-%d", iv.start, iv.end)
+			}
+		}
+		elems = append(elems, sb.String())
+	}
+	return strings.Join(elems, ",")
+}
+
+// EqualGTIDSets returns true if the GTID sets `set1` and `set2` have the same transactions.
+// If either of them cannot be parsed, this falls back to comparing them as strings.
+func EqualGTIDSets(set1, set2 string) bool {
+	if set1 == set2 {
+		return true
+	}
+	s1, err := ParseGTIDSet(set1)
+	if err != nil {
+		return false
+	}
+	s2, err := ParseGTIDSet(set2)
+	if err != nil {
+		return false
+	}
+	return s1.Equal(s2)
+}
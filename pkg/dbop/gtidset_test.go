@@ -0,0 +1,65 @@
+package dbop
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GTIDSet", func() {
+	const (
+		uuid1 = "8e349184-bc14-11e3-8d4c-0800272864ba"
+		uuid2 = "8e349184-bc14-11e3-8d4c-0800272864bb"
+	)
+
+	It("should parse and normalize GTID sets", func() {
+		set, err := ParseGTIDSet("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(set).To(BeEmpty())
+		Expect(set.String()).To(Equal(""))
+
+		set, err = ParseGTIDSet(uuid2 + ":7:1-3:5-6:4, \n" + "8E349184-BC14-11E3-8D4C-0800272864BA:10-20:1-15")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(set.String()).To(Equal(uuid1 + ":1-20," + uuid2 + ":1-7"))
+
+		By("parsing tagged GTIDs")
+		set, err = ParseGTIDSet(uuid1 + ":1-5:Tag1:1-3:4,\n" + uuid1 + ":6")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(set.String()).To(Equal(uuid1 + ":1-6," + uuid1 + ":tag1:1-4"))
+
+		By("rejecting broken sets")
+		for _, s := range []string{
+			"p0:1",
+			uuid1,
+			uuid1 + ":0",
+			uuid1 + ":5-3",
+			uuid1 + ":1-x",
+			uuid1 + "::1",
+		} {
+			_, err := ParseGTIDSet(s)
+			Expect(err).To(HaveOccurred(), s)
+		}
+	})
+
+	It("should compare GTID sets", func() {
+		parse := func(s string) GTIDSet {
+			set, err := ParseGTIDSet(s)
+			Expect(err).NotTo(HaveOccurred())
+			return set
+		}
+
+		all := parse(uuid1 + ":1-10:20-30," + uuid2 + ":1-5")
+		Expect(all.Contains(parse(uuid1 + ":3-7:25"))).To(BeTrue())
+		Expect(all.Contains(parse(""))).To(BeTrue())
+		Expect(all.Contains(parse(uuid1 + ":9-21"))).To(BeFalse())
+		Expect(all.Contains(parse(uuid2 + ":6"))).To(BeFalse())
+		Expect(parse("").Contains(all)).To(BeFalse())
+
+		Expect(all.Equal(parse(uuid2 + ":1-3:4-5," + uuid1 + ":20-30:1-10"))).To(BeTrue())
+		Expect(all.Equal(parse(uuid1 + ":1-10:20-30"))).To(BeFalse())
+
+		Expect(EqualGTIDSets(uuid1+":1-3", uuid1+":1:2:3")).To(BeTrue())
+		Expect(EqualGTIDSets(uuid1+":1-3", uuid1+":1-4")).To(BeFalse())
+		Expect(EqualGTIDSets("p0:1", "p0:1")).To(BeTrue())
+		Expect(EqualGTIDSets("p0:1", "p0:1,p0:2")).To(BeFalse())
+	})
+})
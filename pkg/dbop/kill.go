@@ -12,19 +12,19 @@ import (
 func (o *operator) KillConnections(ctx context.Context) error {
 	var procs []Process
 
-	if err := o.db.SelectContext(ctx, &procs, `SELECT ID, USER, HOST FROM information_schema.PROCESSLIST`); err != nil {
+	if err := o.selectContext(ctx, o.db, &procs, `SELECT ID, USER, HOST FROM information_schema.PROCESSLIST`); err != nil {
 		return fmt.Errorf("failed to get process list: %w", err)
 	}
 
 	for _, p := range procs {
-		if constants.MocoSystemUsers[p.User] {
+		if constants.MocoSystemUsers[p.User] || p.User == o.replicationUser {
 			continue
 		}
 		if p.Host == "localhost" {
 			continue
 		}
 
-		if _, err := o.db.ExecContext(ctx, `KILL CONNECTION ?`, p.ID); err != nil && !isNoSuchThread(err) {
+		if _, err := o.execContext(ctx, `KILL CONNECTION ?`, p.ID); err != nil && !isNoSuchThread(err) {
 			return fmt.Errorf("failed to kill connection %d for %s from %s: %w", p.ID, p.User, p.Host, err)
 		}
 	}
@@ -39,7 +39,7 @@ func (o NopOperator) ConfigureReplica(ctx context.Context, source AccessInfo, se
 	return ErrNop
 }
 
-func (o NopOperator) ConfigurePrimary(ctx context.Context, waitForCount int) error {
+func (o NopOperator) ConfigurePrimary(ctx context.Context, waitForCount, timeoutMilliseconds int) error {
 	return ErrNop
 }
 
@@ -47,6 +47,18 @@ func (o NopOperator) StopReplicaIOThread(context.Context) error {
 	return ErrNop
 }
 
+func (o NopOperator) StartReplicaSQLThread(context.Context) error {
+	return ErrNop
+}
+
+func (o NopOperator) StartReplicaIOThread(context.Context) error {
+	return ErrNop
+}
+
+func (o NopOperator) ResetReplica(context.Context) error {
+	return ErrNop
+}
+
 func (o NopOperator) WaitForGTID(ctx context.Context, gtidSet string, timeoutSeconds int) error {
 	return ErrNop
 }
@@ -58,3 +70,23 @@ func (o NopOperator) SetReadOnly(context.Context, bool) error {
 func (o NopOperator) KillConnections(context.Context) error {
 	return ErrNop
 }
+
+func (o NopOperator) GetDataSize(context.Context) (int64, error) {
+	return 0, ErrNop
+}
+
+func (o NopOperator) CheckHealth(ctx context.Context, query string) (bool, error) {
+	return false, ErrNop
+}
+
+func (o NopOperator) EnsureTable(ctx context.Context, database, table, definition string) error {
+	return ErrNop
+}
+
+func (o NopOperator) EnsureReplicationUser(ctx context.Context, user, password string) error {
+	return ErrNop
+}
+
+func (o NopOperator) CommandCounts() CommandCounts {
+	return CommandCounts{}
+}
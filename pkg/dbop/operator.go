@@ -2,9 +2,15 @@ package dbop
 
 import (
 	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
@@ -20,6 +26,14 @@ const (
 	readTimeout = 1 * time.Minute
 )
 
+// statusTimeout is the timeout to gather the instance status.
+// Gathering should fail fast whereas operations such as `CHANGE MASTER TO`
+// are allowed to take up to readTimeout.
+var statusTimeout = 10 * time.Second
+
+// healthCheckTimeout is the timeout of `spec.replicaHealthCheckSQL`.
+var healthCheckTimeout = 5 * time.Second
+
 // Operator represents a set of operations for a MySQL instance.
 type Operator interface {
 	// Name is the name of the MySQL instance for which this operator works.
@@ -43,29 +57,88 @@ type Operator interface {
 	ConfigureReplica(ctx context.Context, source AccessInfo, semisync bool) error
 
 	// ConfigurePrimary configures server-side semi-synchronous replication.
+	// If `waitForCount` is zero, it disables server-side semi-synchronous replication
+	// for a primary that has no replicas.
+	// `timeoutMilliseconds` is set to `rpl_semi_sync_master_timeout`.
 	// For asynchronous replication, this method should not be called.
-	ConfigurePrimary(ctx context.Context, waitForCount int) error
+	ConfigurePrimary(ctx context.Context, waitForCount, timeoutMilliseconds int) error
 
 	// StopReplicaIOThread executes `STOP SLAVE IO_THREAD`.
 	StopReplicaIOThread(context.Context) error
 
+	// StartReplicaSQLThread executes `START SLAVE SQL_THREAD`.
+	StartReplicaSQLThread(context.Context) error
+
+	// StartReplicaIOThread executes `START SLAVE IO_THREAD`.
+	StartReplicaIOThread(context.Context) error
+
+	// ResetReplica executes `STOP SLAVE`, `RESET SLAVE`, and `START SLAVE`.
+	// This discards the relay logs and fetches the transactions again from the source.
+	// The connection parameters of the replication are kept.
+	ResetReplica(context.Context) error
+
 	// WaitForGTID waits for `mysqld` to execute all GTIDs in `gtidSet`.
 	// If timeout happens, this return ErrTimeout.
 	// If `timeoutSeconds` is zero, this will not timeout.
 	WaitForGTID(ctx context.Context, gtidSet string, timeoutSeconds int) error
 
 	// SetReadOnly makes the instance super_read_only if `true` is passed.
-	// Otherwise, this stops the replication and makes the instance writable.
+	// Otherwise, this stops the replication and makes the instance writable
+	// by turning off both `super_read_only` and `read_only`.
 	SetReadOnly(context.Context, bool) error
 
+	// GetDataSize returns the total size of tables and indexes in bytes.
+	// This approximates the size of data copied by `CLONE INSTANCE`.
+	GetDataSize(context.Context) (int64, error)
+
 	// KillConnections kills all connections except for ones from `localhost`
 	// and ones for MOCO.
 	KillConnections(context.Context) error
+
+	// CheckHealth runs `query` in a read-only transaction and returns its boolean result.
+	// `query` is an arbitrary statement given by users, so it is run as the read-only user
+	// and cancelled after a short timeout.
+	CheckHealth(ctx context.Context, query string) (bool, error)
+
+	// EnsureTable creates a table and its database if they do not exist.
+	EnsureTable(ctx context.Context, database, table, definition string) error
+
+	// EnsureReplicationUser creates a replication user if it does not exist.
+	// The password of the user is updated if it exists.
+	EnsureReplicationUser(ctx context.Context, user, password string) error
+
+	// CommandCounts returns the number of SQL commands issued by this operator.
+	CommandCounts() CommandCounts
+}
+
+// CommandCounts represents the number of SQL commands issued to an instance.
+type CommandCounts struct {
+	// Query is the number of commands that return rows.
+	Query int
+
+	// Exec is the number of commands that do not return rows.
+	Exec int
+}
+
+// Total returns the total number of commands.
+func (c CommandCounts) Total() int {
+	return c.Query + c.Exec
 }
 
 // OperatorFactory represents the factory for Operators.
 type OperatorFactory interface {
 	New(context.Context, *mocov1beta2.MySQLCluster, *password.MySQLPassword, int) (Operator, error)
+
+	// Remove closes and drops the connections to `host` kept by the factory, if any.
+	Remove(host string)
+
+	// RemoveCluster closes and drops the connections to the instances of the cluster
+	// whose index is `replicas` or larger.  If `replicas` is zero, the connections
+	// to all the instances of the cluster are dropped.
+	RemoveCluster(namespace, name string, replicas int)
+
+	// Cleanup closes all the connections kept by the factory.
+	// It is safe to call Cleanup more than once.  Operators cannot be created after Cleanup.
 	Cleanup()
 }
 
@@ -73,56 +146,256 @@ type Resolver interface {
 	Resolve(context.Context, *mocov1beta2.MySQLCluster, int) (string, error)
 }
 
+// FactoryConfig is the configuration of the connection pools of Operators.
+type FactoryConfig struct {
+	// MaxIdleConns is the maximum number of idle connections to an instance.
+	// If this is zero or negative, no idle connections are retained.
+	MaxIdleConns int
+
+	// MaxOpenConns is the maximum number of open connections to an instance.
+	// If this is zero or negative, the number of connections is not limited.
+	MaxOpenConns int
+
+	// SeparateStatusPool makes Operators gather the instance status through a pool
+	// separated from the one for the other operations such as failovers, so that a slow
+	// status gathering cannot starve them.  Each pool is limited by the above settings.
+	SeparateStatusPool bool
+
+	// TLSConfig is the TLS configuration to connect to the instances.
+	// If this is nil, the connections are not encrypted.
+	// Use NewTLSConfig to create one from a mounted Secret.
+	TLSConfig *tls.Config
+}
+
+// DefaultFactoryConfig is the default configuration of NewFactory.
+var DefaultFactoryConfig = FactoryConfig{
+	MaxIdleConns: 1,
+}
+
 type defaultFactory struct {
-	r Resolver
+	r   Resolver
+	cfg FactoryConfig
+
+	mu      sync.Mutex
+	dbs     map[string]*cachedDB
+	tlsName string
+	closed  bool
+}
+
+// cachedDB is a connection pool shared among Operators for the same instance.
+type cachedDB struct {
+	db      *sqlx.DB
+	host    string
+	pod     string
+	cluster string
+	index   int
+	dsn     string
 }
 
-var _ OperatorFactory = defaultFactory{}
+var _ OperatorFactory = &defaultFactory{}
 
 // NewFactory returns a new OperatorFactory that resolves instance IP address using `r`.
 // If `r.Resolve` returns an error, the `New` method will return a NopOperator.
-func NewFactory(r Resolver) OperatorFactory {
-	return defaultFactory{r: r}
+// The connection pool of each Operator is limited by `cfg`.
+//
+// The connection pools are cached per host and user, and reused by the Operators
+// as long as the credentials are the same.  Closing an Operator does not close
+// the cached pool; call `Remove` or `Cleanup` to close them.
+//
+// If `cfg.TLSConfig` is not nil, the Operators connect to the instances over TLS.
+func NewFactory(r Resolver, cfg FactoryConfig) OperatorFactory {
+	return &defaultFactory{
+		r:   r,
+		cfg: cfg,
+		dbs: make(map[string]*cachedDB),
+	}
 }
 
-func (f defaultFactory) New(ctx context.Context, cluster *mocov1beta2.MySQLCluster, pwd *password.MySQLPassword, index int) (Operator, error) {
+func (f *defaultFactory) New(ctx context.Context, cluster *mocov1beta2.MySQLCluster, pwd *password.MySQLPassword, index int) (Operator, error) {
 	addr, err := f.r.Resolve(ctx, cluster, index)
 	if err != nil {
 		return NopOperator{name: fmt.Sprintf("%s/%s", cluster.Namespace, cluster.PodName(index))}, nil
 	}
 
+	cfg := newConfig(cluster, pwd, net.JoinHostPort(addr, strconv.Itoa(constants.MySQLAdminPort)))
+	db, err := f.getDB(cluster, index, addr, "", cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", cluster.PodName(index), err)
+	}
+	statusDB := db
+	if f.cfg.SeparateStatusPool {
+		statusDB, err = f.getDB(cluster, index, addr, "status", newConfig(cluster, pwd, cfg.Addr))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s for the status: %w", cluster.PodName(index), err)
+		}
+	}
+	var readOnlyDB *sqlx.DB
+	if cluster.Spec.ReplicaHealthCheckSQL != "" {
+		rcfg := newConfig(cluster, pwd, cfg.Addr)
+		rcfg.User = constants.ReadOnlyUser
+		rcfg.Passwd = pwd.ReadOnly()
+		readOnlyDB, err = f.getDB(cluster, index, addr, "", rcfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s as %s: %w", cluster.PodName(index), constants.ReadOnlyUser, err)
+		}
+	}
+	return &operator{
+		namespace:  cluster.Namespace,
+		name:       cluster.PodName(index),
+		passwd:     pwd,
+		index:      index,
+		db:         db,
+		statusDB:   statusDB,
+		readOnlyDB: readOnlyDB,
+		shared:     true,
+
+		replicationUser:  cluster.ReplicationUser(),
+		maxExecutionTime: maxExecutionTime(cluster),
+	}, nil
+}
+
+// getDB returns the cached connection pool for `cfg` and `pool` or opens a new one.
+// `pool` distinguishes the pools of the same user for different purposes.
+// The cached pool is replaced if the credentials have been changed, and pools to
+// the previous address of `pod` are closed as the pod has been re-created.
+// Pools of other users to the current address are kept.
+func (f *defaultFactory) getDB(cluster *mocov1beta2.MySQLCluster, index int, host, pool string, cfg *mysql.Config) (*sqlx.DB, error) {
+	key := cfg.Addr + "/" + cfg.User
+	if pool != "" {
+		key += "/" + pool
+	}
+	pod := fmt.Sprintf("%s/%s", cluster.Namespace, cluster.PodName(index))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, errors.New("the factory has been cleaned up")
+	}
+	if f.cfg.TLSConfig != nil {
+		if f.tlsName == "" {
+			name, err := registerTLSConfig(f.cfg.TLSConfig)
+			if err != nil {
+				return nil, fmt.Errorf("failed to register the TLS config: %w", err)
+			}
+			f.tlsName = name
+		}
+		cfg.TLSConfig = f.tlsName
+	}
+	dsn := cfg.FormatDSN()
+	if c, ok := f.dbs[key]; ok {
+		if c.dsn == dsn && c.pod == pod {
+			return c.db, nil
+		}
+		c.db.Close()
+		delete(f.dbs, key)
+	}
+	for k, c := range f.dbs {
+		if c.pod == pod && c.host != host {
+			c.db.Close()
+			delete(f.dbs, k)
+		}
+	}
+
+	db, err := sqlx.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxIdleConns(f.cfg.MaxIdleConns)
+	db.SetMaxOpenConns(f.cfg.MaxOpenConns)
+	db.SetConnMaxIdleTime(30 * time.Second)
+	f.dbs[key] = &cachedDB{
+		db:      db,
+		host:    host,
+		pod:     pod,
+		cluster: cluster.Namespace + "/" + cluster.Name,
+		index:   index,
+		dsn:     dsn,
+	}
+	return db, nil
+}
+
+func (f *defaultFactory) Remove(host string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for k, c := range f.dbs {
+		if c.host == host {
+			c.db.Close()
+			delete(f.dbs, k)
+		}
+	}
+}
+
+func (f *defaultFactory) RemoveCluster(namespace, name string, replicas int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := namespace + "/" + name
+	for k, c := range f.dbs {
+		if c.cluster == key && c.index >= replicas {
+			c.db.Close()
+			delete(f.dbs, k)
+		}
+	}
+}
+
+func (f *defaultFactory) Cleanup() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for k, c := range f.dbs {
+		c.db.Close()
+		delete(f.dbs, k)
+	}
+	if f.tlsName != "" {
+		mysql.DeregisterTLSConfig(f.tlsName)
+		f.tlsName = ""
+	}
+}
+
+// newConfig returns the connection configuration of an operator for `addr`.
+func newConfig(cluster *mocov1beta2.MySQLCluster, pwd *password.MySQLPassword, addr string) *mysql.Config {
 	cfg := mysql.NewConfig()
 	cfg.User = constants.AdminUser
 	cfg.Passwd = pwd.Admin()
 	cfg.Net = "tcp"
-	cfg.Addr = net.JoinHostPort(addr, strconv.Itoa(constants.MySQLAdminPort))
+	cfg.Addr = addr
 	cfg.InterpolateParams = true
 	cfg.ParseTime = true
 	cfg.Timeout = connTimeout
 	cfg.ReadTimeout = readTimeout
-	db, err := sqlx.Open("mysql", cfg.FormatDSN())
-	if err != nil {
-		return nil, fmt.Errorf("failed to open %s: %w", cluster.PodName(index), err)
-	}
-	db.SetMaxIdleConns(1)
-	db.SetConnMaxIdleTime(30 * time.Second)
-	return &operator{
-		namespace: cluster.Namespace,
-		name:      cluster.PodName(index),
-		passwd:    pwd,
-		index:     index,
-		db:        db,
-	}, nil
+	return cfg
 }
 
-func (defaultFactory) Cleanup() {}
-
 type operator struct {
 	namespace string
 	name      string
 	passwd    *password.MySQLPassword
 	index     int
 	db        *sqlx.DB
+
+	// statusDB is the connection pool to gather the instance status.
+	// This is the same as `db` unless FactoryConfig.SeparateStatusPool is true.
+	statusDB *sqlx.DB
+
+	// readOnlyDB is the connection pool of the read-only user to run
+	// `spec.replicaHealthCheckSQL`.  This is nil if the query is not set.
+	readOnlyDB *sqlx.DB
+
+	// replicationUser is the replication user of the cluster.
+	replicationUser string
+
+	// maxExecutionTime is `spec.maxExecutionTimeMilliseconds` of the cluster.
+	// See hintSelect and execContext.
+	maxExecutionTime time.Duration
+
+	// shared is true if `db` is owned by the factory and should not be closed by Close.
+	shared bool
+
+	queryCount atomic.Int64
+	execCount  atomic.Int64
 }
 
 var _ Operator = &operator{}
@@ -135,9 +408,73 @@ func (o *operator) Close() error {
 	if o.db == nil {
 		return nil
 	}
+	if o.shared {
+		o.db = nil
+		o.statusDB = nil
+		o.readOnlyDB = nil
+		return nil
+	}
+	if o.readOnlyDB != nil {
+		if err := o.readOnlyDB.Close(); err != nil {
+			return err
+		}
+		o.readOnlyDB = nil
+	}
 	if err := o.db.Close(); err != nil {
 		return err
 	}
 	o.db = nil
+	o.statusDB = nil
 	return nil
 }
+
+func (o *operator) CommandCounts() CommandCounts {
+	return CommandCounts{
+		Query: int(o.queryCount.Load()),
+		Exec:  int(o.execCount.Load()),
+	}
+}
+
+func (o *operator) getContext(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error {
+	o.queryCount.Add(1)
+	return sqlx.GetContext(ctx, q, dest, query, args...)
+}
+
+func (o *operator) selectContext(ctx context.Context, q sqlx.QueryerContext, dest interface{}, query string, args ...interface{}) error {
+	o.queryCount.Add(1)
+	return sqlx.SelectContext(ctx, q, dest, query, args...)
+}
+
+// execContext executes a statement that may change the instance.
+// If `spec.maxExecutionTimeMilliseconds` is set, the statement is canceled after that
+// as mysqld does not limit the execution time of statements other than SELECT.
+func (o *operator) execContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	o.execCount.Add(1)
+	ctx, cancel := o.withExecutionTimeout(ctx)
+	defer cancel()
+	return o.db.ExecContext(ctx, query, args...)
+}
+
+// withExecutionTimeout returns a context that is canceled after `spec.maxExecutionTimeMilliseconds`.
+// If it is not set, `ctx` is returned as is.
+func (o *operator) withExecutionTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.maxExecutionTime <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, o.maxExecutionTime)
+}
+
+// hintSelect adds `MAX_EXECUTION_TIME` optimizer hint to the SELECT statement `query`
+// if `spec.maxExecutionTimeMilliseconds` is set, so that mysqld itself aborts the statement.
+// This must not be used for statements that wait intentionally, such as WAIT_FOR_EXECUTED_GTID_SET.
+func (o *operator) hintSelect(query string) string {
+	if o.maxExecutionTime <= 0 {
+		return query
+	}
+	return fmt.Sprintf("SELECT /*+ MAX_EXECUTION_TIME(%d) */", o.maxExecutionTime.Milliseconds()) + strings.TrimPrefix(query, "SELECT")
+}
+
+// maxExecutionTime returns `spec.maxExecutionTimeMilliseconds` of `cluster` as a duration.
+func maxExecutionTime(cluster *mocov1beta2.MySQLCluster) time.Duration {
+	return time.Duration(cluster.Spec.MaxExecutionTimeMilliseconds) * time.Millisecond
+}
@@ -0,0 +1,262 @@
+package dbop
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/password"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type staticResolver string
+
+func (r staticResolver) Resolve(context.Context, *mocov1beta2.MySQLCluster, int) (string, error) {
+	return string(r), nil
+}
+
+type indexResolver struct{}
+
+func (indexResolver) Resolve(_ context.Context, _ *mocov1beta2.MySQLCluster, index int) (string, error) {
+	return fmt.Sprintf("127.0.0.%d", index+1), nil
+}
+
+var _ = Describe("operator", func() {
+	It("should limit the connection pool", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "pool"
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		f := NewFactory(staticResolver("127.0.0.1"), FactoryConfig{MaxIdleConns: 2, MaxOpenConns: 3})
+		op, err := f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer op.Close()
+		Expect(op.(*operator).db.Stats().MaxOpenConnections).To(Equal(3))
+
+		f = NewFactory(staticResolver("127.0.0.1"), DefaultFactoryConfig)
+		op2, err := f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer op2.Close()
+		Expect(op2.(*operator).db.Stats().MaxOpenConnections).To(Equal(0))
+	})
+
+	It("should reuse the connection pools", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "cache"
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		f := NewFactory(staticResolver("127.0.0.1"), DefaultFactoryConfig).(*defaultFactory)
+		defer f.Cleanup()
+
+		By("creating operators for the same instance")
+		op1, err := f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		db1 := op1.(*operator).db
+		Expect(op1.Close()).To(Succeed())
+		op2, err := f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op2.(*operator).db).To(BeIdenticalTo(db1))
+		Expect(f.dbs).To(HaveLen(1))
+
+		By("changing the password")
+		passwd2, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+		op3, err := f.New(context.Background(), cluster, passwd2, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op3.(*operator).db).NotTo(BeIdenticalTo(db1))
+		Expect(f.dbs).To(HaveLen(1))
+
+		By("removing the host")
+		f.Remove("127.0.0.2")
+		Expect(f.dbs).To(HaveLen(1))
+		f.Remove("127.0.0.1")
+		Expect(f.dbs).To(BeEmpty())
+		op4, err := f.New(context.Background(), cluster, passwd2, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op4.(*operator).db).NotTo(BeIdenticalTo(op3.(*operator).db))
+
+		By("cleaning up")
+		f.Cleanup()
+		Expect(f.dbs).To(BeEmpty())
+		f.Cleanup()
+		_, err = f.New(context.Background(), cluster, passwd2, 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should separate the pool to gather the status if configured", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "separate"
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("sharing a pool by default")
+		f := NewFactory(staticResolver("127.0.0.1"), DefaultFactoryConfig).(*defaultFactory)
+		defer f.Cleanup()
+		op, err := f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op.(*operator).statusDB).To(BeIdenticalTo(op.(*operator).db))
+		Expect(f.dbs).To(HaveLen(1))
+
+		By("separating the pools")
+		f2 := NewFactory(staticResolver("127.0.0.1"), FactoryConfig{MaxOpenConns: 2, SeparateStatusPool: true}).(*defaultFactory)
+		defer f2.Cleanup()
+		op2, err := f2.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		db := op2.(*operator).db
+		statusDB := op2.(*operator).statusDB
+		Expect(statusDB).NotTo(BeIdenticalTo(db))
+		Expect(statusDB.Stats().MaxOpenConnections).To(Equal(2))
+		Expect(db.Stats().MaxOpenConnections).To(Equal(2))
+		Expect(f2.dbs).To(HaveLen(2))
+
+		op3, err := f2.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op3.(*operator).db).To(BeIdenticalTo(db))
+		Expect(op3.(*operator).statusDB).To(BeIdenticalTo(statusDB))
+		Expect(f2.dbs).To(HaveLen(2))
+
+		By("removing both pools")
+		f2.Remove("127.0.0.1")
+		Expect(f2.dbs).To(BeEmpty())
+	})
+
+	It("should open a pool of the read-only user for the health check", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "health"
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		f := NewFactory(staticResolver("127.0.0.1"), DefaultFactoryConfig).(*defaultFactory)
+		defer f.Cleanup()
+
+		op, err := f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op.(*operator).readOnlyDB).To(BeNil())
+		Expect(f.dbs).To(HaveLen(1))
+
+		cluster.Spec.ReplicaHealthCheckSQL = "SELECT 1"
+		op2, err := f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op2.(*operator).readOnlyDB).NotTo(BeNil())
+		Expect(op2.(*operator).readOnlyDB).NotTo(BeIdenticalTo(op2.(*operator).db))
+		Expect(op2.(*operator).db).To(BeIdenticalTo(op.(*operator).db))
+		Expect(f.dbs).To(HaveLen(2))
+	})
+
+	It("should remove the connection pools of a cluster", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "scale"
+		other := &mocov1beta2.MySQLCluster{}
+		other.Namespace = "test"
+		other.Name = "other"
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		f := NewFactory(indexResolver{}, DefaultFactoryConfig).(*defaultFactory)
+		defer f.Cleanup()
+
+		for i := 0; i < 3; i++ {
+			_, err := f.New(context.Background(), cluster, passwd, i)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(f.dbs).To(HaveLen(3))
+
+		By("scaling in")
+		f.RemoveCluster(cluster.Namespace, cluster.Name, 1)
+		Expect(f.dbs).To(HaveLen(1))
+		for _, c := range f.dbs {
+			Expect(c.index).To(Equal(0))
+		}
+
+		By("removing another cluster")
+		f.RemoveCluster(other.Namespace, other.Name, 0)
+		Expect(f.dbs).To(HaveLen(1))
+
+		By("removing the cluster")
+		f.RemoveCluster(cluster.Namespace, cluster.Name, 0)
+		Expect(f.dbs).To(BeEmpty())
+	})
+
+	It("should clean up while creating operators", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "cleanup"
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		f := NewFactory(staticResolver("127.0.0.1"), DefaultFactoryConfig).(*defaultFactory)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				op, err := f.New(context.Background(), cluster, passwd, i)
+				if err == nil {
+					op.Close()
+				}
+			}(i)
+		}
+		f.Cleanup()
+		wg.Wait()
+		Expect(f.dbs).To(BeEmpty())
+	})
+
+	It("should not set max_execution_time of the session", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Spec.MaxExecutionTimeMilliseconds = 1500
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg := newConfig(cluster, passwd, "localhost:3306")
+		Expect(cfg.Params).NotTo(HaveKey("max_execution_time"))
+	})
+
+	It("should limit the execution time of the status queries but not of waiting for GTID", func() {
+		By("preparing a single node cluster")
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "timeout"
+		cluster.Spec.Replicas = 1
+		cluster.Spec.MaxExecutionTimeMilliseconds = 1500
+
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		op, err := factory.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer op.Close()
+
+		By("checking the session variable")
+		var timeout int
+		err = op.(*operator).db.Get(&timeout, "SELECT @@SESSION.max_execution_time")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(timeout).To(Equal(0))
+
+		By("gathering the status with the optimizer hint")
+		_, err = op.GetStatus(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		By("aborting a long running SELECT statement with the hint")
+		// mysqld either interrupts SLEEP() or aborts the statement with an error.
+		start := time.Now()
+		var slept int
+		_ = op.(*operator).db.Get(&slept, op.(*operator).hintSelect("SELECT SLEEP(5)"))
+		Expect(time.Since(start)).To(BeNumerically("<", 4*time.Second))
+
+		By("waiting for GTID longer than the limit")
+		start = time.Now()
+		err = op.WaitForGTID(context.Background(), "3E11FA47-71CA-11E1-9E33-C80AA9429562:1", 3)
+		Expect(err).To(MatchError(ErrTimeout))
+		Expect(time.Since(start)).To(BeNumerically(">=", 3*time.Second))
+	})
+})
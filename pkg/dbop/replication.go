@@ -5,51 +5,103 @@ import (
 	"fmt"
 )
 
-const semiSyncMasterTimeout = 24 * 60 * 60 * 1000
+// DefaultSemiSyncMasterTimeout is the default value of `rpl_semi_sync_master_timeout` in milliseconds.
+// It is long enough so that the semi-synchronous replication never degrades to asynchronous one.
+const DefaultSemiSyncMasterTimeout = 24 * 60 * 60 * 1000
 
 func (o *operator) ConfigureReplica(ctx context.Context, primary AccessInfo, semisync bool) error {
-	if _, err := o.db.ExecContext(ctx, `STOP SLAVE`); err != nil {
+	// each statement is retried on transient errors so that a brief disconnection
+	// does not fail the whole configuration.  The statements are idempotent.
+	if err := o.execRetry(ctx, `STOP SLAVE`); err != nil {
 		return fmt.Errorf("failed to stop replica: %w", err)
 	}
-	if _, err := o.db.NamedExecContext(ctx, `CHANGE MASTER TO MASTER_HOST = :Host, MASTER_PORT = :Port, MASTER_USER = :User, MASTER_PASSWORD = :Password, MASTER_AUTO_POSITION = 1, GET_MASTER_PUBLIC_KEY = 1`, primary); err != nil {
+	err := retryTransient(ctx, func() error {
+		o.execCount.Add(1)
+		ctx, cancel := o.withExecutionTimeout(ctx)
+		defer cancel()
+		_, err := o.db.NamedExecContext(ctx, `CHANGE MASTER TO MASTER_HOST = :Host, MASTER_PORT = :Port, MASTER_USER = :User, MASTER_PASSWORD = :Password, MASTER_AUTO_POSITION = 1, GET_MASTER_PUBLIC_KEY = 1`, primary)
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("failed to change primary: %w", err)
 	}
-	if _, err := o.db.ExecContext(ctx, "SET GLOBAL rpl_semi_sync_slave_enabled=?", semisync); err != nil {
+	// a replica must not accept writes even from users with SUPER privilege.
+	// `super_read_only=1` implicitly sets `read_only=1`.
+	if err := o.execRetry(ctx, "SET GLOBAL super_read_only=1"); err != nil {
+		return fmt.Errorf("failed to set super_read_only=1: %w", err)
+	}
+	if err := o.execRetry(ctx, "SET GLOBAL rpl_semi_sync_slave_enabled=?", semisync); err != nil {
 		return fmt.Errorf("failed to set rpl_semi_sync_slave_enabled: %w", err)
 	}
-	if _, err := o.db.ExecContext(ctx, "SET GLOBAL rpl_semi_sync_master_enabled=OFF"); err != nil {
+	if err := o.execRetry(ctx, "SET GLOBAL rpl_semi_sync_master_enabled=OFF"); err != nil {
 		return fmt.Errorf("failed to disable rpl_semi_sync_master_enabled: %w", err)
 	}
-	if _, err := o.db.ExecContext(ctx, `START SLAVE`); err != nil {
+	if err := o.execRetry(ctx, `START SLAVE`); err != nil {
 		return fmt.Errorf("failed to start replica: %w", err)
 	}
 	return nil
 }
 
-func (o *operator) ConfigurePrimary(ctx context.Context, waitForCount int) error {
-	if _, err := o.db.ExecContext(ctx, "SET GLOBAL rpl_semi_sync_master_timeout=?", semiSyncMasterTimeout); err != nil {
-		return fmt.Errorf("failed to set rpl_semi_sync_master_timeout count: %w", err)
+func (o *operator) ConfigurePrimary(ctx context.Context, waitForCount, timeoutMilliseconds int) error {
+	if waitForCount == 0 {
+		if _, err := o.execContext(ctx, "SET GLOBAL rpl_semi_sync_master_enabled=OFF"); err != nil {
+			return fmt.Errorf("failed to disable semi-sync primary: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := o.execContext(ctx, "SET GLOBAL rpl_semi_sync_master_timeout=?", timeoutMilliseconds); err != nil {
+		return fmt.Errorf("failed to set rpl_semi_sync_master_timeout: %w", err)
 	}
-	if _, err := o.db.ExecContext(ctx, "SET GLOBAL rpl_semi_sync_master_wait_for_slave_count=?", waitForCount); err != nil {
+	if _, err := o.execContext(ctx, "SET GLOBAL rpl_semi_sync_master_wait_for_slave_count=?", waitForCount); err != nil {
 		return fmt.Errorf("failed to set rpl_semi_sync_master_wait_for_slave_count count: %w", err)
 	}
-	if _, err := o.db.ExecContext(ctx, "SET GLOBAL rpl_semi_sync_master_enabled=ON"); err != nil {
+	if _, err := o.execContext(ctx, "SET GLOBAL rpl_semi_sync_master_enabled=ON"); err != nil {
 		return fmt.Errorf("failed to enable semi-sync primary: %w", err)
 	}
 	return nil
 }
 
 func (o *operator) StopReplicaIOThread(ctx context.Context) error {
-	if _, err := o.db.ExecContext(ctx, `STOP SLAVE IO_THREAD`); err != nil {
+	if _, err := o.execContext(ctx, `STOP SLAVE IO_THREAD`); err != nil {
 		return fmt.Errorf("failed to stop replica IO thread: %w", err)
 	}
 	return nil
 }
 
+func (o *operator) StartReplicaSQLThread(ctx context.Context) error {
+	if _, err := o.execContext(ctx, `START SLAVE SQL_THREAD`); err != nil {
+		return fmt.Errorf("failed to start replica SQL thread: %w", err)
+	}
+	return nil
+}
+
+func (o *operator) StartReplicaIOThread(ctx context.Context) error {
+	if _, err := o.execContext(ctx, `START SLAVE IO_THREAD`); err != nil {
+		return fmt.Errorf("failed to start replica IO thread: %w", err)
+	}
+	return nil
+}
+
+func (o *operator) ResetReplica(ctx context.Context) error {
+	// RESET SLAVE without ALL keeps the connection parameters, and
+	// GTID auto-positioning fetches the discarded transactions again.
+	if err := o.execRetry(ctx, `STOP SLAVE`); err != nil {
+		return fmt.Errorf("failed to stop replica: %w", err)
+	}
+	if err := o.execRetry(ctx, `RESET SLAVE`); err != nil {
+		return fmt.Errorf("failed to reset replica: %w", err)
+	}
+	if err := o.execRetry(ctx, `START SLAVE`); err != nil {
+		return fmt.Errorf("failed to start replica: %w", err)
+	}
+	return nil
+}
+
 func (o *operator) WaitForGTID(ctx context.Context, gtid string, timeoutSeconds int) error {
 	var err error
 	var timeout bool
-	err = o.db.GetContext(ctx, &timeout, `SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)`, gtid, timeoutSeconds)
+	err = o.getContext(ctx, o.db, &timeout, `SELECT WAIT_FOR_EXECUTED_GTID_SET(?, ?)`, gtid, timeoutSeconds)
 	if err != nil {
 		return fmt.Errorf("failed to wait GTID subset %s: %w", gtid, err)
 	}
@@ -61,19 +113,22 @@ func (o *operator) WaitForGTID(ctx context.Context, gtid string, timeoutSeconds
 
 func (o *operator) SetReadOnly(ctx context.Context, readOnly bool) error {
 	if readOnly {
-		if _, err := o.db.ExecContext(ctx, "SET GLOBAL super_read_only=1"); err != nil {
+		if _, err := o.execContext(ctx, "SET GLOBAL super_read_only=1"); err != nil {
 			return fmt.Errorf("failed to set super_read_only=1: %w", err)
 		}
 		return nil
 	}
 
-	if _, err := o.db.ExecContext(ctx, "STOP SLAVE"); err != nil {
+	if _, err := o.execContext(ctx, "STOP SLAVE"); err != nil {
 		return fmt.Errorf("failed to stop replica: %w", err)
 	}
-	if _, err := o.db.ExecContext(ctx, "RESET SLAVE"); err != nil {
+	if _, err := o.execContext(ctx, "RESET SLAVE"); err != nil {
 		return fmt.Errorf("failed to stop replica: %w", err)
 	}
-	if _, err := o.db.ExecContext(ctx, "SET GLOBAL read_only=0"); err != nil {
+	if _, err := o.execContext(ctx, "SET GLOBAL super_read_only=0"); err != nil {
+		return fmt.Errorf("failed to set super_read_only=0: %w", err)
+	}
+	if _, err := o.execContext(ctx, "SET GLOBAL read_only=0"); err != nil {
 		return fmt.Errorf("failed to set read_only=0: %w", err)
 	}
 	return nil
@@ -0,0 +1,178 @@
+package dbop
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingConn is a driver connection that only records executed statements.
+// If `block` is true, statements block until the context is done.
+type recordingConn struct {
+	mu    *sync.Mutex
+	execs *[]string
+	block bool
+}
+
+var _ driver.ExecerContext = recordingConn{}
+var _ driver.QueryerContext = recordingConn{}
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c recordingConn) Close() error {
+	return nil
+}
+
+func (c recordingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.mu.Lock()
+	*c.execs = append(*c.execs, query)
+	c.mu.Unlock()
+	if c.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, errors.New("not supported")
+}
+
+type recordingConnector struct {
+	recordingConn
+}
+
+func (c recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.recordingConn, nil
+}
+
+func (c recordingConnector) Driver() driver.Driver {
+	return nil
+}
+
+// newRecordingOperator returns an operator whose statements are recorded in `execs`.
+func newRecordingOperator(execs *[]string) *operator {
+	conn := recordingConn{mu: &sync.Mutex{}, execs: execs}
+	db := sqlx.NewDb(sql.OpenDB(recordingConnector{conn}), "mysql")
+	return &operator{namespace: "test", name: "recording", db: db}
+}
+
+// newBlockingOperator returns an operator whose statements never finish until the context is done.
+func newBlockingOperator() *operator {
+	conn := recordingConn{mu: &sync.Mutex{}, execs: new([]string), block: true}
+	db := sqlx.NewDb(sql.OpenDB(recordingConnector{conn}), "mysql")
+	return &operator{namespace: "test", name: "blocking", db: db}
+}
+
+var _ = Describe("replication statements", func() {
+	It("should make a replica super_read_only before starting replication", func() {
+		var execs []string
+		op := newRecordingOperator(&execs)
+		defer op.Close()
+
+		err := op.ConfigureReplica(context.Background(), AccessInfo{Host: "primary", Port: 3306, User: "repl", Password: "pass"}, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(execs).To(HaveLen(6))
+		Expect(execs[0]).To(Equal("STOP SLAVE"))
+		Expect(execs[1]).To(HavePrefix("CHANGE MASTER TO"))
+		Expect(execs[2]).To(Equal("SET GLOBAL super_read_only=1"))
+		Expect(execs[5]).To(Equal("START SLAVE"))
+	})
+
+	It("should clear super_read_only of a new primary", func() {
+		var execs []string
+		op := newRecordingOperator(&execs)
+		defer op.Close()
+
+		err := op.SetReadOnly(context.Background(), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(execs).To(Equal([]string{
+			"STOP SLAVE",
+			"RESET SLAVE",
+			"SET GLOBAL super_read_only=0",
+			"SET GLOBAL read_only=0",
+		}))
+	})
+})
+
+var _ = Describe("context propagation", func() {
+	It("should abort statements when the context is done", func() {
+		op := newBlockingOperator()
+		defer op.Close()
+
+		calls := map[string]func(context.Context) error{
+			"StopReplicaIOThread": op.StopReplicaIOThread,
+			"ConfigureReplica": func(ctx context.Context) error {
+				return op.ConfigureReplica(ctx, AccessInfo{Host: "primary", Port: 3306}, true)
+			},
+			"SetReadOnly": func(ctx context.Context) error {
+				return op.SetReadOnly(ctx, true)
+			},
+			"KillConnections": op.KillConnections,
+			"IsSubsetGTID": func(ctx context.Context) error {
+				_, err := op.IsSubsetGTID(ctx, "a:1", "a:1-2")
+				return err
+			},
+			"GetDataSize": func(ctx context.Context) error {
+				_, err := op.GetDataSize(ctx)
+				return err
+			},
+		}
+		for name, f := range calls {
+			By(name)
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			start := time.Now()
+			err := f(ctx)
+			cancel()
+			Expect(err).To(MatchError(context.DeadlineExceeded), name)
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second), name)
+		}
+	})
+
+	It("should cancel statements after spec.maxExecutionTimeMilliseconds", func() {
+		op := newBlockingOperator()
+		defer op.Close()
+		op.maxExecutionTime = 100 * time.Millisecond
+
+		calls := map[string]func(context.Context) error{
+			"StopReplicaIOThread": op.StopReplicaIOThread,
+			"ConfigureReplica": func(ctx context.Context) error {
+				return op.ConfigureReplica(ctx, AccessInfo{Host: "primary", Port: 3306}, true)
+			},
+			"SetReadOnly": func(ctx context.Context) error {
+				return op.SetReadOnly(ctx, true)
+			},
+		}
+		for name, f := range calls {
+			By(name)
+			start := time.Now()
+			err := f(context.Background())
+			Expect(err).To(MatchError(context.DeadlineExceeded), name)
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second), name)
+		}
+	})
+
+	It("should add the optimizer hint only if the limit is set", func() {
+		op := &operator{}
+		Expect(op.hintSelect("SELECT 1")).To(Equal("SELECT 1"))
+		op.maxExecutionTime = 1500 * time.Millisecond
+		Expect(op.hintSelect("SELECT 1")).To(Equal("SELECT /*+ MAX_EXECUTION_TIME(1500) */ 1"))
+	})
+})
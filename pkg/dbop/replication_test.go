@@ -49,6 +49,10 @@ var _ = Describe("replication", func() {
 		By("initializing an external instance 0")
 		err = ops[0].SetReadOnly(ctx, false)
 		Expect(err).NotTo(HaveOccurred())
+		st, err := ops[0].GetStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.GlobalVariables.SuperReadOnly).To(BeFalse())
+		Expect(st.GlobalVariables.ReadOnly).To(BeFalse())
 		_, err = ops[0].db.Exec(`CREATE DATABASE foo`)
 		Expect(err).NotTo(HaveOccurred())
 		_, err = ops[0].db.Exec(`CREATE TABLE foo.t1 (pkey INT PRIMARY KEY, data TEXT NOT NULL) ENGINE=InnoDB`)
@@ -92,7 +96,7 @@ var _ = Describe("replication", func() {
 		Expect(err).NotTo(HaveOccurred())
 		err = ops[1].WaitForGTID(ctx, st0.GlobalVariables.ExecutedGTID, 1)
 		Expect(err).To(MatchError(ErrTimeout))
-		_, err = ops[1].db.Exec(`START SLAVE`)
+		err = ops[1].StartReplicaIOThread(ctx)
 		Expect(err).NotTo(HaveOccurred())
 		err = ops[1].WaitForGTID(ctx, st0.GlobalVariables.ExecutedGTID, 0)
 		Expect(err).NotTo(HaveOccurred())
@@ -101,6 +105,63 @@ var _ = Describe("replication", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(count).To(Equal(4))
 
+		By("restarting SQL thread")
+		_, err = ops[1].db.Exec(`STOP SLAVE SQL_THREAD`)
+		Expect(err).NotTo(HaveOccurred())
+		st1, err = ops[1].GetStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st1.ReplicaStatus.SlaveSQLRunning).To(Equal("No"))
+		err = ops[1].StartReplicaSQLThread(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		st1, err = ops[1].GetStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st1.ReplicaStatus.SlaveSQLRunning).To(Equal("Yes"))
+		Expect(st1.ReplicaStatus.AutoPosition).To(Equal("1"))
+		Expect(st1.ReplicaStatus.SQLDelay).To(Equal(0))
+		Expect(st1.ReplicaStatus.SQLRemainingDelay.Valid).To(BeFalse())
+
+		By("checking the delay of a delayed replica")
+		_, err = ops[1].db.Exec(`STOP SLAVE SQL_THREAD`)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ops[1].db.Exec(`CHANGE MASTER TO MASTER_DELAY = 3600`)
+		Expect(err).NotTo(HaveOccurred())
+		err = ops[1].StartReplicaSQLThread(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ops[0].db.Exec(`CREATE DATABASE delayed`)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() bool {
+			st1, err = ops[1].GetStatus(ctx)
+			if err != nil {
+				return false
+			}
+			return st1.ReplicaStatus.SQLRemainingDelay.Valid
+		}).Should(BeTrue())
+		Expect(st1.ReplicaStatus.SQLDelay).To(Equal(3600))
+		Expect(st1.ReplicaStatus.SQLRemainingDelay.Int64).To(BeNumerically(">", 0))
+		Expect(st1.ReplicaStatus.SQLRemainingDelay.Int64).To(BeNumerically("<=", 3600))
+
+		_, err = ops[1].db.Exec(`STOP SLAVE SQL_THREAD`)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ops[1].db.Exec(`CHANGE MASTER TO MASTER_DELAY = 0`)
+		Expect(err).NotTo(HaveOccurred())
+		err = ops[1].StartReplicaSQLThread(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		st0, err = ops[0].GetStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		err = ops[1].WaitForGTID(ctx, st0.GlobalVariables.ExecutedGTID, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("resetting replica")
+		err = ops[1].ResetReplica(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		st1, err = ops[1].GetStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st1.ReplicaStatus.MasterHost).To(Equal(testContainerName(cluster, 0)))
+		Expect(st1.ReplicaStatus.SlaveSQLRunning).To(Equal("Yes"))
+		Expect(st1.ReplicaStatus.AutoPosition).To(Equal("1"))
+		err = ops[1].WaitForGTID(ctx, st0.GlobalVariables.ExecutedGTID, 0)
+		Expect(err).NotTo(HaveOccurred())
+
 		By("configuring asynchronous replication between 1 and 2")
 		err = ops[2].ConfigureReplica(ctx, AccessInfo{
 			Host:     testContainerName(cluster, 1),
@@ -184,7 +245,7 @@ var _ = Describe("replication", func() {
 		Expect(st2.ReplicaStatus.RetrievedGtidSet).NotTo(BeEmpty())
 		err = ops[2].WaitForGTID(ctx, st2.ReplicaStatus.RetrievedGtidSet, 10)
 		Expect(err).NotTo(HaveOccurred())
-		err = ops[2].ConfigurePrimary(ctx, 1)
+		err = ops[2].ConfigurePrimary(ctx, 1, DefaultSemiSyncMasterTimeout)
 		Expect(err).NotTo(HaveOccurred())
 		err = ops[2].SetReadOnly(ctx, false)
 		Expect(err).NotTo(HaveOccurred())
@@ -208,5 +269,24 @@ var _ = Describe("replication", func() {
 			}
 			return count
 		}).Should(Equal(7))
+
+		By("checking the number of semi-sync clients of 2")
+		Eventually(func() int {
+			st2, err := ops[2].GetStatus(ctx)
+			if err != nil {
+				return -1
+			}
+			return st2.SemiSyncMasterClients
+		}).Should(Equal(1))
+		st1, err = ops[1].GetStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st1.SemiSyncSlaveActive).To(BeTrue())
+
+		By("disabling semi-sync primary of 2")
+		err = ops[2].ConfigurePrimary(ctx, 0, DefaultSemiSyncMasterTimeout)
+		Expect(err).NotTo(HaveOccurred())
+		st2, err = ops[2].GetStatus(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st2.GlobalVariables.SemiSyncMasterEnabled).To(BeFalse())
 	})
 })
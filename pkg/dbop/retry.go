@@ -0,0 +1,75 @@
+package dbop
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Parameters of retryTransient.  These are variables for testing.
+var (
+	retryMaxAttempts     = 4
+	retryInitialInterval = 200 * time.Millisecond
+)
+
+// retryTransient calls `f` until it succeeds or returns a non-transient error.
+// The interval between the attempts doubles from `retryInitialInterval`, and
+// the last error is returned after `retryMaxAttempts` attempts.
+// If `ctx` is canceled while waiting for the next attempt, the last error is returned immediately.
+func retryTransient(ctx context.Context, f func() error) error {
+	interval := retryInitialInterval
+	for i := 1; ; i++ {
+		err := f()
+		if err == nil || !isTransient(err) || i >= retryMaxAttempts {
+			return err
+		}
+
+		logr.FromContextOrDiscard(ctx).Info("retrying a statement after a transient error", "attempt", i, "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// isTransient returns true if `err` may go away by retrying the same statement,
+// e.g. a broken connection or a lock wait timeout.
+// Errors such as wrong credentials or insufficient privileges are not transient.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	var merr *mysql.MySQLError
+	if errors.As(err, &merr) {
+		switch merr.Number {
+		case 1040, // ER_CON_COUNT_ERROR
+			1053, // ER_SERVER_SHUTDOWN
+			1205, // ER_LOCK_WAIT_TIMEOUT
+			1213: // ER_LOCK_DEADLOCK
+			return true
+		}
+		return false
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// execRetry is the same as execContext except that transient errors are retried.
+func (o *operator) execRetry(ctx context.Context, query string, args ...interface{}) error {
+	return retryTransient(ctx, func() error {
+		_, err := o.execContext(ctx, query, args...)
+		return err
+	})
+}
@@ -0,0 +1,91 @@
+package dbop
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("retryTransient", func() {
+	var origAttempts int
+	var origInterval time.Duration
+
+	BeforeEach(func() {
+		origAttempts, origInterval = retryMaxAttempts, retryInitialInterval
+		retryMaxAttempts = 4
+		retryInitialInterval = time.Millisecond
+	})
+
+	AfterEach(func() {
+		retryMaxAttempts, retryInitialInterval = origAttempts, origInterval
+	})
+
+	It("should retry transient errors until success", func() {
+		var count int
+		err := retryTransient(context.Background(), func() error {
+			count++
+			if count < 3 {
+				return fmt.Errorf("failed to change primary: %w", driver.ErrBadConn)
+			}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(3))
+	})
+
+	It("should give up after the max attempts", func() {
+		var count int
+		err := retryTransient(context.Background(), func() error {
+			count++
+			return &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(count).To(Equal(4))
+	})
+
+	It("should fail fast on permanent errors", func() {
+		var count int
+		permanent := &mysql.MySQLError{Number: 1045, Message: "Access denied"}
+		err := retryTransient(context.Background(), func() error {
+			count++
+			return permanent
+		})
+		Expect(errors.Is(err, permanent)).To(BeTrue())
+		Expect(count).To(Equal(1))
+	})
+
+	It("should stop retrying when the context is canceled", func() {
+		retryInitialInterval = time.Hour
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var count int
+		done := make(chan error)
+		go func() {
+			done <- retryTransient(ctx, func() error {
+				count++
+				return driver.ErrBadConn
+			})
+		}()
+		cancel()
+
+		var err error
+		Eventually(done).Should(Receive(&err))
+		Expect(errors.Is(err, driver.ErrBadConn)).To(BeTrue())
+		Expect(count).To(Equal(1))
+	})
+
+	It("should classify errors", func() {
+		Expect(isTransient(mysql.ErrInvalidConn)).To(BeTrue())
+		Expect(isTransient(&mysql.MySQLError{Number: 1213})).To(BeTrue())
+		Expect(isTransient(&mysql.MySQLError{Number: 1227})).To(BeFalse())
+		Expect(isTransient(context.Canceled)).To(BeFalse())
+		Expect(isTransient(context.DeadlineExceeded)).To(BeFalse())
+		Expect(isTransient(errors.New("unknown"))).To(BeFalse())
+	})
+})
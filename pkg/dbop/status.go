@@ -6,64 +6,130 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/jmoiron/sqlx"
 )
 
 var statusGlobalVarsString = strings.Join(statusGlobalVars, ",")
 
+// statusTxOptions is the options of the transaction to gather the instance status.
+// The transaction only groups the queries on one read-only connection of the status pool.
+// It does not make them a consistent snapshot: READ COMMITTED takes a fresh snapshot per
+// statement, and SHOW SLAVE STATUS, global variables, and performance_schema tables are
+// not transactional at all, so the state may change between the queries.
+var statusTxOptions = &sql.TxOptions{
+	Isolation: sql.LevelReadCommitted,
+	ReadOnly:  true,
+}
+
+func (o *operator) beginStatusTx(ctx context.Context) (*sqlx.Tx, error) {
+	return o.statusDB.BeginTxx(ctx, statusTxOptions)
+}
+
 func (o *operator) GetStatus(ctx context.Context) (*MySQLInstanceStatus, error) {
+	if o.statusDB == nil {
+		return nil, fmt.Errorf("the operator has been closed: pod=%s, namespace=%s", o.name, o.namespace)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	tx, err := o.beginStatusTx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrBeginTx, o.name, o.namespace, err)
+	}
+	defer tx.Rollback()
 
 	status := &MySQLInstanceStatus{}
 
-	globalVariablesStatus, err := o.getGlobalVariablesStatus(ctx)
+	globalVariablesStatus, err := o.getGlobalVariablesStatus(ctx, tx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get global variables: pod=%s, namespace=%s: %w", o.name, o.namespace, err)
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrGlobalVariables, o.name, o.namespace, err)
 	}
 	status.GlobalVariables = *globalVariablesStatus
 
-	if err := o.db.Select(&status.ReplicaHosts, `SHOW SLAVE HOSTS`); err != nil {
-		return nil, fmt.Errorf("failed to get slave hosts: pod=%s, namespace=%s: %w", o.name, o.namespace, err)
+	if err := o.selectContext(ctx, tx, &status.ReplicaHosts, `SHOW SLAVE HOSTS`); err != nil {
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrReplicaHosts, o.name, o.namespace, err)
 	}
 
-	replicaStatus, err := o.getReplicaStatus(ctx)
+	replicaStatuses, err := o.getReplicaStatus(ctx, tx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get replica status: pod=%s, namespace=%s: %w", o.name, o.namespace, err)
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrReplicaStatus, o.name, o.namespace, err)
+	}
+	status.ReplicaStatus = defaultChannelStatus(replicaStatuses)
+	for _, rs := range replicaStatuses {
+		status.ReplicationChannels = append(status.ReplicationChannels, rs.ChannelName)
 	}
-	status.ReplicaStatus = replicaStatus
 
-	cloneStatus, err := o.getCloneStateStatus(ctx)
+	cloneStatus, err := o.getCloneStateStatus(ctx, tx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get clone status: pod=%s, namespace=%s: %w", o.name, o.namespace, err)
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrCloneStatus, o.name, o.namespace, err)
 	}
 	status.CloneStatus = cloneStatus
 
+	clients, err := o.getSemiSyncMasterClients(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrGlobalStatus, o.name, o.namespace, err)
+	}
+	status.SemiSyncMasterClients = clients
+
+	active, err := o.getSemiSyncSlaveActive(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrGlobalStatus, o.name, o.namespace, err)
+	}
+	status.SemiSyncSlaveActive = active
+
+	threads, err := o.getThreadsConnected(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrGlobalStatus, o.name, o.namespace, err)
+	}
+	status.ThreadsConnected = threads
+
+	recovered, err := o.getRecoveredTransactions(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: pod=%s, namespace=%s: %w", ErrInnoDBStatus, o.name, o.namespace, err)
+	}
+	status.RecoveredTransactions = recovered
+
 	return status, nil
 }
 
-func (o *operator) getGlobalVariablesStatus(ctx context.Context) (*GlobalVariables, error) {
+func (o *operator) getGlobalVariablesStatus(ctx context.Context, tx *sqlx.Tx) (*GlobalVariables, error) {
 	status := &GlobalVariables{}
-	err := o.db.GetContext(ctx, status, "SELECT "+statusGlobalVarsString)
+	err := o.getContext(ctx, tx, status, o.hintSelect("SELECT "+statusGlobalVarsString))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get mysql global variables: %w", err)
 	}
 	return status, nil
 }
 
-func (o *operator) getReplicaStatus(ctx context.Context) (*ReplicaStatus, error) {
-	status := &ReplicaStatus{}
-	err := o.db.GetContext(ctx, status, `SHOW SLAVE STATUS`)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			// slave status can be empty for non-replica servers
-			return nil, nil
-		}
+// getReplicaStatus returns the status of all replication channels.
+// The result is empty for non-replica servers.
+func (o *operator) getReplicaStatus(ctx context.Context, tx *sqlx.Tx) ([]ReplicaStatus, error) {
+	var statuses []ReplicaStatus
+	if err := o.selectContext(ctx, tx, &statuses, `SHOW SLAVE STATUS`); err != nil {
 		return nil, fmt.Errorf("failed to get slave status: %w", err)
 	}
-	return status, nil
+	return statuses, nil
 }
 
-func (o *operator) getCloneStateStatus(ctx context.Context) (*CloneStatus, error) {
+// defaultChannelStatus returns the status of the default replication channel.
+// If there is no default channel, the first channel is returned.
+func defaultChannelStatus(statuses []ReplicaStatus) *ReplicaStatus {
+	if len(statuses) == 0 {
+		return nil
+	}
+	for i := range statuses {
+		if statuses[i].ChannelName == "" {
+			return &statuses[i]
+		}
+	}
+	return &statuses[0]
+}
+
+func (o *operator) getCloneStateStatus(ctx context.Context, tx *sqlx.Tx) (*CloneStatus, error) {
 	status := &CloneStatus{}
-	err := o.db.GetContext(ctx, status, `SELECT state FROM performance_schema.clone_status`)
+	err := o.getContext(ctx, tx, status, o.hintSelect(`SELECT state, source, end_time, error_no, error_message FROM performance_schema.clone_status`))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// clone status can be empty
@@ -71,5 +137,108 @@ func (o *operator) getCloneStateStatus(ctx context.Context) (*CloneStatus, error
 		}
 		return nil, fmt.Errorf("failed to get ps.clone_status: %w", err)
 	}
+
+	var progress struct {
+		Data     int64 `db:"data"`
+		Estimate int64 `db:"estimate"`
+	}
+	err = o.getContext(ctx, tx, &progress, o.hintSelect(`SELECT COALESCE(SUM(data), 0) AS data, COALESCE(SUM(estimate), 0) AS estimate FROM performance_schema.clone_progress`))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ps.clone_progress: %w", err)
+	}
+	status.EstimatedProgress = estimateCloneProgress(status.State.String, progress.Data, progress.Estimate)
 	return status, nil
 }
+
+// estimateCloneProgress returns the percentage of `data` bytes copied out of
+// the `estimate` bytes of all the copy stages.  The result is capped at 100
+// as the estimate may be smaller than the actual data.
+func estimateCloneProgress(state string, data, estimate int64) float64 {
+	if state == "Completed" {
+		return 100
+	}
+	if estimate <= 0 {
+		return 0
+	}
+	progress := float64(data) * 100 / float64(estimate)
+	if progress > 100 {
+		return 100
+	}
+	return progress
+}
+
+func (o *operator) getSemiSyncMasterClients(ctx context.Context, tx *sqlx.Tx) (int, error) {
+	var clients int
+	err := o.getContext(ctx, tx, &clients, o.hintSelect(`SELECT VARIABLE_VALUE FROM performance_schema.global_status WHERE VARIABLE_NAME = 'Rpl_semi_sync_master_clients'`))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the plugin is not loaded
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get Rpl_semi_sync_master_clients: %w", err)
+	}
+	return clients, nil
+}
+
+func (o *operator) getSemiSyncSlaveActive(ctx context.Context, tx *sqlx.Tx) (bool, error) {
+	var value string
+	err := o.getContext(ctx, tx, &value, o.hintSelect(`SELECT VARIABLE_VALUE FROM performance_schema.global_status WHERE VARIABLE_NAME = 'Rpl_semi_sync_slave_status'`))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// the plugin is not loaded
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get Rpl_semi_sync_slave_status: %w", err)
+	}
+	return value == "ON", nil
+}
+
+func (o *operator) getThreadsConnected(ctx context.Context, tx *sqlx.Tx) (int, error) {
+	var threads int
+	err := o.getContext(ctx, tx, &threads, o.hintSelect(`SELECT VARIABLE_VALUE FROM performance_schema.global_status WHERE VARIABLE_NAME = 'Threads_connected'`))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Threads_connected: %w", err)
+	}
+	return threads, nil
+}
+
+// getRecoveredTransactions returns the number of transactions that InnoDB is rolling back
+// after the crash recovery.  Such transactions are not associated with any client thread.
+func (o *operator) getRecoveredTransactions(ctx context.Context, tx *sqlx.Tx) (int, error) {
+	var count int
+	err := o.getContext(ctx, tx, &count, o.hintSelect(`SELECT COUNT(*) FROM information_schema.INNODB_TRX WHERE trx_mysql_thread_id = 0 AND trx_state = 'ROLLING BACK'`))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get information_schema.INNODB_TRX: %w", err)
+	}
+	return count, nil
+}
+
+func (o *operator) CheckHealth(ctx context.Context, query string) (bool, error) {
+	if o.readOnlyDB == nil {
+		return false, errors.New("no connection for the health check; replicaHealthCheckSQL is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	tx, err := o.readOnlyDB.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return false, fmt.Errorf("failed to begin a transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var ok bool
+	if err := o.getContext(ctx, tx, &ok, query); err != nil {
+		return false, fmt.Errorf("failed to run the health check query: %w", err)
+	}
+	return ok, nil
+}
+
+func (o *operator) GetDataSize(ctx context.Context) (int64, error) {
+	var size int64
+	err := o.getContext(ctx, o.db, &size, `SELECT CAST(COALESCE(SUM(data_length + index_length), 0) AS SIGNED) FROM information_schema.tables`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the data size: %w", err)
+	}
+	return size, nil
+}
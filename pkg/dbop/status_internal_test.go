@@ -0,0 +1,40 @@
+package dbop
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("defaultChannelStatus", func() {
+	It("should return nil for non-replica servers", func() {
+		Expect(defaultChannelStatus(nil)).To(BeNil())
+	})
+
+	It("should choose the default channel from multiple rows", func() {
+		rs := defaultChannelStatus([]ReplicaStatus{
+			{ChannelName: "extra", MasterHost: "extra-host"},
+			{ChannelName: "", MasterHost: "default-host"},
+		})
+		Expect(rs).NotTo(BeNil())
+		Expect(rs.MasterHost).To(Equal("default-host"))
+	})
+
+	It("should fall back to the first channel", func() {
+		rs := defaultChannelStatus([]ReplicaStatus{
+			{ChannelName: "a", MasterHost: "a-host"},
+			{ChannelName: "b", MasterHost: "b-host"},
+		})
+		Expect(rs).NotTo(BeNil())
+		Expect(rs.MasterHost).To(Equal("a-host"))
+	})
+})
+
+var _ = Describe("estimateCloneProgress", func() {
+	It("should calculate the percentage of copied data", func() {
+		Expect(estimateCloneProgress("In Progress", 0, 0)).To(Equal(0.0))
+		Expect(estimateCloneProgress("In Progress", 25, 100)).To(Equal(25.0))
+		Expect(estimateCloneProgress("In Progress", 120, 100)).To(Equal(100.0))
+		Expect(estimateCloneProgress("Failed", 50, 200)).To(Equal(25.0))
+		Expect(estimateCloneProgress("Completed", 0, 0)).To(Equal(100.0))
+	})
+})
@@ -2,8 +2,12 @@ package dbop
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/constants"
 	"github.com/cybozu-go/moco/pkg/password"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -28,11 +32,21 @@ var _ = Describe("status", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(status).NotTo(BeNil())
 		Expect(status.GlobalVariables.ExecutedGTID).To(BeEmpty())
+		Expect(status.GlobalVariables.PurgedGTID).To(BeEmpty())
 		Expect(status.GlobalVariables.ReadOnly).To(BeTrue())
 		Expect(status.GlobalVariables.SuperReadOnly).To(BeTrue())
 		Expect(status.GlobalVariables.WaitForSlaveCount).To(Equal(1))
 		Expect(status.GlobalVariables.SemiSyncMasterEnabled).To(BeFalse())
 		Expect(status.GlobalVariables.SemiSyncSlaveEnabled).To(BeFalse())
+		Expect(status.GlobalVariables.LogBin).To(BeTrue())
+		Expect(status.GlobalVariables.MaxConnections).To(BeNumerically(">", 0))
+		Expect(status.ThreadsConnected).To(BeNumerically(">=", 1))
+		// transactions of client threads are not counted as recovered ones.
+		Expect(status.RecoveredTransactions).To(Equal(0))
+
+		size, err := op.GetDataSize(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(BeNumerically(">", 0))
 
 		By("writing data and checking gtid_executed")
 		_, err = op.(*operator).db.Exec("SET GLOBAL read_only=0")
@@ -49,17 +63,154 @@ var _ = Describe("status", func() {
 		Expect(status.GlobalVariables.SemiSyncMasterEnabled).To(BeFalse())
 		Expect(status.GlobalVariables.SemiSyncSlaveEnabled).To(BeFalse())
 
+		By("purging binary logs and checking gtid_purged")
+		_, err = op.(*operator).db.Exec("FLUSH BINARY LOGS")
+		Expect(err).NotTo(HaveOccurred())
+		binlog := map[string]any{}
+		err = op.(*operator).db.QueryRowx("SHOW MASTER STATUS").MapScan(binlog)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = op.(*operator).db.Exec(fmt.Sprintf("PURGE BINARY LOGS TO '%s'", binlog["File"]))
+		Expect(err).NotTo(HaveOccurred())
+		status, err = op.GetStatus(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.GlobalVariables.PurgedGTID).To(Equal(status.GlobalVariables.ExecutedGTID))
+
 		By("enabling semi-sync master")
-		err = op.ConfigurePrimary(context.Background(), 3)
+		err = op.ConfigurePrimary(context.Background(), 3, 3000)
 		Expect(err).NotTo(HaveOccurred())
 		status, err = op.GetStatus(context.Background())
 		Expect(err).NotTo(HaveOccurred())
 		Expect(status).NotTo(BeNil())
 		Expect(status.GlobalVariables.WaitForSlaveCount).To(Equal(3))
 		Expect(status.GlobalVariables.SemiSyncMasterEnabled).To(BeTrue())
+		Expect(status.GlobalVariables.SemiSyncMasterTimeout).To(Equal(3000))
 		Expect(status.GlobalVariables.SemiSyncSlaveEnabled).To(BeFalse())
 
 		err = op.Close()
 		Expect(err).NotTo(HaveOccurred())
 	})
+
+	It("should report which query failed", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "status-error"
+		cluster.Spec.Replicas = 1
+
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("connecting to a port where no mysqld listens")
+		f := NewFactory(staticResolver("127.0.0.1"), DefaultFactoryConfig)
+		defer f.Cleanup()
+		op, err := f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = op.GetStatus(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, ErrBeginTx)).To(BeTrue())
+		Expect(errors.Is(err, ErrGlobalVariables)).To(BeFalse())
+
+		By("closing the operator")
+		err = op.Close()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = op.GetStatus(context.Background())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should gather status in a read committed session", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "status-tx"
+		cluster.Spec.Replicas = 1
+
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		op, err := factory.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		tx, err := op.(*operator).beginStatusTx(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		var isolation string
+		err = tx.Get(&isolation, "SELECT @@transaction_isolation")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(isolation).To(Equal("READ-COMMITTED"))
+
+		err = tx.Rollback()
+		Expect(err).NotTo(HaveOccurred())
+
+		err = op.Close()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should run custom health check queries", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "health"
+		cluster.Spec.Replicas = 1
+		cluster.Spec.ReplicaHealthCheckSQL = "SELECT 1"
+
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		op, err := factory.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		ok, err := op.CheckHealth(context.Background(), "SELECT 1 = 1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+
+		ok, err = op.CheckHealth(context.Background(), "SELECT 1 = 0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+
+		_, err = op.CheckHealth(context.Background(), "SELECT * FROM no_such_table")
+		Expect(err).To(HaveOccurred())
+
+		By("running the query as the read-only user")
+		ok, err = op.CheckHealth(context.Background(), "SELECT CURRENT_USER() LIKE '"+constants.ReadOnlyUser+"@%'")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+
+		_, err = op.CheckHealth(context.Background(), "SET GLOBAL max_connections = 10")
+		Expect(err).To(HaveOccurred())
+
+		By("cancelling a slow query")
+		orig := healthCheckTimeout
+		healthCheckTimeout = 100 * time.Millisecond
+		defer func() { healthCheckTimeout = orig }()
+		_, err = op.CheckHealth(context.Background(), "SELECT SLEEP(10) = 0")
+		Expect(err).To(HaveOccurred())
+
+		err = op.Close()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should use a short timeout only for gathering status", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "status-timeout"
+		cluster.Spec.Replicas = 1
+
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		op, err := factory.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		orig := statusTimeout
+		statusTimeout = time.Nanosecond
+		defer func() { statusTimeout = orig }()
+
+		_, err = op.GetStatus(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+
+		err = op.ConfigurePrimary(context.Background(), 1, DefaultSemiSyncMasterTimeout)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = op.Close()
+		Expect(err).NotTo(HaveOccurred())
+	})
 })
@@ -0,0 +1,24 @@
+package dbop
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// quoteIdentifier quotes a MySQL identifier with backticks.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (o *operator) EnsureTable(ctx context.Context, database, table, definition string) error {
+	db := quoteIdentifier(database)
+	if _, err := o.execContext(ctx, "CREATE DATABASE IF NOT EXISTS "+db); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", database, err)
+	}
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (%s)", db, quoteIdentifier(table), definition)
+	if _, err := o.execContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create table %s.%s: %w", database, table, err)
+	}
+	return nil
+}
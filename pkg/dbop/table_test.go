@@ -0,0 +1,55 @@
+package dbop
+
+import (
+	"context"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/password"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("table", func() {
+	It("should create tables idempotently", func() {
+		By("preparing a single node cluster")
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "table"
+		cluster.Spec.Replicas = 1
+
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		op, err := factory.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = op.(*operator).db.Exec("SET GLOBAL read_only=0")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating a table")
+		err = op.EnsureTable(context.Background(), "ops", "heartbeat", "id INT PRIMARY KEY, ts TIMESTAMP(6) NOT NULL")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(op.CommandCounts()).To(Equal(CommandCounts{Exec: 2}))
+		_, err = op.(*operator).db.Exec("INSERT INTO ops.heartbeat VALUES (1, NOW(6))")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("ensuring the same table again")
+		err = op.EnsureTable(context.Background(), "ops", "heartbeat", "id INT PRIMARY KEY, ts TIMESTAMP(6) NOT NULL")
+		Expect(err).NotTo(HaveOccurred())
+
+		var count int
+		err = op.(*operator).db.Get(&count, "SELECT COUNT(*) FROM ops.heartbeat")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(1))
+
+		By("quoting identifiers")
+		err = op.EnsureTable(context.Background(), "ops", "weird`name", "id INT")
+		Expect(err).NotTo(HaveOccurred())
+		err = op.(*operator).db.Get(&count, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = 'ops' AND table_name = 'weird`name'")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(1))
+
+		err = op.Close()
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -174,15 +174,7 @@ func (f *testFactory) New(ctx context.Context, cluster *mocov1beta2.MySQLCluster
 }
 
 func newTestOperator(cluster *mocov1beta2.MySQLCluster, pwd *password.MySQLPassword, index, port int) (Operator, error) {
-	cfg := mysql.NewConfig()
-	cfg.User = constants.AdminUser
-	cfg.Passwd = pwd.Admin()
-	cfg.Net = "tcp"
-	cfg.Addr = fmt.Sprintf("localhost:%d", port)
-	cfg.InterpolateParams = true
-	cfg.ParseTime = true
-	cfg.Timeout = connTimeout
-	cfg.ReadTimeout = readTimeout
+	cfg := newConfig(cluster, pwd, fmt.Sprintf("localhost:%d", port))
 	udb, err := sqlx.Connect("mysql", cfg.FormatDSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to %s: %w", cfg.FormatDSN(), err)
@@ -190,12 +182,31 @@ func newTestOperator(cluster *mocov1beta2.MySQLCluster, pwd *password.MySQLPassw
 	udb.SetMaxIdleConns(1)
 	udb.SetConnMaxIdleTime(30 * time.Second)
 
+	var rdb *sqlx.DB
+	if cluster.Spec.ReplicaHealthCheckSQL != "" {
+		rcfg := newConfig(cluster, pwd, cfg.Addr)
+		rcfg.User = constants.ReadOnlyUser
+		rcfg.Passwd = pwd.ReadOnly()
+		rdb, err = sqlx.Connect("mysql", rcfg.FormatDSN())
+		if err != nil {
+			udb.Close()
+			return nil, fmt.Errorf("failed to connect to %s: %w", rcfg.FormatDSN(), err)
+		}
+		rdb.SetMaxIdleConns(1)
+		rdb.SetConnMaxIdleTime(30 * time.Second)
+	}
+
 	return &operator{
-		namespace: cluster.Namespace,
-		name:      cluster.PodName(index),
-		passwd:    pwd,
-		index:     index,
-		db:        udb,
+		namespace:  cluster.Namespace,
+		name:       cluster.PodName(index),
+		passwd:     pwd,
+		index:      index,
+		db:         udb,
+		statusDB:   udb,
+		readOnlyDB: rdb,
+
+		replicationUser:  cluster.ReplicationUser(),
+		maxExecutionTime: maxExecutionTime(cluster),
 	}, nil
 }
 
@@ -224,6 +235,10 @@ func (f *testFactory) newConn(ctx context.Context, cluster *mocov1beta2.MySQLClu
 	return udb, nil
 }
 
+func (f *testFactory) Remove(host string) {}
+
+func (f *testFactory) RemoveCluster(namespace, name string, replicas int) {}
+
 func (f *testFactory) Cleanup() {
 	out, err := exec.Command("docker", "ps", "--format", "{{.Names}}").Output()
 	if err != nil {
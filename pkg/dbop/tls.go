@@ -0,0 +1,73 @@
+package dbop
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// tlsConfigSeq generates unique names of TLS configurations registered to the driver.
+var tlsConfigSeq atomic.Int64
+
+// NewTLSConfig returns a TLS configuration to connect to MySQL instances.
+// `dir` is usually a directory where a Secret is mounted and may contain these files.
+//
+// - ca.crt:  CA certificate bundle in PEM format to verify the server certificates.
+// - tls.crt: The client certificate.  This is optional.
+// - tls.key: The private key of the client certificate.  This is required if tls.crt exists.
+//
+// If `serverName` is not empty, it is used to verify the server certificates
+// instead of the IP addresses of the instances.
+// If `skipVerify` is true, the server certificates are not verified and ca.crt is not required.
+// This should be used only for testing.
+func NewTLSConfig(dir, serverName string, skipVerify bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: skipVerify,
+	}
+	if dir == "" {
+		if !skipVerify {
+			return nil, errors.New("no CA certificate is given")
+		}
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	switch {
+	case err == nil:
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("no certificates found in ca.crt")
+		}
+		cfg.RootCAs = pool
+	case errors.Is(err, os.ErrNotExist) && skipVerify:
+	default:
+		return nil, fmt.Errorf("failed to load ca.crt: %w", err)
+	}
+
+	certFile := filepath.Join(dir, "tls.crt")
+	if _, err := os.Stat(certFile); err == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, filepath.Join(dir, "tls.key"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cert/key pair: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// registerTLSConfig registers `cfg` to the driver and returns the name to be used
+// as `tls` parameter of the DSN.
+func registerTLSConfig(cfg *tls.Config) (string, error) {
+	name := fmt.Sprintf("moco-%d", tlsConfigSeq.Add(1))
+	if err := mysql.RegisterTLSConfig(name, cfg); err != nil {
+		return "", err
+	}
+	return name, nil
+}
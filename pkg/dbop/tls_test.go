@@ -0,0 +1,110 @@
+package dbop
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/password"
+	"github.com/go-sql-driver/mysql"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// writeSelfSignedCert writes a self-signed certificate and its key to `dir`
+// as ca.crt, tls.crt, and tls.key.
+func writeSelfSignedCert(dir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "moco-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	Expect(os.WriteFile(filepath.Join(dir, "ca.crt"), certPEM, 0644)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "tls.crt"), certPEM, 0644)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "tls.key"), keyPEM, 0600)).To(Succeed())
+}
+
+var _ = Describe("TLS", func() {
+	It("should load the certificates", func() {
+		dir, err := os.MkdirTemp("", "moco-tls")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		By("requiring ca.crt")
+		_, err = NewTLSConfig("", "", false)
+		Expect(err).To(HaveOccurred())
+		_, err = NewTLSConfig(dir, "", false)
+		Expect(err).To(HaveOccurred())
+
+		By("allowing no CA with skip-verify")
+		cfg, err := NewTLSConfig("", "", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.InsecureSkipVerify).To(BeTrue())
+		cfg, err = NewTLSConfig(dir, "", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.RootCAs).To(BeNil())
+
+		By("loading the CA and the client certificate")
+		writeSelfSignedCert(dir)
+		cfg, err = NewTLSConfig(dir, "moco.example.com", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.RootCAs).NotTo(BeNil())
+		Expect(cfg.Certificates).To(HaveLen(1))
+		Expect(cfg.ServerName).To(Equal("moco.example.com"))
+		Expect(cfg.InsecureSkipVerify).To(BeFalse())
+
+		By("rejecting a broken key")
+		Expect(os.WriteFile(filepath.Join(dir, "tls.key"), []byte("broken"), 0600)).To(Succeed())
+		_, err = NewTLSConfig(dir, "", false)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should connect over TLS", func() {
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "tls"
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		tlsCfg, err := NewTLSConfig("", "", true)
+		Expect(err).NotTo(HaveOccurred())
+		f := NewFactory(staticResolver("127.0.0.1"), FactoryConfig{TLSConfig: tlsCfg}).(*defaultFactory)
+		_, err = f.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		name := f.tlsName
+		Expect(name).NotTo(BeEmpty())
+		Expect(f.dbs).To(HaveLen(1))
+		for _, c := range f.dbs {
+			dsnCfg, err := mysql.ParseDSN(c.dsn)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dsnCfg.TLSConfig).To(Equal(name))
+		}
+
+		By("deregistering the TLS config on cleanup")
+		f.Cleanup()
+		_, err = mysql.ParseDSN("moco@tcp(127.0.0.1:3306)/?tls=" + name)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -18,27 +18,64 @@ type MySQLInstanceStatus struct {
 	ReplicaHosts    []ReplicaHost
 	ReplicaStatus   *ReplicaStatus // may not be available
 	CloneStatus     *CloneStatus   // may not be available
+
+	// CustomHealthCheckFailed is true if `spec.replicaHealthCheckSQL` returned false or failed.
+	CustomHealthCheckFailed bool
+
+	// ReplicationChannels is the list of replication channel names.
+	// MOCO manages only the default channel whose name is empty.
+	ReplicationChannels []string
+
+	// SemiSyncMasterClients is the value of `Rpl_semi_sync_master_clients` status variable.
+	// This is zero if the semi-sync source plugin is not loaded.
+	SemiSyncMasterClients int
+
+	// SemiSyncSlaveActive is true if `Rpl_semi_sync_slave_status` status variable is ON,
+	// i.e. the instance is acknowledging transactions to its source as a semi-sync replica.
+	SemiSyncSlaveActive bool
+
+	// ThreadsConnected is the value of `Threads_connected` status variable,
+	// i.e. the number of currently open connections.
+	ThreadsConnected int
+
+	// RecoveredTransactions is the number of transactions recovered by the InnoDB
+	// crash recovery that are still being rolled back in the background.
+	// `mysqld` accepts connections during the rollback, but the instance should not
+	// be operated until it completes.
+	RecoveredTransactions int
 }
 
 var statusGlobalVars = []string{
 	"@@server_uuid",
 	"@@gtid_executed",
+	"@@gtid_purged",
 	"@@read_only",
 	"@@super_read_only",
 	"@@rpl_semi_sync_master_wait_for_slave_count",
 	"@@rpl_semi_sync_master_enabled",
+	"@@rpl_semi_sync_master_timeout",
 	"@@rpl_semi_sync_slave_enabled",
+	"@@log_bin",
+	"@@character_set_server",
+	"@@collation_server",
+	"@@max_connections",
 }
 
 // GlobalVariables defines the observed global variable values of a MySQL instance
 type GlobalVariables struct {
 	UUID                  string `db:"@@server_uuid"`
 	ExecutedGTID          string `db:"@@gtid_executed"`
+	PurgedGTID            string `db:"@@gtid_purged"`
 	ReadOnly              bool   `db:"@@read_only"`
 	SuperReadOnly         bool   `db:"@@super_read_only"`
 	WaitForSlaveCount     int    `db:"@@rpl_semi_sync_master_wait_for_slave_count"`
 	SemiSyncMasterEnabled bool   `db:"@@rpl_semi_sync_master_enabled"`
+	SemiSyncMasterTimeout int    `db:"@@rpl_semi_sync_master_timeout"`
 	SemiSyncSlaveEnabled  bool   `db:"@@rpl_semi_sync_slave_enabled"`
+	LogBin                bool   `db:"@@log_bin"`
+	CharacterSetServer    string `db:"@@character_set_server"`
+	CollationServer       string `db:"@@collation_server"`
+	MaxConnections        int    `db:"@@max_connections"`
 }
 
 // ReplicaHost defines the columns from `SHOW SLAVE HOSTS`
@@ -56,15 +93,17 @@ type ReplicaHost struct {
 
 // ReplicaStatus defines the observed state of a replica
 type ReplicaStatus struct {
-	LastIoErrno      int    `db:"Last_IO_Errno"`
-	LastIoError      string `db:"Last_IO_Error"`
-	LastSQLErrno     int    `db:"Last_SQL_Errno"`
-	LastSQLError     string `db:"Last_SQL_Error"`
-	MasterHost       string `db:"Master_Host"`
-	RetrievedGtidSet string `db:"Retrieved_Gtid_Set"`
-	ExecutedGtidSet  string `db:"Executed_Gtid_Set"`
-	SlaveIORunning   string `db:"Slave_IO_Running"`
-	SlaveSQLRunning  string `db:"Slave_SQL_Running"`
+	LastIoErrno         int           `db:"Last_IO_Errno"`
+	LastIoError         string        `db:"Last_IO_Error"`
+	LastSQLErrno        int           `db:"Last_SQL_Errno"`
+	LastSQLError        string        `db:"Last_SQL_Error"`
+	MasterHost          string        `db:"Master_Host"`
+	RetrievedGtidSet    string        `db:"Retrieved_Gtid_Set"`
+	ExecutedGtidSet     string        `db:"Executed_Gtid_Set"`
+	SlaveIORunning      string        `db:"Slave_IO_Running"`
+	SlaveSQLRunning     string        `db:"Slave_SQL_Running"`
+	AutoPosition        string        `db:"Auto_Position"`
+	SecondsBehindMaster sql.NullInt64 `db:"Seconds_Behind_Master"`
 
 	// All of variables from here are NOT used in MOCO's reconcile
 	SlaveIOState              string        `db:"Slave_IO_State"`
@@ -96,7 +135,6 @@ type ReplicaStatus struct {
 	MasterSSLCert             string        `db:"Master_SSL_Cert"`
 	MasterSSLCipher           string        `db:"Master_SSL_Cipher"`
 	MasterSSLKey              string        `db:"Master_SSL_Key"`
-	SecondsBehindMaster       sql.NullInt64 `db:"Seconds_Behind_Master"`
 	MasterSSLVerifyServerCert string        `db:"Master_SSL_Verify_Server_Cert"`
 	ReplicateIgnoreServerIds  string        `db:"Replicate_Ignore_Server_Ids"`
 	MasterServerID            int           `db:"Master_Server_Id"`
@@ -111,7 +149,6 @@ type ReplicaStatus struct {
 	LastSQLErrorTimestamp     string        `db:"Last_SQL_Error_Timestamp"`
 	MasterSSLCrl              string        `db:"Master_SSL_Crl"`
 	MasterSSLCrlpath          string        `db:"Master_SSL_Crlpath"`
-	AutoPosition              string        `db:"Auto_Position"`
 	ReplicateRewriteDB        string        `db:"Replicate_Rewrite_DB"`
 	ChannelName               string        `db:"Channel_Name"`
 	MasterTLSVersion          string        `db:"Master_TLS_Version"`
@@ -129,7 +166,17 @@ func (rs *ReplicaStatus) IsRunning() bool {
 
 // CloneStatus defines the observed clone status of a MySQL instance
 type CloneStatus struct {
-	State sql.NullString `db:"state"`
+	State   sql.NullString `db:"state"`
+	Source  sql.NullString `db:"source"`
+	EndTime sql.NullTime   `db:"end_time"`
+
+	// ErrorNo and ErrorMessage tell why the clone operation failed.
+	ErrorNo      sql.NullInt64  `db:"error_no"`
+	ErrorMessage sql.NullString `db:"error_message"`
+
+	// EstimatedProgress is the estimated percentage (0-100) of the data copied so far.
+	// This is calculated from `performance_schema.clone_progress`.
+	EstimatedProgress float64 `db:"-"`
 }
 
 // Process represents a process in `information_schema.PROCESSLIST` table.
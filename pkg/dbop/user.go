@@ -0,0 +1,20 @@
+package dbop
+
+import (
+	"context"
+	"fmt"
+)
+
+func (o *operator) EnsureReplicationUser(ctx context.Context, user, password string) error {
+	if _, err := o.execContext(ctx, `CREATE USER IF NOT EXISTS ?@'%' IDENTIFIED BY ?`, user, password); err != nil {
+		return fmt.Errorf("failed to create user %s: %w", user, err)
+	}
+	// the user may have been created with another password, e.g. by restoring a backup.
+	if _, err := o.execContext(ctx, `ALTER USER ?@'%' IDENTIFIED BY ?`, user, password); err != nil {
+		return fmt.Errorf("failed to set the password of user %s: %w", user, err)
+	}
+	if _, err := o.execContext(ctx, `GRANT REPLICATION CLIENT, REPLICATION SLAVE ON *.* TO ?@'%'`, user); err != nil {
+		return fmt.Errorf("failed to grant privileges to user %s: %w", user, err)
+	}
+	return nil
+}
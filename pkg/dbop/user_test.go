@@ -0,0 +1,56 @@
+package dbop
+
+import (
+	"context"
+
+	mocov1beta2 "github.com/cybozu-go/moco/api/v1beta2"
+	"github.com/cybozu-go/moco/pkg/password"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("user", func() {
+	It("should create the replication user idempotently", func() {
+		By("preparing a single node cluster")
+		cluster := &mocov1beta2.MySQLCluster{}
+		cluster.Namespace = "test"
+		cluster.Name = "user"
+		cluster.Spec.Replicas = 1
+		cluster.Spec.ReplicationUser = "tenant_repl"
+
+		passwd, err := password.NewMySQLPassword()
+		Expect(err).NotTo(HaveOccurred())
+
+		op, err := factory.New(context.Background(), cluster, passwd, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = op.(*operator).db.Exec("SET GLOBAL read_only=0")
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating the user")
+		err = op.EnsureReplicationUser(context.Background(), "tenant_repl", "p1")
+		Expect(err).NotTo(HaveOccurred())
+
+		var count int
+		err = op.(*operator).db.Get(&count, "SELECT COUNT(*) FROM mysql.user WHERE user = 'tenant_repl' AND Repl_slave_priv = 'Y'")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(1))
+
+		By("changing the password")
+		err = op.EnsureReplicationUser(context.Background(), "tenant_repl", "p2")
+		Expect(err).NotTo(HaveOccurred())
+		db, err := factory.(*testFactory).newConn(context.Background(), cluster, "tenant_repl", "p2", 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		By("keeping the connection of the replication user")
+		err = op.KillConnections(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		err = op.(*operator).db.Get(&count, "SELECT COUNT(*) FROM information_schema.PROCESSLIST WHERE user = 'tenant_repl'")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(1))
+
+		err = op.Close()
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
@@ -15,7 +15,11 @@ type MOCOEvent struct {
 	Message string
 }
 
+// Emit records the event for `obj`.  Nothing is recorded if `r` is nil.
 func (e MOCOEvent) Emit(obj runtime.Object, r record.EventRecorder, args ...interface{}) {
+	if r == nil {
+		return
+	}
 	r.Eventf(obj, e.Type, e.Reason, e.Message, args...)
 }
 
@@ -72,6 +76,31 @@ var (
 		Reason:  "FailOverFailed",
 		Message: "The primary could not be changed: %v",
 	}
+	FailOverForced = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "FailOverForced",
+		Message: "A failover from instance %d in %s state is forced; transactions not replicated to reachable replicas will be lost",
+	}
+	PrimaryChanged = MOCOEvent{
+		Type:    corev1.EventTypeNormal,
+		Reason:  "PrimaryChanged",
+		Message: "The primary was changed from instance %d to %d",
+	}
+	ClusterLost = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "ClusterLost",
+		Message: "No instance is available to become the primary",
+	}
+	WaitTimeout = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "WaitTimeout",
+		Message: "The cluster has not converged for more than %d seconds: %s",
+	}
+	FencingFailed = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "FencingFailed",
+		Message: "The old primary instance %d could not be fenced: %v",
+	}
 	CloneSucceeded = MOCOEvent{
 		Type:    corev1.EventTypeNormal,
 		Reason:  "Cloned",
@@ -82,6 +111,46 @@ var (
 		Reason:  "CloneFailed",
 		Message: "Clone from the primary failed for instance %d: %v",
 	}
+	ReplicaOutOfSync = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "ReplicaOutOfSync",
+		Message: "Instance %d has been out of sync for %d consecutive checks",
+	}
+	ReplicaSelfReplication = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "ReplicaSelfReplication",
+		Message: "Instance %d was replicating from itself and has been reconfigured",
+	}
+	ReplicaIOThreadExhausted = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "ReplicaIOThreadExhausted",
+		Message: "The replication IO thread of instance %d stopped retrying and could not be revived %d times",
+	}
+	ReplicaReset = MOCOEvent{
+		Type:    corev1.EventTypeNormal,
+		Reason:  "ReplicaReset",
+		Message: "The replication of instance %d was reset to recover from SQL error %d (attempt %d)",
+	}
+	ReplicaRecoveryExhausted = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "ReplicaRecoveryExhausted",
+		Message: "The replication SQL thread of instance %d could not be recovered from SQL error %d after %d attempts",
+	}
+	ReCloneStarted = MOCOEvent{
+		Type:    corev1.EventTypeNormal,
+		Reason:  "ReCloneStarted",
+		Message: "Re-cloning instance %d as it lacks transactions purged from the primary",
+	}
+	ReCloneSkipped = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "ReCloneSkipped",
+		Message: "Instance %d lacks purged transactions but cannot be re-cloned: the data size %d bytes exceeds the volume capacity %d bytes",
+	}
+	ConnectionHeadroomLow = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "ConnectionHeadroomLow",
+		Message: "Instance %d is promoted to the primary without enough connection headroom (Threads_connected=%d, max_connections=%d)",
+	}
 	SetWritable = MOCOEvent{
 		Type:    corev1.EventTypeNormal,
 		Reason:  "Writable",
@@ -97,6 +166,11 @@ var (
 		Reason:  "BackupNoBinlog",
 		Message: "Backup created w/o binlog files",
 	}
+	BackupBinlogDisabled = MOCOEvent{
+		Type:    corev1.EventTypeWarning,
+		Reason:  "BackupBinlogDisabled",
+		Message: "Backup failed because the binary logging is disabled on instance %d",
+	}
 	Restored = MOCOEvent{
 		Type:    corev1.EventTypeNormal,
 		Reason:  "Restored",
@@ -1,6 +1,10 @@
 package metrics
 
 import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/cybozu-go/moco"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -10,18 +14,31 @@ const (
 	backupSubsystem     = "backup"
 )
 
+// BuildInfo is a metric to expose the version information of moco-controller.
+var BuildInfo *prometheus.GaugeVec
+
 // Clustering related metrics
 var (
-	CheckCountVec      *prometheus.CounterVec
-	ErrorCountVec      *prometheus.CounterVec
-	AvailableVec       *prometheus.GaugeVec
-	HealthyVec         *prometheus.GaugeVec
-	SwitchoverCountVec *prometheus.CounterVec
-	FailoverCountVec   *prometheus.CounterVec
-	TotalReplicasVec   *prometheus.GaugeVec
-	ReadyReplicasVec   *prometheus.GaugeVec
-	ErrantReplicasVec  *prometheus.GaugeVec
-	ProcessingTimeVec  *prometheus.HistogramVec
+	CheckCountVec        *prometheus.CounterVec
+	ErrorCountVec        *prometheus.CounterVec
+	AvailableVec         *prometheus.GaugeVec
+	HealthyVec           *prometheus.GaugeVec
+	SwitchoverCountVec   *prometheus.CounterVec
+	FailoverCountVec     *prometheus.CounterVec
+	TotalReplicasVec     *prometheus.GaugeVec
+	ReadyReplicasVec     *prometheus.GaugeVec
+	SyncedReplicasVec    *prometheus.GaugeVec
+	DesiredReplicasVec   *prometheus.GaugeVec
+	ErrantReplicasVec    *prometheus.GaugeVec
+	OutOfSyncReplicasVec *prometheus.GaugeVec
+	ReplicationDelayVec  *prometheus.GaugeVec
+	PrimaryIndexVec      *prometheus.GaugeVec
+	MySQLCommandsVec     *prometheus.CounterVec
+	OperationErrorsVec   *prometheus.CounterVec
+	ProcessingTimeVec    *prometheus.HistogramVec
+	GatherTimeVec        *prometheus.HistogramVec
+	DecisionTimeVec      *prometheus.HistogramVec
+	LastSuccessVec       *prometheus.GaugeVec
 
 	VolumeResizedTotal            *prometheus.CounterVec
 	VolumeResizedErrorTotal       *prometheus.CounterVec
@@ -41,6 +58,14 @@ var (
 
 // Register registers Prometheus metrics vectors to the registry.
 func Register(registry prometheus.Registerer) {
+	BuildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "build_info",
+		Help:      "The version information of moco-controller",
+	}, []string{"version", "revision", "goversion"})
+	registry.MustRegister(BuildInfo)
+	BuildInfo.WithLabelValues(moco.Version, gitCommit(), runtime.Version()).Set(1)
+
 	CheckCountVec = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: metricsNamespace,
 		Subsystem: clusteringSubsystem,
@@ -105,6 +130,22 @@ func Register(registry prometheus.Registerer) {
 	}, []string{"name", "namespace"})
 	registry.MustRegister(ReadyReplicasVec)
 
+	SyncedReplicasVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "synced_replicas",
+		Help:      "The number of synced instances in the cluster",
+	}, []string{"name", "namespace"})
+	registry.MustRegister(SyncedReplicasVec)
+
+	DesiredReplicasVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "desired_replicas",
+		Help:      "The number of instances specified for the cluster",
+	}, []string{"name", "namespace"})
+	registry.MustRegister(DesiredReplicasVec)
+
 	ErrantReplicasVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: metricsNamespace,
 		Subsystem: clusteringSubsystem,
@@ -113,6 +154,46 @@ func Register(registry prometheus.Registerer) {
 	}, []string{"name", "namespace"})
 	registry.MustRegister(ErrantReplicasVec)
 
+	OutOfSyncReplicasVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "long_out_of_sync_replicas",
+		Help:      "The number of replicas that have been out of sync for too long",
+	}, []string{"name", "namespace"})
+	registry.MustRegister(OutOfSyncReplicasVec)
+
+	ReplicationDelayVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "max_replication_delay_seconds",
+		Help:      "The largest Seconds_Behind_Master among the replicas",
+	}, []string{"name", "namespace"})
+	registry.MustRegister(ReplicationDelayVec)
+
+	PrimaryIndexVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "current_primary_index",
+		Help:      "The index of the current primary instance",
+	}, []string{"name", "namespace"})
+	registry.MustRegister(PrimaryIndexVec)
+
+	MySQLCommandsVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "mysql_commands_total",
+		Help:      "The number of SQL commands MOCO has issued to the cluster",
+	}, []string{"name", "namespace", "type"})
+	registry.MustRegister(MySQLCommandsVec)
+
+	OperationErrorsVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "operation_errors_total",
+		Help:      "The number of times an operation MOCO performed on the cluster has failed",
+	}, []string{"name", "namespace", "operation"})
+	registry.MustRegister(OperationErrorsVec)
+
 	ProcessingTimeVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: metricsNamespace,
 		Subsystem: clusteringSubsystem,
@@ -122,6 +203,32 @@ func Register(registry prometheus.Registerer) {
 	}, []string{"name", "namespace"})
 	registry.MustRegister(ProcessingTimeVec)
 
+	GatherTimeVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "status_gather_duration_seconds",
+		Help:      "The length of time in seconds gathering the status of the cluster",
+		Buckets:   []float64{0.1, 0.25, 0.5, 0.75, 1.0, 2.5, 5.0, 7.5, 10, 20, 30},
+	}, []string{"name", "namespace"})
+	registry.MustRegister(GatherTimeVec)
+
+	DecisionTimeVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "decision_duration_seconds",
+		Help:      "The length of time in seconds deciding the state of the cluster",
+		Buckets:   prometheus.ExponentialBuckets(0.00001, 10, 6),
+	}, []string{"name", "namespace"})
+	registry.MustRegister(DecisionTimeVec)
+
+	LastSuccessVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: clusteringSubsystem,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "The time when MOCO last finished maintaining the cluster without errors",
+	}, []string{"name", "namespace"})
+	registry.MustRegister(LastSuccessVec)
+
 	BackupTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: metricsNamespace,
 		Subsystem: backupSubsystem,
@@ -202,3 +309,19 @@ func Register(registry prometheus.Registerer) {
 	}, []string{"name", "namespace"})
 	registry.MustRegister(StatefulSetRecreateErrorTotal)
 }
+
+func gitCommit() string {
+	if moco.GitCommit != "" {
+		return moco.GitCommit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/cybozu-go/moco"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBuildInfo(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	Register(registry)
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mf := range mfs {
+		if mf.GetName() != "moco_build_info" {
+			continue
+		}
+		if len(mf.Metric) != 1 {
+			t.Fatalf("unexpected number of metrics: %d", len(mf.Metric))
+		}
+		m := mf.Metric[0]
+		if m.GetGauge().GetValue() != 1 {
+			t.Errorf("unexpected value: %f", m.GetGauge().GetValue())
+		}
+		labels := make(map[string]string)
+		for _, l := range m.Label {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["version"] != moco.Version {
+			t.Errorf("unexpected version: %s", labels["version"])
+		}
+		if labels["goversion"] != runtime.Version() {
+			t.Errorf("unexpected goversion: %s", labels["goversion"])
+		}
+		if _, ok := labels["revision"]; !ok {
+			t.Error("revision label is missing")
+		}
+		return
+	}
+	t.Error("moco_build_info is not registered")
+}
@@ -157,7 +157,8 @@ func calcBufferSize(total int64) int64 {
 //
 // If `userConf` does not specify `innodb_buffer_pool_size`, this
 // will automatically set it to 70% of `memTotal`.
-func Generate(userConf map[string]string, memTotal int64) string {
+// `port` overrides the port number in ConstMycnf.
+func Generate(userConf map[string]string, memTotal int64, port int32) string {
 	opaque := userConf[opaqueKey]
 	mysqldConf := mergeSection(DefaultMycnf, userConf)
 	if _, ok := mysqldConf["innodb_buffer_pool_size"]; !ok {
@@ -174,6 +175,8 @@ func Generate(userConf map[string]string, memTotal int64) string {
 	for sec, secConf := range ConstMycnf {
 		conf[sec] = mergeSection(conf[sec], secConf)
 	}
+	conf["mysqld"]["port"] = strconv.Itoa(int(port))
+	conf["client"]["port"] = strconv.Itoa(int(port))
 
 	// sort keys to generate reproducible my.cnf
 	sections := make([]string, 0, len(conf))
@@ -2,8 +2,10 @@ package mycnf
 
 import (
 	_ "embed"
+	"strings"
 	"testing"
 
+	"github.com/cybozu-go/moco/pkg/constants"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -13,13 +15,14 @@ func TestGenerator(t *testing.T) {
 	t.Run("loose", testLoose)
 	t.Run("buffer-pool-size", testBufferPoolSize)
 	t.Run("opaque", testOpaque)
+	t.Run("port", testPort)
 }
 
 //go:embed testdata/nil.cnf
 var nilCnf string
 
 func testGeneratorNil(t *testing.T) {
-	actual := Generate(nil, 100<<20)
+	actual := Generate(nil, 100<<20, constants.MySQLPort)
 	if !cmp.Equal(nilCnf, actual) {
 		t.Error("not matched", cmp.Diff(nilCnf, actual))
 	}
@@ -32,7 +35,7 @@ func testNormalize(t *testing.T) {
 	actual := Generate(map[string]string{
 		"thread-cache-size": "200",
 		"foo":               "bar",
-	}, 1000<<20)
+	}, 1000<<20, constants.MySQLPort)
 	if !cmp.Equal(normalizeCnf, actual) {
 		t.Error("not matched", cmp.Diff(normalizeCnf, actual))
 	}
@@ -46,7 +49,7 @@ func testLoose(t *testing.T) {
 		"innodb_numa_interleave":                 "OFF",
 		"loose_temptable_use_mmap":               "ON",
 		"loose_innodb_validate_tablespace_paths": "ON",
-	}, 1000<<20)
+	}, 1000<<20, constants.MySQLPort)
 	if !cmp.Equal(looseCnf, actual) {
 		t.Error("not matched", cmp.Diff(looseCnf, actual))
 	}
@@ -58,7 +61,7 @@ var bufsizeCnf string
 func testBufferPoolSize(t *testing.T) {
 	actual := Generate(map[string]string{
 		"innodb_buffer_pool_size": "268435456",
-	}, 1000<<20)
+	}, 1000<<20, constants.MySQLPort)
 	if !cmp.Equal(bufsizeCnf, actual) {
 		t.Error("not matched", cmp.Diff(bufsizeCnf, actual))
 	}
@@ -73,9 +76,19 @@ func testOpaque(t *testing.T) {
 performance-schema-instrument='wait/synch/%/innodb/%=ON'
 performance-schema-instrument='wait/lock/table/sql/handler=OFF'
 performance-schema-instrument='wait/lock/metadata/sql/mdl=OFF'
-`}, 100<<20)
+`}, 100<<20, constants.MySQLPort)
 	if !cmp.Equal(opaqueCnf, actual) {
 		t.Error("not matched", cmp.Diff(opaqueCnf, actual))
 	}
 
 }
+
+func testPort(t *testing.T) {
+	actual := Generate(map[string]string{"port": "3307"}, 100<<20, 13306)
+	if n := strings.Count(actual, "port = 13306\n"); n != 2 {
+		t.Errorf("port should be set in mysqld and client sections: %d\n%s", n, actual)
+	}
+	if strings.Contains(actual, "port = 3306\n") || strings.Contains(actual, "port = 3307\n") {
+		t.Error("port is not overridden", actual)
+	}
+}
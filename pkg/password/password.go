@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"text/template"
 
 	"github.com/cybozu-go/moco/pkg/constants"
@@ -29,6 +30,18 @@ const (
 	writablePasswordKey    = "WRITABLE_PASSWORD"
 )
 
+// passwordKeys is the list of keys that a password secret must have.
+var passwordKeys = []string{
+	AdminPasswordKey,
+	agentPasswordKey,
+	replicationPasswordKey,
+	cloneDonorPasswordKey,
+	exporterPasswordKey,
+	BackupPasswordKey,
+	readOnlyPasswordKey,
+	writablePasswordKey,
+}
+
 // MySQLPassword represents a set of passwords of MySQL users for MOCO
 type MySQLPassword struct {
 	admin      string
@@ -39,6 +52,9 @@ type MySQLPassword struct {
 	backup     string
 	readOnly   string
 	writable   string
+
+	// replicators holds the passwords of the replication users other than moco-repl.
+	replicators map[string]string
 }
 
 // NewMySQLPassword generates random passwords for NewMySQLPassword and return it.
@@ -101,6 +117,24 @@ func NewMySQLPasswordFromSecret(secret *corev1.Secret) (*MySQLPassword, error) {
 		return nil, fmt.Errorf("secret %s/%s does not have valid annotation", secret.Namespace, secret.Name)
 	}
 
+	for _, key := range passwordKeys {
+		if len(secret.Data[key]) == 0 {
+			return nil, fmt.Errorf("secret %s/%s does not have %s", secret.Namespace, secret.Name, key)
+		}
+	}
+
+	var replicators map[string]string
+	prefix := replicationPasswordKey + "_"
+	for key, val := range secret.Data {
+		if !strings.HasPrefix(key, prefix) || len(val) == 0 {
+			continue
+		}
+		if replicators == nil {
+			replicators = make(map[string]string)
+		}
+		replicators[strings.TrimPrefix(key, prefix)] = string(val)
+	}
+
 	return &MySQLPassword{
 		admin:      string(secret.Data[AdminPasswordKey]),
 		agent:      string(secret.Data[agentPasswordKey]),
@@ -110,13 +144,15 @@ func NewMySQLPasswordFromSecret(secret *corev1.Secret) (*MySQLPassword, error) {
 		backup:     string(secret.Data[BackupPasswordKey]),
 		readOnly:   string(secret.Data[readOnlyPasswordKey]),
 		writable:   string(secret.Data[writablePasswordKey]),
+
+		replicators: replicators,
 	}, nil
 }
 
 // ToSecret converts MySQLPassword to Secret.
 // The caller have to fill Name and Namespace of the returned Secret.
 func (p MySQLPassword) ToSecret() *corev1.Secret {
-	return &corev1.Secret{
+	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Annotations: map[string]string{
 				constants.AnnSecretVersion: passwordVersion,
@@ -133,6 +169,10 @@ func (p MySQLPassword) ToSecret() *corev1.Secret {
 			writablePasswordKey:    []byte(p.writable),
 		},
 	}
+	for user, pwd := range p.replicators {
+		secret.Data[ReplicationPasswordKey(user)] = []byte(pwd)
+	}
+	return secret
 }
 
 var mycnfTmpl = template.Must(template.New("my.cnf").Parse(`[client]
@@ -193,6 +233,42 @@ func (p MySQLPassword) Replicator() string {
 	return p.replicator
 }
 
+// ReplicatorOf returns the password for the replication user `user`.
+// The second return value is false if the password of `user` has not been generated.
+func (p MySQLPassword) ReplicatorOf(user string) (string, bool) {
+	if user == constants.ReplicationUser {
+		return p.replicator, true
+	}
+	pwd, ok := p.replicators[user]
+	return pwd, ok
+}
+
+// AddReplicator generates the password for the replication user `user` unless it exists.
+// This returns true if the password is generated.
+func (p *MySQLPassword) AddReplicator(user string) (bool, error) {
+	if _, ok := p.ReplicatorOf(user); ok {
+		return false, nil
+	}
+	pwd, err := generateRandomPassword()
+	if err != nil {
+		return false, err
+	}
+	if p.replicators == nil {
+		p.replicators = make(map[string]string)
+	}
+	p.replicators[user] = pwd
+	return true, nil
+}
+
+// ReplicationPasswordKey returns the key of the password for the replication user `user`
+// in the password Secret.  The key for moco-repl is REPLICATION_PASSWORD.
+func ReplicationPasswordKey(user string) string {
+	if user == constants.ReplicationUser {
+		return replicationPasswordKey
+	}
+	return replicationPasswordKey + "_" + user
+}
+
 // Donor returns the password for moco-clone-donor.
 func (p MySQLPassword) Donor() string {
 	return p.donor
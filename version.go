@@ -10,3 +10,8 @@ const (
 	// ExporterImage is the image for mysqld_exporter sidecar container.
 	ExporterImage = "ghcr.io/cybozu-go/moco/mysqld_exporter:0.15.0.2"
 )
+
+// GitCommit is the git commit from which MOCO is built.
+// It can be set with `-ldflags "-X github.com/cybozu-go/moco.GitCommit=..."`.
+// If not set, the VCS revision embedded by the Go toolchain is used.
+var GitCommit = ""